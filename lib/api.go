@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
 	authRouters "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/auth/routers"
+	cinemasRouter "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/cinemas/routers"
+	moviesRepo "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/movies/repositories"
 	moviesRouter "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/movies/routers"
+	reservationsRepo "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/reservations/repositories"
 	reservationsRouter "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/reservations/routers"
 )
 
@@ -40,6 +44,11 @@ func (server *Server) Serve() {
 	subRouter.Handle("/auth/", http.StripPrefix("/auth", authRouter.Router))
 	authRouter.RegisterRouts()
 
+	// Cinemas router:
+	cinemasRouter := cinemasRouter.NewCinemasRouter()
+	subRouter.Handle("/cinemas/", http.StripPrefix("/cinemas", cinemasRouter.Router))
+	cinemasRouter.RegisterRouts()
+
 	// Movies router:
 	moviesRouter := moviesRouter.NewAuthRouter()
 	subRouter.Handle("/movies/", http.StripPrefix("/movies", moviesRouter.Router))
@@ -50,6 +59,14 @@ func (server *Server) Serve() {
 	subRouter.Handle("/reservations/", http.StripPrefix("/reservations", reservationsRouter.Router))
 	reservationsRouter.RegisterRouts()
 
+	// Background jobs:
+	moviesRepo.NewMoviesRepository().FailInterruptedImportJobs()
+	go moviesRepo.NewMoviesRepository().BackfillTMDBIDs(context.Background())
+	moviesRepo.NewMoviesRepository().StartMetadataRefresh(context.Background(), moviesRepo.MetadataRefreshInterval)
+	reservationsRepo.NewReservationsRepo().StartNotificationsDispatch(context.Background(), reservationsRepo.NotificationsDispatchInterval)
+	reservationsRepo.NewReservationsRepo().StartRefundsRetry(context.Background(), reservationsRepo.RefundsRetryInterval)
+	reservationsRepo.NewReservationsRepo().StartHoldsRelease(context.Background(), reservationsRepo.HoldsReleaseInterval)
+
 	// Run server :
 	fmt.Println("Server listening on: ", server.address)
 	log.Fatal(http.ListenAndServe(server.address, mainRouter).Error())
@@ -77,6 +77,23 @@ func (authcontroller *AuthController) GetUser(w http.ResponseWriter, r *http.Req
 	w.Write(reponse)
 }
 
+func (authcontroller *AuthController) SetDefaultCinema(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		CinemaID uint `json:"cinemaID"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	authRepo := authcontroller.authRepo
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	id := uint(auth["id"].(float64))
+	status, result := authRepo.SetDefaultCinema(id, body.CinemaID)
+
+	w.WriteHeader(status)
+	reponse, _ := json.Marshal(result)
+	w.Write(reponse)
+}
+
 func (authcontroller *AuthController) SendEmailVerificationLink(w http.ResponseWriter, r *http.Request) {
 	var body map[string]any
 	json.NewDecoder(r.Body).Decode(&body)
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+func (authcontroller *AuthController) GetPoints(w http.ResponseWriter, r *http.Request) {
+	authRepo := authcontroller.authRepo
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	id := uint(auth["id"].(float64))
+	status, result := authRepo.GetPoints(id, tools.GetListingQuery(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
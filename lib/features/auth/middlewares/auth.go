@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	authRepo "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/auth/repositories"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
 )
 
 type AuthMiddlewares struct {
@@ -72,3 +73,27 @@ func (authMiddlewares *AuthMiddlewares) AuthorizationWithAdminCheck(next http.Ha
 		w.Write(reponse)
 	}
 }
+
+func (authMiddlewares *AuthMiddlewares) AuthorizationWithCinemaAdminCheck(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authRepo := authMiddlewares.authRepo
+		auth, _ := r.Context().Value("auth").(map[string]any)
+		id := uint(auth["id"].(float64))
+		isAdmin := auth["isAdmin"].(bool)
+		status, result := authRepo.AuthorizationWithCinemaAdminCheck(id, isAdmin)
+
+		if status == http.StatusOK {
+			adminCinemaIDs, _ := result["adminCinemaIDs"].([]uint)
+			auth["cinemaScope"] = tools.CinemaScope{
+				IsSuperAdmin: isAdmin,
+				CinemaIDs:    adminCinemaIDs,
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.WriteHeader(status)
+		reponse, _ := json.Marshal(result)
+		w.Write(reponse)
+	}
+}
@@ -52,6 +52,10 @@ func (authRepo *AuthRepo) RegisterWithEmailAndPassword(user *models.User) (int,
 		}
 	}
 
+	// Cinema roles are only granted by super admins:
+	user.AdminCinemas = nil
+	user.DefaultCinema = nil
+
 	// Adding auth provider
 	err = addAuthProvider(user, "password", database)
 	if err != nil {
@@ -185,6 +189,34 @@ func (authRepo *AuthRepo) AuthorizationWithAdminCheck(isAdmin bool) (int, map[st
 	return http.StatusOK, nil
 }
 
+// AuthorizationWithCinemaAdminCheck lets in super admins and the admins of at
+// least one cinema. For the latter, the managed cinemas are returned so the
+// handlers can restrict what they touch.
+func (authRepo *AuthRepo) AuthorizationWithCinemaAdminCheck(id uint, isAdmin bool) (int, map[string]any) {
+	if isAdmin {
+		return http.StatusOK, map[string]any{}
+	}
+
+	database := authRepo.database
+
+	adminCinemaIDs := []uint{}
+	err := database.Table("cinema_admins").Where("user_id = ?", id).Pluck("cinema_id", &adminCinemaIDs).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]any{
+			"error": "FINDING_USER_FAILED",
+		}
+	}
+	if len(adminCinemaIDs) == 0 {
+		return http.StatusUnauthorized, map[string]any{
+			"error": "UNAUTHORIZED",
+		}
+	}
+
+	return http.StatusOK, map[string]any{
+		"adminCinemaIDs": adminCinemaIDs,
+	}
+}
+
 func (authRepo *AuthRepo) GetUser(id uint) (int, map[string]any) {
 	// Validate authorization:
 	if id == 0 {
@@ -197,7 +229,7 @@ func (authRepo *AuthRepo) GetUser(id uint) (int, map[string]any) {
 
 	// Getting user:
 	var user models.User
-	err := database.Where("id = ?", id).First(&user).Error
+	err := database.Where("id = ?", id).Preload("DefaultCinema").Preload("AdminCinemas").First(&user).Error
 	if err != nil {
 		return http.StatusInternalServerError, map[string]any{
 			"error": "FINDING_USER_FAILED",
@@ -341,3 +373,31 @@ func (authRepo *AuthRepo) SendPasswordResetLink(toEmail string, url string) (int
 		"message": "RESET_PASSWORD_LINK_SENT",
 	}
 }
+
+func (authRepo *AuthRepo) SetDefaultCinema(id uint, cinemaID uint) (int, map[string]any) {
+	if cinemaID == 0 {
+		return http.StatusBadRequest, map[string]any{
+			"error": "INVALID_CINEMA_ID",
+		}
+	}
+
+	database := authRepo.database
+
+	err := database.Where("id = ?", cinemaID).First(&models.Cinema{}).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]any{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+
+	err = database.Model(&models.User{}).Where("id = ?", id).Update("default_cinema_id", cinemaID).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]any{
+			"error": "UPDATING_USER_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]any{
+		"message": "DEFAULT_CINEMA_SET",
+	}
+}
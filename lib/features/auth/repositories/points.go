@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+var loyaltyEntriesListing = tools.ListingOptions{
+	Model: models.LoyaltyEntry{},
+	SortFields: map[string]string{
+		"id":        "id",
+		"createdAt": "created_at",
+	},
+	DefaultSort:  "-id",
+	DefaultLimit: tools.DefaultListingLimit,
+}
+
+func (authRepo *AuthRepo) GetPoints(id uint, listing tools.ListingQuery) (int, map[string]any) {
+	if id == 0 {
+		return http.StatusBadRequest, map[string]any{
+			"error": "INDEFINED_ID",
+		}
+	}
+	if err := listing.Validate(loyaltyEntriesListing); err != nil {
+		return http.StatusBadRequest, map[string]any{
+			"error": err.Error(),
+		}
+	}
+
+	database := authRepo.database
+
+	var user models.User
+	err := database.Select("id", "fidelity_points").Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]any{
+			"error": "FINDING_USER_FAILED",
+		}
+	}
+
+	var entries []models.LoyaltyEntry
+	page, err := tools.FindPage(database.Model(&models.LoyaltyEntry{}).Where("user_id = ?", id), &entries, listing)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]any{
+			"error": "FETCHING_POINTS_HISTORY_FAILED",
+		}
+	}
+	for index := range entries {
+		entries[index].UserID = 0
+	}
+
+	return http.StatusOK, map[string]any{
+		"balance":    user.FidelityPoints,
+		"count":      len(entries),
+		"total":      page.Total,
+		"nextCursor": page.NextCursor,
+		"history":    tools.SelectFields(entries, listing.Fields),
+	}
+}
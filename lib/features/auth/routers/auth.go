@@ -41,6 +41,8 @@ func (authRouter *AuthRouter) RegisterRouts() {
 	router.HandleFunc("POST /loginWithEmailAndPassword", controller.LoginWithEmailAndPassword)
 	router.HandleFunc("GET /getUser", authorizationWithEmailVerification(http.HandlerFunc(controller.GetUser)))
 	router.HandleFunc("GET /getAdmin", authorizationWithAdminCheck(http.HandlerFunc(controller.GetUser)))
+	router.HandleFunc("GET /me/points", authorizationWithEmailVerification(http.HandlerFunc(controller.GetPoints)))
+	router.HandleFunc("PUT /setDefaultCinema", authorizationWithEmailVerification(http.HandlerFunc(controller.SetDefaultCinema)))
 	router.HandleFunc("POST /sendEmailVerificationLink", controller.SendEmailVerificationLink)
 	router.HandleFunc("GET /verifyEmail/{idToken}", controller.VerifyEmail)
 	router.HandleFunc("POST /sendPasswordResetLink", controller.SendPasswordResetLink)
@@ -0,0 +1,102 @@
+package cinemas
+
+import (
+	"encoding/json"
+	"net/http"
+
+	cinemasRepo "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/cinemas/repositories"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+type CinemasController struct {
+	cinemasRepo *cinemasRepo.CinemasRepo
+}
+
+func NewCinemasController() *CinemasController {
+	return &CinemasController{
+		cinemasRepo: cinemasRepo.NewCinemasRepository(),
+	}
+}
+
+func (cinemasController *CinemasController) AddCinema(w http.ResponseWriter, r *http.Request) {
+	var cinema models.Cinema
+	json.NewDecoder(r.Body).Decode(&cinema)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.AddCinema(cinema)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) GetCinemas(w http.ResponseWriter, r *http.Request) {
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.GetCinemas()
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) GetCinema(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.GetCinema(id)
+
+	if status == http.StatusOK {
+		cinema := result["cinema"].(models.Cinema)
+		w.WriteHeader(status)
+		reponse, _ := json.MarshalIndent(cinema, "", "\t")
+		w.Write(reponse)
+		return
+	}
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) UpdateCinema(w http.ResponseWriter, r *http.Request) {
+	var cinema models.Cinema
+	json.NewDecoder(r.Body).Decode(&cinema)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.UpdateCinema(cinema, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) AddCinemaAdmin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		CinemaID uint `json:"cinemaID"`
+		UserID   uint `json:"userID"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.AddCinemaAdmin(body.CinemaID, body.UserID)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) RemoveCinemaAdmin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		CinemaID uint `json:"cinemaID"`
+		UserID   uint `json:"userID"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.RemoveCinemaAdmin(body.CinemaID, body.UserID)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
@@ -0,0 +1,67 @@
+package cinemas
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+func (cinemasController *CinemasController) AddConcessionItem(w http.ResponseWriter, r *http.Request) {
+	var item models.ConcessionItem
+	json.NewDecoder(r.Body).Decode(&item)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.AddConcessionItem(item)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) UpdateConcessionItem(w http.ResponseWriter, r *http.Request) {
+	var item models.ConcessionItem
+	json.NewDecoder(r.Body).Decode(&item)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.UpdateConcessionItem(item)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) DeleteConcessionItem(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.DeleteConcessionItem(id)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) SetConcessionStock(w http.ResponseWriter, r *http.Request) {
+	var stock models.ConcessionStock
+	json.NewDecoder(r.Body).Decode(&stock)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.SetConcessionStock(stock, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) GetConcessions(w http.ResponseWriter, r *http.Request) {
+	cinemaID := r.URL.Query().Get("cinemaID")
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.GetConcessions(cinemaID)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
@@ -0,0 +1,32 @@
+package cinemas
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+func (cinemasController *CinemasController) SetFormatSurcharge(w http.ResponseWriter, r *http.Request) {
+	var surcharge models.FormatSurcharge
+	json.NewDecoder(r.Body).Decode(&surcharge)
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.SetFormatSurcharge(surcharge, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (cinemasController *CinemasController) GetFormatSurcharges(w http.ResponseWriter, r *http.Request) {
+	cinemaID := r.URL.Query().Get("cinemaID")
+
+	cinemasRepo := cinemasController.cinemasRepo
+	status, result := cinemasRepo.GetFormatSurcharges(cinemaID)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
@@ -0,0 +1,233 @@
+package cinemas
+
+import (
+	"net/http"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	mysql "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/mysql"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+)
+
+type CinemasRepo struct {
+	database *gorm.DB
+}
+
+func NewCinemasRepository() *CinemasRepo {
+	return &CinemasRepo{
+		database: mysql.Instance,
+	}
+}
+
+func (cinemasRepo *CinemasRepo) AddCinema(cinema models.Cinema) (int, map[string]interface{}) {
+	if err := cinema.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := cinemasRepo.database
+
+	cinema.Halls = nil
+	err := database.Create(&cinema).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "CINEMA_CREATION_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":  "CINEMA_ADDED",
+		"cinemaID": cinema.ID,
+	}
+}
+
+func (cinemasRepo *CinemasRepo) GetCinemas() (int, map[string]interface{}) {
+	database := cinemasRepo.database
+
+	var cinemas []models.Cinema
+	err := database.Find(&cinemas).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_CINEMAS_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":   len(cinemas),
+		"cinemas": cinemas,
+	}
+}
+
+func (cinemasRepo *CinemasRepo) GetCinema(id string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	var cinema models.Cinema
+	err := database.Preload("Halls").Where("id = ?", id).First(&cinema).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"cinema": cinema,
+	}
+}
+
+func (cinemasRepo *CinemasRepo) UpdateCinema(newCinema models.Cinema, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	// Validating id:
+	if newCinema.ID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+	if !cinemaScope.Includes(newCinema.ID) {
+		return http.StatusForbidden, map[string]interface{}{
+			"error": "CINEMA_ACCESS_DENIED",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	var cinema models.Cinema
+	err := database.Where("id = ?", newCinema.ID).First(&cinema).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+
+	isRezoned := newCinema.Timezone != "" && newCinema.Timezone != cinema.Timezone
+
+	// Updating cinema:
+	if newCinema.Name != "" {
+		cinema.Name = newCinema.Name
+	}
+	if newCinema.Address != "" {
+		cinema.Address = newCinema.Address
+	}
+	if newCinema.Timezone != "" {
+		cinema.Timezone = newCinema.Timezone
+	}
+	if newCinema.Currency != "" {
+		cinema.Currency = newCinema.Currency
+	}
+	if newCinema.VATRate != nil {
+		cinema.VATRate = newCinema.VATRate
+	}
+	if err := cinema.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	// Changing the time zone moves the local show times of the halls that
+	// inherit it, refuse it while shows are planned in them:
+	if isRezoned {
+		futureDiffusionsCount, err := cinemasRepo.countInheritingFutureDiffusions(cinema.ID)
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "FETCHING_DIFFUSIONS_FAILED",
+			}
+		}
+		if futureDiffusionsCount > 0 {
+			return http.StatusConflict, map[string]interface{}{
+				"error": "HALL_HAS_FUTURE_DIFFUSIONS",
+				"count": futureDiffusionsCount,
+			}
+		}
+	}
+
+	err = database.Omit("Halls").Save(&cinema).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "UPDATING_CINEMA_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "CINEMA_UPDATED",
+	}
+}
+
+// countInheritingFutureDiffusions counts the diffusions to come in the halls
+// of the cinema that have no time zone of their own.
+func (cinemasRepo *CinemasRepo) countInheritingFutureDiffusions(cinemaID uint) (int64, error) {
+	database := cinemasRepo.database
+
+	var count int64
+	err := database.Model(&models.Diffusion{}).
+		Where("hall_id IN (?) AND show_time > ?",
+			database.Model(&models.Hall{}).Select("id").Where("cinema_id = ? AND (timezone IS NULL OR timezone = ?)", cinemaID, ""),
+			time.Now(),
+		).
+		Count(&count).Error
+	return count, err
+}
+
+func (cinemasRepo *CinemasRepo) AddCinemaAdmin(cinemaID uint, userID uint) (int, map[string]interface{}) {
+	if cinemaID == 0 || userID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ARGS",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	var cinema models.Cinema
+	err := database.Where("id = ?", cinemaID).First(&cinema).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+
+	var user models.User
+	err = database.Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "USER_NOT_FOUND",
+		}
+	}
+
+	err = database.Model(&user).Association("AdminCinemas").Append(&cinema)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ADDING_CINEMA_ADMIN_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "CINEMA_ADMIN_ADDED",
+	}
+}
+
+func (cinemasRepo *CinemasRepo) RemoveCinemaAdmin(cinemaID uint, userID uint) (int, map[string]interface{}) {
+	if cinemaID == 0 || userID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ARGS",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	user := models.User{ID: userID}
+	err := database.Model(&user).Association("AdminCinemas").Delete(&models.Cinema{ID: cinemaID})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "REMOVING_CINEMA_ADMIN_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "CINEMA_ADMIN_REMOVED",
+	}
+}
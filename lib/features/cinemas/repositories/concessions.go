@@ -0,0 +1,271 @@
+package cinemas
+
+import (
+	"net/http"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+)
+
+func (cinemasRepo *CinemasRepo) AddConcessionItem(item models.ConcessionItem) (int, map[string]interface{}) {
+	item.ID = 0
+	for index := range item.Variants {
+		item.Variants[index].ID = 0
+	}
+	if err := item.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := cinemasRepo.database
+
+	err := database.Where("name = ?", item.Name).First(&models.ConcessionItem{}).Error
+	if err == nil {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "CONCESSION_ITEM_ALREADY_EXISTS",
+		}
+	}
+
+	err = database.Create(&item).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ADDING_CONCESSION_ITEM_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "CONCESSION_ITEM_ADDED",
+		"item":    item,
+	}
+}
+
+// UpdateConcessionItem replaces the item and its variants. Variants sent with
+// an id are renamed, the ones without are added and the missing ones removed,
+// along with their stocks.
+func (cinemasRepo *CinemasRepo) UpdateConcessionItem(newItem models.ConcessionItem) (int, map[string]interface{}) {
+	if newItem.ID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+	if err := newItem.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := cinemasRepo.database
+
+	var item models.ConcessionItem
+	err := database.Where("id = ?", newItem.ID).Preload("Variants").First(&item).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "CONCESSION_ITEM_NOT_FOUND",
+		}
+	}
+
+	err = database.Where("name = ? AND id <> ?", newItem.Name, item.ID).First(&models.ConcessionItem{}).Error
+	if err == nil {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "CONCESSION_ITEM_ALREADY_EXISTS",
+		}
+	}
+
+	existingVariants := make(map[uint]bool)
+	for _, variant := range item.Variants {
+		existingVariants[variant.ID] = true
+	}
+	var keptVariantIDs []uint
+	for index := range newItem.Variants {
+		variant := &newItem.Variants[index]
+		if variant.ID != 0 && !existingVariants[variant.ID] {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": "INVALID_VARIANTS",
+			}
+		}
+		variant.ItemID = item.ID
+		if variant.ID != 0 {
+			keptVariantIDs = append(keptVariantIDs, variant.ID)
+		}
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		removedVariants := tx.Where("item_id = ?", item.ID)
+		if len(keptVariantIDs) > 0 {
+			removedVariants = removedVariants.Where("id NOT IN ?", keptVariantIDs)
+		}
+		if err := removedVariants.Delete(&models.ConcessionVariant{}).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&item).Select("name", "description", "category", "pic_url").Updates(models.ConcessionItem{
+			Name:        newItem.Name,
+			Description: newItem.Description,
+			Category:    newItem.Category,
+			PicURL:      newItem.PicURL,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for index := range newItem.Variants {
+			if err := tx.Save(&newItem.Variants[index]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "UPDATING_CONCESSION_ITEM_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "CONCESSION_ITEM_UPDATED",
+		"item":    newItem,
+	}
+}
+
+func (cinemasRepo *CinemasRepo) DeleteConcessionItem(id string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	var item models.ConcessionItem
+	err := database.Where("id = ?", id).First(&item).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "CONCESSION_ITEM_NOT_FOUND",
+		}
+	}
+
+	err = database.Delete(&item).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "DELETING_CONCESSION_ITEM_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "CONCESSION_ITEM_DELETED",
+	}
+}
+
+// SetConcessionStock sets the price and the quantity left of a variant in the
+// cinema.
+func (cinemasRepo *CinemasRepo) SetConcessionStock(stock models.ConcessionStock, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if err := stock.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	if !cinemaScope.Includes(stock.CinemaID) {
+		return http.StatusForbidden, map[string]interface{}{
+			"error": "CINEMA_ACCESS_DENIED",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	err := database.Where("id = ?", stock.CinemaID).First(&models.Cinema{}).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+	err = database.Where("id = ?", stock.VariantID).First(&models.ConcessionVariant{}).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "VARIANT_NOT_FOUND",
+		}
+	}
+
+	var storedStock models.ConcessionStock
+	database.Where(models.ConcessionStock{
+		CinemaID:  stock.CinemaID,
+		VariantID: stock.VariantID,
+	}).FirstOrInit(&storedStock)
+	storedStock.Price = stock.Price
+	storedStock.Quantity = stock.Quantity
+
+	err = database.Save(&storedStock).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "SETTING_STOCK_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "STOCK_SET",
+		"stock":   storedStock,
+	}
+}
+
+// GetConcessions returns the catalog of the cinema: the items with the
+// variants it has in stock, priced in its currency.
+func (cinemasRepo *CinemasRepo) GetConcessions(cinemaID string) (int, map[string]interface{}) {
+	if cinemaID == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_CINEMA_ID",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	var cinema models.Cinema
+	err := database.Where("id = ?", cinemaID).First(&cinema).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+
+	var stocks []models.ConcessionStock
+	err = database.Where("cinema_id = ? AND quantity > 0", cinema.ID).Find(&stocks).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_CONCESSIONS_FAILED",
+		}
+	}
+
+	stocksByVariant := make(map[uint]models.ConcessionStock)
+	var variantIDs []uint
+	for _, stock := range stocks {
+		stocksByVariant[stock.VariantID] = stock
+		variantIDs = append(variantIDs, stock.VariantID)
+	}
+
+	items := []models.ConcessionItem{}
+	if len(variantIDs) > 0 {
+		err = database.Where("id IN (?)", database.Model(&models.ConcessionVariant{}).Select("item_id").Where("id IN ?", variantIDs)).
+			Preload("Variants", "id IN ?", variantIDs).
+			Order("category, name").
+			Find(&items).Error
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "FETCHING_CONCESSIONS_FAILED",
+			}
+		}
+	}
+
+	for itemIndex := range items {
+		for variantIndex := range items[itemIndex].Variants {
+			variant := &items[itemIndex].Variants[variantIndex]
+			variant.Price = stocksByVariant[variant.ID].Price
+			variant.Quantity = stocksByVariant[variant.ID].Quantity
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":    len(items),
+		"currency": cinema.Currency,
+		"items":    items,
+	}
+}
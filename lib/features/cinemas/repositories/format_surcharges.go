@@ -0,0 +1,88 @@
+package cinemas
+
+import (
+	"net/http"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+// SetFormatSurcharge sets the amount added to the seat price of the cinema
+// diffusions shown in the format. A zero amount removes the surcharge.
+func (cinemasRepo *CinemasRepo) SetFormatSurcharge(surcharge models.FormatSurcharge, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if err := surcharge.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	if !cinemaScope.Includes(surcharge.CinemaID) {
+		return http.StatusForbidden, map[string]interface{}{
+			"error": "CINEMA_ACCESS_DENIED",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	err := database.Where("id = ?", surcharge.CinemaID).First(&models.Cinema{}).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+
+	if surcharge.Amount == 0 {
+		err = database.Where("cinema_id = ? AND format = ?", surcharge.CinemaID, surcharge.Format).
+			Delete(&models.FormatSurcharge{}).Error
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "REMOVING_SURCHARGE_FAILED",
+			}
+		}
+
+		return http.StatusOK, map[string]interface{}{
+			"message": "SURCHARGE_REMOVED",
+		}
+	}
+
+	var storedSurcharge models.FormatSurcharge
+	database.Where(models.FormatSurcharge{
+		CinemaID: surcharge.CinemaID,
+		Format:   surcharge.Format,
+	}).FirstOrInit(&storedSurcharge)
+	storedSurcharge.Amount = surcharge.Amount
+
+	err = database.Save(&storedSurcharge).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "SETTING_SURCHARGE_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":   "SURCHARGE_SET",
+		"surcharge": storedSurcharge,
+	}
+}
+
+func (cinemasRepo *CinemasRepo) GetFormatSurcharges(cinemaID string) (int, map[string]interface{}) {
+	if cinemaID == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_CINEMA_ID",
+		}
+	}
+
+	database := cinemasRepo.database
+
+	var surcharges []models.FormatSurcharge
+	err := database.Where("cinema_id = ?", cinemaID).Order("format").Find(&surcharges).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_SURCHARGES_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":      len(surcharges),
+		"surcharges": surcharges,
+	}
+}
@@ -0,0 +1,58 @@
+package cinemas
+
+import (
+	"net/http"
+
+	authMiddlewares "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/auth/middlewares"
+	cinemasControllers "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/cinemas/controllers"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+type CinemasRouter struct {
+	Router      *http.ServeMux
+	controller  cinemasControllers.CinemasController
+	middlewares authMiddlewares.AuthMiddlewares
+}
+
+func NewCinemasRouter() *CinemasRouter {
+	return &CinemasRouter{
+		Router:      http.NewServeMux(),
+		controller:  *cinemasControllers.NewCinemasController(),
+		middlewares: *authMiddlewares.NewAuthMiddlewares(),
+	}
+}
+
+func (cinemasRouter *CinemasRouter) RegisterRouts() {
+	router := cinemasRouter.Router
+	controller := cinemasRouter.controller
+	middlewares := cinemasRouter.middlewares
+
+	authorizationWithEmailVerification := tools.MiddlewareChain(
+		middlewares.Authorization,
+		middlewares.AuthorizationWithEmailVerification,
+	)
+	authorizationWithAdminCheck := tools.MiddlewareChain(
+		middlewares.Authorization,
+		middlewares.AuthorizationWithEmailVerification,
+		middlewares.AuthorizationWithAdminCheck,
+	)
+	authorizationWithCinemaAdminCheck := tools.MiddlewareChain(
+		middlewares.Authorization,
+		middlewares.AuthorizationWithEmailVerification,
+		middlewares.AuthorizationWithCinemaAdminCheck,
+	)
+
+	router.HandleFunc("POST /addCinema", authorizationWithAdminCheck(http.HandlerFunc(controller.AddCinema)))
+	router.HandleFunc("GET /getCinemas", authorizationWithEmailVerification(http.HandlerFunc(controller.GetCinemas)))
+	router.HandleFunc("GET /getCinema", authorizationWithEmailVerification(http.HandlerFunc(controller.GetCinema)))
+	router.HandleFunc("PUT /updateCinema", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.UpdateCinema)))
+	router.HandleFunc("POST /addCinemaAdmin", authorizationWithAdminCheck(http.HandlerFunc(controller.AddCinemaAdmin)))
+	router.HandleFunc("DELETE /removeCinemaAdmin", authorizationWithAdminCheck(http.HandlerFunc(controller.RemoveCinemaAdmin)))
+	router.HandleFunc("PUT /setFormatSurcharge", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.SetFormatSurcharge)))
+	router.HandleFunc("GET /getFormatSurcharges", authorizationWithEmailVerification(http.HandlerFunc(controller.GetFormatSurcharges)))
+	router.HandleFunc("POST /addConcessionItem", authorizationWithAdminCheck(http.HandlerFunc(controller.AddConcessionItem)))
+	router.HandleFunc("PUT /updateConcessionItem", authorizationWithAdminCheck(http.HandlerFunc(controller.UpdateConcessionItem)))
+	router.HandleFunc("DELETE /deleteConcessionItem/{id}", authorizationWithAdminCheck(http.HandlerFunc(controller.DeleteConcessionItem)))
+	router.HandleFunc("PUT /setConcessionStock", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.SetConcessionStock)))
+	router.HandleFunc("GET /getConcessions", authorizationWithEmailVerification(http.HandlerFunc(controller.GetConcessions)))
+}
@@ -0,0 +1,56 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+func (moviesController *MoviesController) GetActor(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetActor(id, getUserID(r), getCinemaID(r), getLocale(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) GetGenre(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetGenre(id, getUserID(r), getCinemaID(r), getLocale(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) SearchActors(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.SearchActors(query, tools.GetListingQuery(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) MergeActors(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ActorID      uint   `json:"actorID"`
+		DuplicateIDs []uint `json:"duplicateIDs"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.MergeActors(body.ActorID, body.DuplicateIDs)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
@@ -0,0 +1,31 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+func (moviesController *MoviesController) GetMovieCertifications(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetMovieCertifications(id)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) SetMovieCertification(w http.ResponseWriter, r *http.Request) {
+	var certification models.MovieCertification
+	json.NewDecoder(r.Body).Decode(&certification)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.SetMovieCertification(certification)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
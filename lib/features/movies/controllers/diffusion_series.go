@@ -0,0 +1,58 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+func (moviesController *MoviesController) AddDiffusionSeries(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		models.DiffusionSeries
+		SkipConflicts bool `json:"skipConflicts"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.AddDiffusionSeries(body.DiffusionSeries, body.SkipConflicts, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) GetDiffusionSeries(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetDiffusionSeries(id, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) UpdateDiffusionSeries(w http.ResponseWriter, r *http.Request) {
+	var series models.DiffusionSeries
+	json.NewDecoder(r.Body).Decode(&series)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.UpdateDiffusionSeries(series, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) CancelDiffusionSeries(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.CancelDiffusionSeries(id, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
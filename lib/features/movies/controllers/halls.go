@@ -0,0 +1,66 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+func (moviesController *MoviesController) UpdateHall(w http.ResponseWriter, r *http.Request) {
+	var hall models.Hall
+	json.NewDecoder(r.Body).Decode(&hall)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.UpdateHall(hall, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) DeleteHall(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.DeleteHall(id, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) AddHallMaintenance(w http.ResponseWriter, r *http.Request) {
+	var maintenance models.HallMaintenance
+	json.NewDecoder(r.Body).Decode(&maintenance)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.AddHallMaintenance(maintenance, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) GetHallMaintenances(w http.ResponseWriter, r *http.Request) {
+	hallID := r.URL.Query().Get("hallID")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetHallMaintenances(hallID, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) DeleteHallMaintenance(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.DeleteHallMaintenance(id, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
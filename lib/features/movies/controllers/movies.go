@@ -3,10 +3,12 @@ package movies
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	moviesRepo "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/movies/repositories"
 	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
 )
 
 type MoviesController struct {
@@ -25,7 +27,7 @@ func (moviesController *MoviesController) GetMoviesFromTMDB(w http.ResponseWrite
 	pageString := queries.Get("page")
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetMoviesFromTMDB(query, pageString)
+	status, result := moviesRepo.GetMoviesFromTMDB(r.Context(), query, pageString)
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -42,7 +44,30 @@ func (moviesController *MoviesController) AddMovie(w http.ResponseWriter, r *htt
 	po := body["po"]
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.AddMovie(tmdbID, trailerVideoID, language, po)
+	status, result := moviesRepo.AddMovie(r.Context(), tmdbID, trailerVideoID, language, po)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) ImportMovies(w http.ResponseWriter, r *http.Request) {
+	var job models.ImportJob
+	json.NewDecoder(r.Body).Decode(&job)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.ImportMovies(job, getUserID(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetImportJob(id)
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -53,7 +78,7 @@ func (moviesController *MoviesController) GetMovieTrailersFromTMDB(w http.Respon
 	tmdbID := r.URL.Query().Get("tmdbID")
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetMovieTrailersFromTMDB(tmdbID)
+	status, result := moviesRepo.GetMovieTrailersFromTMDB(r.Context(), tmdbID)
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -64,7 +89,7 @@ func (moviesController *MoviesController) GetMovie(w http.ResponseWriter, r *htt
 	id := r.URL.Query().Get("id")
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetMovie(id)
+	status, result := moviesRepo.GetMovie(id, getLocale(r))
 
 	if status == http.StatusOK {
 		movie := result["movie"].(models.Movie)
@@ -81,7 +106,16 @@ func (moviesController *MoviesController) GetMovie(w http.ResponseWriter, r *htt
 
 func (moviesController *MoviesController) GetMovies(w http.ResponseWriter, r *http.Request) {
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetMovies()
+	status, result := moviesRepo.GetMovies(tools.GetListingQuery(r), getLocale(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) SearchMovies(w http.ResponseWriter, r *http.Request) {
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.SearchMovies(r.URL.Query(), getUserID(r), getCinemaID(r), getLocale(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -100,12 +134,21 @@ func (moviesController *MoviesController) UpdateMovie(w http.ResponseWriter, r *
 	w.Write(reponse)
 }
 
+func (moviesController *MoviesController) RefreshMoviesMetadata(w http.ResponseWriter, r *http.Request) {
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.RefreshMoviesMetadata(r.Context())
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
 func (moviesController *MoviesController) AddDiffusion(w http.ResponseWriter, r *http.Request) {
 	var diffusion models.Diffusion
 	json.NewDecoder(r.Body).Decode(&diffusion)
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.AddDiffusion(diffusion)
+	status, result := moviesRepo.AddDiffusion(diffusion, tools.GetCinemaScope(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -117,7 +160,7 @@ func (moviesController *MoviesController) AddHall(w http.ResponseWriter, r *http
 	json.NewDecoder(r.Body).Decode(&hall)
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.AddHall(hall)
+	status, result := moviesRepo.AddHall(hall, tools.GetCinemaScope(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -126,7 +169,7 @@ func (moviesController *MoviesController) AddHall(w http.ResponseWriter, r *http
 
 func (moviesController *MoviesController) GetHalls(w http.ResponseWriter, r *http.Request) {
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetHalls()
+	status, result := moviesRepo.GetHalls(getCinemaID(r), tools.GetCinemaScope(r), tools.GetListingQuery(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -145,7 +188,7 @@ func (moviesController *MoviesController) DeleteMovie(w http.ResponseWriter, r *
 
 func (moviesController *MoviesController) GetAllWeeksUntilNextYear(w http.ResponseWriter, r *http.Request) {
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetAllWeeksUntilNextYear()
+	status, result := moviesRepo.GetAllWeeksUntilNextYear(getCinemaID(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -166,6 +209,8 @@ func (moviesController *MoviesController) GetDiffusionsForAdmin(w http.ResponseW
 		diffusionFilter.FromDate,
 		diffusionFilter.ToDate,
 		diffusionFilter.HallID,
+		tools.GetCinemaScope(r),
+		getLocale(r),
 	)
 
 	w.WriteHeader(status)
@@ -176,7 +221,7 @@ func (moviesController *MoviesController) GetDiffusionsForAdmin(w http.ResponseW
 func (moviesController *MoviesController) DeleteDiffusion(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.DeleteDiffusion(id)
+	status, result := moviesRepo.DeleteDiffusion(id, tools.GetCinemaScope(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -185,7 +230,7 @@ func (moviesController *MoviesController) DeleteDiffusion(w http.ResponseWriter,
 
 func (moviesController *MoviesController) GetTopDiffusion(w http.ResponseWriter, r *http.Request) {
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetTopDiffusion()
+	status, result := moviesRepo.GetTopDiffusion(getUserID(r), getCinemaID(r), getLocale(r))
 
 	if status == http.StatusOK {
 		diffusion := result["diffusion"].(models.Diffusion)
@@ -202,7 +247,9 @@ func (moviesController *MoviesController) GetTopDiffusion(w http.ResponseWriter,
 
 func (moviesController *MoviesController) GetDiffusionsByDay(w http.ResponseWriter, r *http.Request) {
 	type DiffusionFilter struct {
-		Day time.Time `json:"day"`
+		Day      time.Time `json:"day"`
+		CinemaID uint      `json:"cinemaID"`
+		models.DiffusionVersion
 	}
 	var diffusionFilter DiffusionFilter
 	json.NewDecoder(r.Body).Decode(&diffusionFilter)
@@ -210,6 +257,10 @@ func (moviesController *MoviesController) GetDiffusionsByDay(w http.ResponseWrit
 	moviesRepo := moviesController.moviesRepo
 	status, result := moviesRepo.GetDiffusionsByDay(
 		diffusionFilter.Day,
+		getUserID(r),
+		diffusionFilter.CinemaID,
+		diffusionFilter.DiffusionVersion,
+		getLocale(r),
 	)
 
 	w.WriteHeader(status)
@@ -221,7 +272,18 @@ func (moviesController *MoviesController) GetMostPopularDiffusionsTrailers(w htt
 	trailersCount := r.URL.Query().Get("count")
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetMostPopularDiffusionsTrailers(trailersCount)
+	status, result := moviesRepo.GetMostPopularDiffusionsTrailers(trailersCount, getUserID(r), getCinemaID(r), getLocale(r))
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	count := r.URL.Query().Get("count")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetRecommendations(count, getUserID(r), getCinemaID(r), getLocale(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -230,7 +292,7 @@ func (moviesController *MoviesController) GetMostPopularDiffusionsTrailers(w htt
 
 func (moviesController *MoviesController) GetDiffusionsForUsers(w http.ResponseWriter, r *http.Request) {
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetDiffusionsForUsers()
+	status, result := moviesRepo.GetDiffusionsForUsers(getUserID(r), getCinemaID(r), getDiffusionVersion(r), tools.GetListingQuery(r), getLocale(r))
 
 	w.WriteHeader(status)
 	reponse, _ := json.MarshalIndent(result, "", "\t")
@@ -241,7 +303,7 @@ func (moviesController *MoviesController) GetMoviesDiffusions(w http.ResponseWri
 	id := r.URL.Query().Get("id")
 
 	moviesRepo := moviesController.moviesRepo
-	status, result := moviesRepo.GetMoviesDiffusions(id)
+	status, result := moviesRepo.GetMoviesDiffusions(id, getUserID(r), getCinemaID(r), getDiffusionVersion(r), getLocale(r))
 
 	if status == http.StatusOK {
 		movie := result["movie"].(models.Movie)
@@ -255,3 +317,55 @@ func (moviesController *MoviesController) GetMoviesDiffusions(w http.ResponseWri
 	reponse, _ := json.MarshalIndent(result, "", "\t")
 	w.Write(reponse)
 }
+
+func (moviesController *MoviesController) CopyWeek(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		SourceWeek    time.Time `json:"sourceWeek"`
+		TargetWeek    time.Time `json:"targetWeek"`
+		HallID        uint      `json:"hallID"`
+		DryRun        bool      `json:"dryRun"`
+		SkipConflicts bool      `json:"skipConflicts"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.CopyWeek(
+		body.SourceWeek,
+		body.TargetWeek,
+		body.HallID,
+		body.DryRun,
+		body.SkipConflicts,
+		tools.GetCinemaScope(r),
+	)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func getUserID(r *http.Request) uint {
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	id, _ := auth["id"].(float64)
+	return uint(id)
+}
+
+func getCinemaID(r *http.Request) uint {
+	cinemaID, _ := strconv.Atoi(r.URL.Query().Get("cinemaID"))
+	if cinemaID < 0 {
+		return 0
+	}
+	return uint(cinemaID)
+}
+
+func getLocale(r *http.Request) string {
+	return tools.GetLocale(r, models.SupportedLocales)
+}
+
+func getDiffusionVersion(r *http.Request) models.DiffusionVersion {
+	query := r.URL.Query()
+	return models.DiffusionVersion{
+		AudioLanguage:    query.Get("audioLanguage"),
+		SubtitleLanguage: query.Get("subtitleLanguage"),
+		Format:           query.Get("format"),
+	}
+}
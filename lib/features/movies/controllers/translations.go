@@ -0,0 +1,43 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+func (moviesController *MoviesController) GetMovieTranslations(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.GetMovieTranslations(id)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) UpdateMovieTranslation(w http.ResponseWriter, r *http.Request) {
+	var translation models.MovieTranslation
+	json.NewDecoder(r.Body).Decode(&translation)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.UpdateMovieTranslation(translation)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
+
+func (moviesController *MoviesController) UpdateTypeTranslation(w http.ResponseWriter, r *http.Request) {
+	var translation models.TypeTranslation
+	json.NewDecoder(r.Body).Decode(&translation)
+
+	moviesRepo := moviesController.moviesRepo
+	status, result := moviesRepo.UpdateTypeTranslation(translation)
+
+	w.WriteHeader(status)
+	reponse, _ := json.MarshalIndent(result, "", "\t")
+	w.Write(reponse)
+}
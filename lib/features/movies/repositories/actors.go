@@ -0,0 +1,260 @@
+package movies
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+)
+
+var actorsListing = tools.ListingOptions{
+	Model: models.Actor{},
+	SortFields: map[string]string{
+		"id":   "id",
+		"name": "name",
+	},
+	DefaultSort:  "name",
+	DefaultLimit: tools.DefaultListingLimit,
+}
+
+func (moviesRepo *MoviesRepo) GetActor(id string, userID uint, cinemaID uint, locale string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var actor models.Actor
+	err := database.Where("id = ?", id).First(&actor).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "ACTOR_NOT_FOUND",
+		}
+	}
+
+	actorMovies := database.Table("movie_actors").Select("movie_id").Where("actor_id = ?", actor.ID)
+
+	var filmography []models.Movie
+	err = database.Select("id", "title", "pic_url", "rate").
+		Where("id IN (?)", actorMovies).
+		Order("id DESC").
+		Find(&filmography).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_MOVIES_FAILED",
+		}
+	}
+
+	showingMovies, err := moviesRepo.getShowingMovies(actorMovies, userID, cinemaID, locale)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_MOVIES_FAILED",
+		}
+	}
+
+	moviesToTranslate := make([]*models.Movie, len(filmography))
+	for index := range filmography {
+		moviesToTranslate[index] = &filmography[index]
+	}
+	moviesRepo.translateMovies(moviesToTranslate, locale)
+
+	return http.StatusOK, map[string]interface{}{
+		"actor":       actor,
+		"movies":      showingMovies,
+		"filmography": filmography,
+	}
+}
+
+func (moviesRepo *MoviesRepo) GetGenre(id string, userID uint, cinemaID uint, locale string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var genre models.Type
+	err := database.Where("id = ?", id).First(&genre).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "GENRE_NOT_FOUND",
+		}
+	}
+
+	if locale != "" {
+		var translation models.TypeTranslation
+		err := database.Where("type_id = ? AND locale = ?", genre.ID, locale).First(&translation).Error
+		if err == nil && translation.Name != "" {
+			genre.Name = translation.Name
+		}
+	}
+
+	genreMovies := database.Table("movie_types").Select("movie_id").Where("type_id = ?", genre.ID)
+	showingMovies, err := moviesRepo.getShowingMovies(genreMovies, userID, cinemaID, locale)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_MOVIES_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"genre":  genre,
+		"movies": showingMovies,
+	}
+}
+
+// getShowingMovies returns the movies among the given ones with upcoming
+// diffusions in the cinema of the user, the soonest shown first.
+func (moviesRepo *MoviesRepo) getShowingMovies(movieIDs *gorm.DB, userID uint, cinemaID uint, locale string) ([]models.Movie, error) {
+	database := moviesRepo.database
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
+
+	nextShowTimes := database.Model(&models.Diffusion{}).
+		Select("diffusions.movie_id, MIN(diffusions.show_time) AS next_show_time").
+		Scopes(moviesRepo.diffusionsInCinema(cinemaID)).
+		Where("diffusions.show_time > ?", time.Now()).
+		Group("diffusions.movie_id")
+
+	var movies []models.Movie
+	err := database.Joins("JOIN (?) AS showings ON showings.movie_id = movies.id", nextShowTimes).
+		Where("movies.id IN (?)", movieIDs).
+		Preload("Type").
+		Order("showings.next_show_time ASC").
+		Find(&movies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	moviesToTranslate := make([]*models.Movie, len(movies))
+	for index := range movies {
+		moviesToTranslate[index] = &movies[index]
+	}
+	moviesRepo.translateMovies(moviesToTranslate, locale)
+
+	return movies, nil
+}
+
+func (moviesRepo *MoviesRepo) SearchActors(query string, listing tools.ListingQuery) (int, map[string]interface{}) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_QUERY",
+		}
+	}
+	if err := listing.Validate(actorsListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	var actors []models.Actor
+	page, err := tools.FindPage(
+		database.Model(&models.Actor{}).Where("name LIKE ?", likePattern(query)),
+		&actors,
+		listing,
+	)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "SEARCHING_ACTORS_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":      len(actors),
+		"total":      page.Total,
+		"nextCursor": page.NextCursor,
+		"actors":     tools.SelectFields(actors, listing.Fields),
+	}
+}
+
+// MergeActors moves the movies of the duplicate actors to the kept one, then
+// deletes the duplicates.
+func (moviesRepo *MoviesRepo) MergeActors(actorID uint, duplicateIDs []uint) (int, map[string]interface{}) {
+	if actorID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ACTOR_ID",
+		}
+	}
+	if len(duplicateIDs) == 0 || slices.Contains(duplicateIDs, actorID) || slices.Contains(duplicateIDs, 0) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_DUPLICATE_IDS",
+		}
+	}
+	slices.Sort(duplicateIDs)
+	duplicateIDs = slices.Compact(duplicateIDs)
+
+	database := moviesRepo.database
+
+	var actor models.Actor
+	err := database.Where("id = ?", actorID).First(&actor).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "ACTOR_NOT_FOUND",
+		}
+	}
+
+	var duplicates []models.Actor
+	err = database.Where("id IN ?", duplicateIDs).Find(&duplicates).Error
+	if err != nil || len(duplicates) != len(duplicateIDs) {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "DUPLICATE_ACTOR_NOT_FOUND",
+		}
+	}
+
+	// Keep what the duplicates know and the actor does not:
+	updates := make(map[string]interface{})
+	for _, duplicate := range duplicates {
+		if actor.TMDBID == 0 && duplicate.TMDBID != 0 {
+			actor.TMDBID = duplicate.TMDBID
+			updates["tmdb_id"] = duplicate.TMDBID
+		}
+		if actor.PicURL == "" && duplicate.PicURL != "" {
+			actor.PicURL = duplicate.PicURL
+			updates["pic_url"] = duplicate.PicURL
+		}
+	}
+
+	var movedCount int64
+	err = database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(
+			"INSERT IGNORE INTO movie_actors (movie_id, actor_id) SELECT movie_id, ? FROM movie_actors WHERE actor_id IN ?",
+			actor.ID, duplicateIDs,
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		movedCount = result.RowsAffected
+
+		if err := tx.Exec("DELETE FROM movie_actors WHERE actor_id IN ?", duplicateIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", duplicateIDs).Delete(&models.Actor{}).Error; err != nil {
+			return err
+		}
+		if len(updates) > 0 {
+			return tx.Model(&actor).Updates(updates).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "MERGING_ACTORS_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":     "ACTORS_MERGED",
+		"actor":       actor,
+		"mergedCount": len(duplicates),
+		"movedMovies": movedCount,
+	}
+}
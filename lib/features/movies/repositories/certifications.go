@@ -0,0 +1,172 @@
+package movies
+
+import (
+	"context"
+	"net/http"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
+	gorm "gorm.io/gorm"
+)
+
+func (moviesRepo *MoviesRepo) SetMovieCertification(newCertification models.MovieCertification) (int, map[string]interface{}) {
+	// Validate inputs:
+	if err := newCertification.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	var movie models.Movie
+	err := database.Where("id = ?", newCertification.MovieID).First(&movie).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "MOVIE_NOT_FOUND",
+		}
+	}
+
+	certification, err := saveAdminCertification(database, newCertification)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "SETTING_CERTIFICATION_FAILED",
+		}
+	}
+
+	// The default country certification is the one shown on the movie:
+	if certification.Country == models.DefaultCertificationCountry {
+		movie.AddOverride("certification")
+		err = database.Model(&movie).Updates(map[string]interface{}{
+			"certification":   certification.Certification,
+			"minimum_age":     certification.MinimumAge,
+			"admin_overrides": movie.AdminOverrides,
+		}).Error
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "SETTING_CERTIFICATION_FAILED",
+			}
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":       "CERTIFICATION_SET",
+		"certification": certification,
+	}
+}
+
+// saveAdminCertification stores the certification of the movie in the
+// country, as edited by an admin so that refreshes keep it.
+func saveAdminCertification(tx *gorm.DB, newCertification models.MovieCertification) (models.MovieCertification, error) {
+	var certification models.MovieCertification
+	err := tx.Where(models.MovieCertification{
+		MovieID: newCertification.MovieID,
+		Country: newCertification.Country,
+	}).FirstOrInit(&certification).Error
+	if err != nil {
+		return certification, err
+	}
+
+	certification.Certification = newCertification.Certification
+	certification.MinimumAge = newCertification.MinimumAge
+	certification.AdminEdited = true
+
+	return certification, tx.Save(&certification).Error
+}
+
+func (moviesRepo *MoviesRepo) GetMovieCertifications(id string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var movie models.Movie
+	err := database.Where("id = ?", id).
+		Select("id", "certification", "minimum_age").
+		Preload("Certifications").
+		First(&movie).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "MOVIE_NOT_FOUND",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"movieID":        movie.ID,
+		"certification":  movie.Certification,
+		"minimumAge":     movie.MinimumAge,
+		"certifications": movie.Certifications,
+	}
+}
+
+// syncMovieCertifications stores the theatrical certifications of the movie in
+// the countries with a known rating system, leaving the ones edited by an
+// admin untouched.
+func (moviesRepo *MoviesRepo) syncMovieCertifications(ctx context.Context, movie *models.Movie) error {
+	releaseDates, err := moviesRepo.tmdbAPI.GetMovieReleaseDates(ctx, int(*movie.TMDBID))
+	if err != nil {
+		return err
+	}
+
+	database := moviesRepo.database
+
+	for _, countryReleaseDates := range releaseDates.Results {
+		certificationName := theatricalCertification(countryReleaseDates.ReleaseDates)
+		minimumAge, ok := models.CertificationMinimumAge(countryReleaseDates.Country, certificationName)
+		if certificationName == "" || !ok {
+			continue
+		}
+
+		var certification models.MovieCertification
+		database.Where(models.MovieCertification{
+			MovieID: movie.ID,
+			Country: countryReleaseDates.Country,
+		}).FirstOrInit(&certification)
+
+		if certification.AdminEdited {
+			continue
+		}
+
+		certification.Certification = certificationName
+		certification.MinimumAge = minimumAge
+		err = database.Save(&certification).Error
+		if err != nil {
+			return err
+		}
+
+		if certification.Country == models.DefaultCertificationCountry && !movie.IsOverridden("certification") {
+			movie.Certification = certification.Certification
+			movie.MinimumAge = certification.MinimumAge
+			err = database.Model(movie).Updates(map[string]interface{}{
+				"certification": movie.Certification,
+				"minimum_age":   movie.MinimumAge,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// theatricalCertification prefers the certification of the theatrical
+// release, then any other one.
+func theatricalCertification(releaseDates []tmdb.ReleaseDate) string {
+	certification := ""
+	for _, releaseDate := range releaseDates {
+		if releaseDate.Certification == "" {
+			continue
+		}
+		if releaseDate.Type == tmdb.ReleaseTypeTheatrical {
+			return releaseDate.Certification
+		}
+		if certification == "" {
+			certification = releaseDate.Certification
+		}
+	}
+	return certification
+}
@@ -0,0 +1,54 @@
+package movies
+
+import (
+	"testing"
+
+	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
+)
+
+func TestTheatricalCertification(t *testing.T) {
+	tests := []struct {
+		name         string
+		releaseDates []tmdb.ReleaseDate
+		want         string
+	}{
+		{
+			name:         "no release dates",
+			releaseDates: nil,
+			want:         "",
+		},
+		{
+			name: "theatrical preferred",
+			releaseDates: []tmdb.ReleaseDate{
+				{Type: tmdb.ReleaseTypePremiere},
+				{Certification: "12", Type: tmdb.ReleaseTypeDigital},
+				{Certification: "TP", Type: tmdb.ReleaseTypeTheatrical},
+			},
+			want: "TP",
+		},
+		{
+			name: "first certified otherwise",
+			releaseDates: []tmdb.ReleaseDate{
+				{Type: tmdb.ReleaseTypeTheatrical},
+				{Certification: "16", Type: tmdb.ReleaseTypeDigital},
+				{Certification: "18", Type: tmdb.ReleaseTypeTV},
+			},
+			want: "16",
+		},
+		{
+			name: "uncertified",
+			releaseDates: []tmdb.ReleaseDate{
+				{Type: tmdb.ReleaseTypeTheatrical},
+			},
+			want: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := theatricalCertification(test.releaseDates); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package movies
+
+import (
+	"errors"
+	"net/http"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+)
+
+// getManagedHall fetches the hall and checks that it belongs to one of the
+// cinemas the admin manages.
+func (moviesRepo *MoviesRepo) getManagedHall(hallID interface{}, cinemaScope tools.CinemaScope) (models.Hall, int, error) {
+	var hall models.Hall
+	err := moviesRepo.database.Preload("Cinema").Where("id = ?", hallID).First(&hall).Error
+	if err != nil {
+		return hall, http.StatusBadRequest, errors.New("HALL_NOT_FOUND")
+	}
+	if !cinemaScope.Includes(hall.CinemaID) {
+		return hall, http.StatusForbidden, errors.New("CINEMA_ACCESS_DENIED")
+	}
+	return hall, http.StatusOK, nil
+}
+
+// resolveCinemaID falls back on the default cinema of the user when the
+// request does not name one. Zero means every cinema.
+func (moviesRepo *MoviesRepo) resolveCinemaID(userID uint, cinemaID uint) uint {
+	if cinemaID != 0 {
+		return cinemaID
+	}
+
+	var user models.User
+	err := moviesRepo.database.Select("id", "default_cinema_id").Where("id = ?", userID).First(&user).Error
+	if err != nil || user.DefaultCinemaID == nil {
+		return 0
+	}
+	return *user.DefaultCinemaID
+}
+
+// diffusionsInCinema restricts a diffusions query to the halls of the cinema.
+func (moviesRepo *MoviesRepo) diffusionsInCinema(cinemaID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cinemaID == 0 {
+			return db
+		}
+		halls := moviesRepo.database.Model(&models.Hall{}).Select("id").Where("cinema_id = ?", cinemaID)
+		return db.Where("diffusions.hall_id in (?)", halls)
+	}
+}
+
+// diffusionsInAdminScope restricts a diffusions query to the halls of the
+// cinemas the admin manages.
+func (moviesRepo *MoviesRepo) diffusionsInAdminScope(cinemaScope tools.CinemaScope) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if cinemaScope.IsSuperAdmin {
+			return db
+		}
+		halls := moviesRepo.database.Model(&models.Hall{}).Select("id").Where("cinema_id in ?", cinemaScope.CinemaIDs)
+		return db.Where("diffusions.hall_id in (?)", halls)
+	}
+}
@@ -0,0 +1,169 @@
+package movies
+
+import (
+	"net/http"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+)
+
+// CopyWeek duplicates the diffusions of the source week (optionally of a single
+// hall) into the target week, keeping the same weekday and local hour. Weeks are
+// given as the calendar date of their Sunday and read in the time zone of each
+// hall. With dryRun nothing is written and the proposed diffusions are
+// returned instead.
+func (moviesRepo *MoviesRepo) CopyWeek(sourceWeek time.Time, targetWeek time.Time, hallID uint, dryRun bool, skipConflicts bool, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if sourceWeek.IsZero() || sourceWeek.Weekday() != time.Sunday {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SOURCE_WEEK_MUST_BE_SUNDAY",
+		}
+	}
+	if targetWeek.IsZero() || targetWeek.Weekday() != time.Sunday {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "TARGET_WEEK_MUST_BE_SUNDAY",
+		}
+	}
+
+	sourceStart := time.Date(sourceWeek.Year(), sourceWeek.Month(), sourceWeek.Day(), 0, 0, 0, 0, time.UTC)
+	targetStart := time.Date(targetWeek.Year(), targetWeek.Month(), targetWeek.Day(), 0, 0, 0, 0, time.UTC)
+	shiftDays := int(targetStart.Sub(sourceStart) / (24 * time.Hour))
+	if shiftDays == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SAME_SOURCE_AND_TARGET_WEEK",
+		}
+	}
+
+	database := moviesRepo.database
+
+	// Get source diffusions, with a margin covering every time zone:
+	query := database.Scopes(moviesRepo.diffusionsInAdminScope(cinemaScope)).
+		Where("show_time >= ? and show_time < ?", sourceStart.Add(-14*time.Hour), sourceStart.AddDate(0, 0, 7).Add(14*time.Hour))
+	if hallID != 0 {
+		if _, status, err := moviesRepo.getManagedHall(hallID, cinemaScope); err != nil {
+			return status, map[string]interface{}{
+				"error": err.Error(),
+			}
+		}
+		query = query.Where("hall_id = ?", hallID)
+	}
+	var sourceDiffusions []models.Diffusion
+	err := query.Preload("Hall.Cinema").Order("show_time ASC").Find(&sourceDiffusions).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_DIFFUSIONS_FAILED",
+		}
+	}
+
+	// Shift diffusions to the target week:
+	now := time.Now()
+	var candidates []models.Diffusion
+	var halls []models.Hall
+	skippedIDs := []uint{}
+	for _, diffusion := range sourceDiffusions {
+		if diffusion.Hall == nil {
+			skippedIDs = append(skippedIDs, diffusion.ID)
+			continue
+		}
+
+		// Keep only the diffusions of the source week in the hall time zone:
+		location := diffusion.Hall.Location()
+		localShowTime := diffusion.ShowTime.In(location)
+		weekStart := tools.WeekStart(localShowTime, location)
+		if weekStart.Format(time.DateOnly) != sourceStart.Format(time.DateOnly) {
+			continue
+		}
+
+		// The hall may have lost the format since:
+		showTime := localShowTime.AddDate(0, 0, shiftDays)
+		if showTime.Before(now) || !diffusion.Hall.SupportsFormat(diffusion.Format) {
+			skippedIDs = append(skippedIDs, diffusion.ID)
+			continue
+		}
+		candidates = append(candidates, models.Diffusion{
+			MovieID:          diffusion.MovieID,
+			ShowTime:         showTime,
+			ShowDuration:     diffusion.ShowDuration,
+			HallID:           diffusion.HallID,
+			SeatPrice:        diffusion.SeatPrice,
+			DiffusionVersion: diffusion.DiffusionVersion,
+		})
+		halls = append(halls, *diffusion.Hall)
+	}
+
+	if len(candidates) == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error":      "NOTHING_TO_COPY",
+			"skippedIDs": skippedIDs,
+		}
+	}
+
+	var conflicts []diffusionConflict
+	var created []models.Diffusion
+	err = database.Transaction(func(tx *gorm.DB) error {
+		var conflicting map[int]bool
+		var err error
+		conflicts, conflicting, err = findScheduleConflicts(tx, candidates, nil)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+		if len(conflicts) > 0 && !skipConflicts {
+			return errScheduleConflicts
+		}
+
+		for index, candidate := range candidates {
+			if conflicting[index] {
+				continue
+			}
+			candidate.SeatsStatus = newHallSeats(halls[index])
+			created = append(created, candidate)
+		}
+		if len(created) == 0 {
+			return errScheduleConflicts
+		}
+
+		return tx.Create(&created).Error
+	})
+	if err == errScheduleConflicts {
+		return http.StatusConflict, map[string]interface{}{
+			"error":     err.Error(),
+			"count":     len(conflicts),
+			"conflicts": conflicts,
+		}
+	}
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "COPYING_WEEK_FAILED",
+		}
+	}
+
+	if dryRun {
+		for index := range candidates {
+			candidates[index].SetLocalTime(halls[index].Location())
+		}
+		return http.StatusOK, map[string]interface{}{
+			"dryRun":     true,
+			"count":      len(candidates),
+			"diffusions": candidates,
+			"conflicts":  conflicts,
+			"skippedIDs": skippedIDs,
+		}
+	}
+
+	var createdIDs []uint
+	for _, diffusion := range created {
+		createdIDs = append(createdIDs, diffusion.ID)
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":    "WEEK_COPIED",
+		"count":      len(created),
+		"ids":        createdIDs,
+		"conflicts":  conflicts,
+		"skippedIDs": skippedIDs,
+	}
+}
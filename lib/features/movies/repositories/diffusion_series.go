@@ -0,0 +1,349 @@
+package movies
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+var errScheduleConflicts = errors.New("SCHEDULE_CONFLICTS")
+
+func (moviesRepo *MoviesRepo) AddDiffusionSeries(series models.DiffusionSeries, skipConflicts bool, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if err := series.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	var movie models.Movie
+	err := database.Where("id = ?", series.MovieID).First(&movie).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "MOVIE_NOT_FOUND",
+		}
+	}
+
+	hall, status, err := moviesRepo.getManagedHall(series.HallID, cinemaScope)
+	if err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	if !hall.SupportsFormat(series.Format) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "FORMAT_NOT_SUPPORTED_BY_HALL",
+		}
+	}
+
+	// Expand the series:
+	occurrences := series.Occurrences(hall.Location())
+	if len(occurrences) == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SERIES_HAS_NO_OCCURRENCES",
+		}
+	}
+
+	var candidates []models.Diffusion
+	for _, showTime := range occurrences {
+		candidates = append(candidates, models.Diffusion{
+			MovieID:          movie.ID,
+			ShowTime:         showTime,
+			ShowDuration:     series.ShowDuration,
+			HallID:           hall.ID,
+			SeatPrice:        series.SeatPrice,
+			DiffusionVersion: series.DiffusionVersion,
+		})
+	}
+
+	var conflicts []diffusionConflict
+	err = database.Transaction(func(tx *gorm.DB) error {
+		var conflicting map[int]bool
+		var err error
+		conflicts, conflicting, err = findScheduleConflicts(tx, candidates, nil)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 && !skipConflicts {
+			return errScheduleConflicts
+		}
+
+		series.Diffusions = nil
+		for index, candidate := range candidates {
+			if conflicting[index] {
+				continue
+			}
+			candidate.SeatsStatus = newHallSeats(hall)
+			series.Diffusions = append(series.Diffusions, candidate)
+		}
+		if len(series.Diffusions) == 0 {
+			return errScheduleConflicts
+		}
+
+		return tx.Create(&series).Error
+	})
+	if err == errScheduleConflicts {
+		return http.StatusConflict, map[string]interface{}{
+			"error":     err.Error(),
+			"count":     len(conflicts),
+			"conflicts": conflicts,
+		}
+	}
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ADDING_DIFFUSION_SERIES_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":   "DIFFUSION_SERIES_ADDED",
+		"seriesID":  series.ID,
+		"count":     len(series.Diffusions),
+		"conflicts": conflicts,
+	}
+}
+
+func (moviesRepo *MoviesRepo) GetDiffusionSeries(id string, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var series models.DiffusionSeries
+	err := database.Preload("Diffusions", func(db *gorm.DB) *gorm.DB {
+		return db.Where("show_time > ?", time.Now()).Order("show_time ASC")
+	}).Where("id = ?", id).First(&series).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SERIES_NOT_FOUND",
+		}
+	}
+	if _, status, err := moviesRepo.getManagedHall(series.HallID, cinemaScope); err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	moviesRepo.localizeDiffusions(series.Diffusions)
+
+	return http.StatusOK, map[string]interface{}{
+		"series": series,
+	}
+}
+
+// UpdateDiffusionSeries changes the price, duration and version of the
+// occurrences of the series that have not started yet. Past occurrences are
+// left untouched. The schedule of a series cannot change: it is canceled and
+// added again instead.
+func (moviesRepo *MoviesRepo) UpdateDiffusionSeries(newSeries models.DiffusionSeries, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if newSeries.ID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+	if newSeries.SeatPrice < 0 || newSeries.ShowDuration < 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ARGS",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var series models.DiffusionSeries
+	err := database.Preload("Diffusions", func(db *gorm.DB) *gorm.DB {
+		return db.Where("show_time > ?", time.Now())
+	}).Where("id = ?", newSeries.ID).First(&series).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SERIES_NOT_FOUND",
+		}
+	}
+	hall, status, err := moviesRepo.getManagedHall(series.HallID, cinemaScope)
+	if err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	// Reject schedule changes rather than ignoring them:
+	movieChanged := newSeries.MovieID != 0 && newSeries.MovieID != series.MovieID
+	hallChanged := newSeries.HallID != 0 && newSeries.HallID != series.HallID
+	datesChanged := (!newSeries.FromDate.IsZero() && !newSeries.FromDate.Equal(series.FromDate)) ||
+		(!newSeries.ToDate.IsZero() && !newSeries.ToDate.Equal(series.ToDate))
+	showTimesChanged := newSeries.ShowTimes != nil && !slices.Equal(newSeries.ShowTimes, series.ShowTimes)
+	skippedWeekdaysChanged := newSeries.SkippedWeekdays != nil && !slices.Equal(newSeries.SkippedWeekdays, series.SkippedWeekdays)
+	if movieChanged || hallChanged || datesChanged || showTimesChanged || skippedWeekdaysChanged {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SERIES_SCHEDULE_NOT_UPDATABLE",
+		}
+	}
+
+	// Apply the version:
+	newSeries.DiffusionVersion.Normalize()
+	if newSeries.AudioLanguage != "" {
+		series.AudioLanguage = newSeries.AudioLanguage
+	}
+	if newSeries.SubtitleLanguage != "" {
+		series.SubtitleLanguage = newSeries.SubtitleLanguage
+	}
+	if newSeries.Format != "" {
+		series.Format = newSeries.Format
+	}
+	if err := series.DiffusionVersion.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	if !hall.SupportsFormat(series.Format) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "FORMAT_NOT_SUPPORTED_BY_HALL",
+		}
+	}
+
+	if newSeries.SeatPrice > 0 {
+		series.SeatPrice = newSeries.SeatPrice
+	}
+	if newSeries.ShowDuration > 0 {
+		series.ShowDuration = newSeries.ShowDuration
+	}
+
+	var remainingIDs []uint
+	for index := range series.Diffusions {
+		diffusion := &series.Diffusions[index]
+		diffusion.SeatPrice = series.SeatPrice
+		diffusion.ShowDuration = series.ShowDuration
+		diffusion.DiffusionVersion = series.DiffusionVersion
+		remainingIDs = append(remainingIDs, diffusion.ID)
+	}
+
+	var conflicts []diffusionConflict
+	err = database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		conflicts, _, err = findScheduleConflicts(tx, series.Diffusions, remainingIDs)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return errScheduleConflicts
+		}
+
+		if len(remainingIDs) > 0 {
+			err = tx.Model(&models.Diffusion{}).Where("id in ?", remainingIDs).Updates(map[string]interface{}{
+				"seat_price":        series.SeatPrice,
+				"show_duration":     series.ShowDuration,
+				"audio_language":    series.AudioLanguage,
+				"subtitle_language": series.SubtitleLanguage,
+				"format":            series.Format,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Omit("Diffusions").Save(&series).Error
+	})
+	if err == errScheduleConflicts {
+		return http.StatusConflict, map[string]interface{}{
+			"error":     err.Error(),
+			"count":     len(conflicts),
+			"conflicts": conflicts,
+		}
+	}
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "UPDATING_DIFFUSION_SERIES_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "DIFFUSION_SERIES_UPDATED",
+		"count":   len(remainingIDs),
+	}
+}
+
+// CancelDiffusionSeries deletes the occurrences of the series that have not
+// started yet. Occurrences that already have reservations are kept and
+// reported so the admin can handle them one by one.
+func (moviesRepo *MoviesRepo) CancelDiffusionSeries(id string, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var series models.DiffusionSeries
+	err := database.Where("id = ?", id).First(&series).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SERIES_NOT_FOUND",
+		}
+	}
+	if _, status, err := moviesRepo.getManagedHall(series.HallID, cinemaScope); err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	var canceledIDs []uint
+	keptIDs := []uint{}
+	err = database.Transaction(func(tx *gorm.DB) error {
+		// The occurrences are locked before their reservations are checked,
+		// so that none is reserved until they are deleted:
+		var diffusions []models.Diffusion
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("series_id = ? AND show_time > ?", series.ID, time.Now()).
+			Order("id").
+			Find(&diffusions).Error
+		if err != nil || len(diffusions) == 0 {
+			return err
+		}
+
+		var diffusionIDs []uint
+		for _, diffusion := range diffusions {
+			diffusionIDs = append(diffusionIDs, diffusion.ID)
+		}
+		var reservedIDs []uint
+		err = tx.Model(&models.Reservation{}).
+			Where("diffusion_id IN ?", diffusionIDs).
+			Distinct().
+			Pluck("diffusion_id", &reservedIDs).Error
+		if err != nil {
+			return err
+		}
+
+		for _, diffusionID := range diffusionIDs {
+			if slices.Contains(reservedIDs, diffusionID) {
+				keptIDs = append(keptIDs, diffusionID)
+				continue
+			}
+			canceledIDs = append(canceledIDs, diffusionID)
+		}
+		if len(canceledIDs) == 0 {
+			return nil
+		}
+		return tx.Unscoped().Where("id in ?", canceledIDs).Delete(&models.Diffusion{}).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "CANCELING_DIFFUSION_SERIES_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "DIFFUSION_SERIES_CANCELED",
+		"count":   len(canceledIDs),
+		"keptIDs": keptIDs,
+	}
+}
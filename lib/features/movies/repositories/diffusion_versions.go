@@ -0,0 +1,67 @@
+package movies
+
+import (
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	gorm "gorm.io/gorm"
+)
+
+// diffusionsWithVersion keeps the diffusions shown in the requested version,
+// the empty attributes matching any.
+func (moviesRepo *MoviesRepo) diffusionsWithVersion(version models.DiffusionVersion) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if version.AudioLanguage != "" {
+			db = db.Where("diffusions.audio_language = ?", version.AudioLanguage)
+		}
+		switch version.SubtitleLanguage {
+		case "":
+		case models.NoSubtitles:
+			db = db.Where("diffusions.subtitle_language = ''")
+		default:
+			db = db.Where("diffusions.subtitle_language = ?", version.SubtitleLanguage)
+		}
+		if version.Format != "" {
+			db = db.Where("diffusions.format = ?", version.Format)
+		}
+		return db
+	}
+}
+
+// priceDiffusions fills the ticket prices of the diffusions, with the format
+// surcharges of their cinemas.
+func (moviesRepo *MoviesRepo) priceDiffusions(diffusions []models.Diffusion) {
+	if len(diffusions) == 0 {
+		return
+	}
+
+	database := moviesRepo.database
+
+	var hallIDs []uint
+	for _, diffusion := range diffusions {
+		hallIDs = append(hallIDs, diffusion.HallID)
+	}
+
+	var halls []models.Hall
+	database.Unscoped().Select("id", "cinema_id").Where("id IN ?", hallIDs).Find(&halls)
+
+	hallCinemas := make(map[uint]uint)
+	var cinemaIDs []uint
+	for _, hall := range halls {
+		hallCinemas[hall.ID] = hall.CinemaID
+		cinemaIDs = append(cinemaIDs, hall.CinemaID)
+	}
+
+	var surcharges []models.FormatSurcharge
+	if len(cinemaIDs) > 0 {
+		database.Where("cinema_id IN ?", cinemaIDs).Find(&surcharges)
+	}
+
+	cinemaSurcharges := make(map[uint][]models.FormatSurcharge)
+	for _, surcharge := range surcharges {
+		cinemaSurcharges[surcharge.CinemaID] = append(cinemaSurcharges[surcharge.CinemaID], surcharge)
+	}
+
+	for index := range diffusions {
+		cinemaID := hallCinemas[diffusions[index].HallID]
+		diffusions[index].SetTicketPrice(cinemaSurcharges[cinemaID])
+	}
+}
@@ -0,0 +1,360 @@
+package movies
+
+import (
+	"net/http"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+)
+
+func (moviesRepo *MoviesRepo) UpdateHall(newHall models.Hall, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	// Validating id:
+	if newHall.ID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	hall, status, err := moviesRepo.getManagedHall(newHall.ID, cinemaScope)
+	if err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	if newHall.Timezone != "" {
+		if _, err := time.LoadLocation(newHall.Timezone); err != nil {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": "INVALID_TIMEZONE",
+			}
+		}
+	}
+
+	// Resizing changes the seat maps, and changing the time zone the local
+	// show times, refuse them while shows are planned:
+	isResized := (newHall.RowsCount != 0 && newHall.RowsCount != hall.RowsCount) ||
+		(newHall.ColumnsCount != 0 && newHall.ColumnsCount != hall.ColumnsCount)
+	isRezoned := newHall.Timezone != "" && newHall.Timezone != hall.Timezone
+	if isResized || isRezoned {
+		futureDiffusionsCount, err := moviesRepo.countFutureDiffusions(hall.ID)
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "FETCHING_DIFFUSIONS_FAILED",
+			}
+		}
+		if futureDiffusionsCount > 0 {
+			return http.StatusConflict, map[string]interface{}{
+				"error": "HALL_HAS_FUTURE_DIFFUSIONS",
+				"count": futureDiffusionsCount,
+			}
+		}
+	}
+
+	// Removing a format is refused while shows are planned in it:
+	if newHall.Formats != nil {
+		if err := newHall.ValidateFormats(); err != nil {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			}
+		}
+
+		var removedFormats []string
+		for _, format := range hall.Formats {
+			if !newHall.SupportsFormat(format) {
+				removedFormats = append(removedFormats, format)
+			}
+		}
+		if len(removedFormats) > 0 {
+			var count int64
+			err := database.Model(&models.Diffusion{}).
+				Where("hall_id = ? AND show_time > ? AND format IN ?", hall.ID, time.Now(), removedFormats).
+				Count(&count).Error
+			if err != nil {
+				return http.StatusInternalServerError, map[string]interface{}{
+					"error": "FETCHING_DIFFUSIONS_FAILED",
+				}
+			}
+			if count > 0 {
+				return http.StatusConflict, map[string]interface{}{
+					"error":   "HALL_FORMAT_IN_USE",
+					"formats": removedFormats,
+					"count":   count,
+				}
+			}
+		}
+		hall.Formats = newHall.Formats
+	}
+
+	// Updating hall:
+	if newHall.Name != "" {
+		hall.Name = newHall.Name
+	}
+	if newHall.Timezone != "" {
+		hall.Timezone = newHall.Timezone
+	}
+	if newHall.RowsCount != 0 {
+		hall.RowsCount = newHall.RowsCount
+	}
+	if newHall.ColumnsCount != 0 {
+		hall.ColumnsCount = newHall.ColumnsCount
+	}
+	if newHall.AisleAfterColumns != nil {
+		hall.AisleAfterColumns = newHall.AisleAfterColumns
+	}
+	if newHall.AccessibleSeats != nil {
+		hall.AccessibleSeats = newHall.AccessibleSeats
+	}
+	if err := hall.ValidateLayout(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	err = database.Save(&hall).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "UPDATING_HALL_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "HALL_UPDATED",
+	}
+}
+
+// DeleteHall takes the hall out of service. Past diffusions and their
+// reservations are kept, so the hall is only soft deleted.
+func (moviesRepo *MoviesRepo) DeleteHall(id string, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	hall, status, err := moviesRepo.getManagedHall(id, cinemaScope)
+	if err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	futureDiffusionsCount, err := moviesRepo.countFutureDiffusions(hall.ID)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_DIFFUSIONS_FAILED",
+		}
+	}
+	if futureDiffusionsCount > 0 {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "HALL_HAS_FUTURE_DIFFUSIONS",
+			"count": futureDiffusionsCount,
+		}
+	}
+
+	// Free the name of the hall, which stays unique among deleted halls:
+	err = database.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&hall).Update("name", models.DeletedHallName(hall.Name, hall.ID)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&hall).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "DELETING_HALL_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "HALL_DELETED",
+	}
+}
+
+func (moviesRepo *MoviesRepo) countFutureDiffusions(hallID uint) (int64, error) {
+	var count int64
+	err := moviesRepo.database.Model(&models.Diffusion{}).
+		Where("hall_id = ? and show_time > ?", hallID, time.Now()).
+		Count(&count).Error
+	return count, err
+}
+
+// AddHallMaintenance closes the hall for the given period. New diffusions are
+// refused during the window, and the already scheduled ones overlapping it are
+// flagged so the admin can move or cancel them.
+func (moviesRepo *MoviesRepo) AddHallMaintenance(maintenance models.HallMaintenance, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if err := maintenance.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	_, status, err := moviesRepo.getManagedHall(maintenance.HallID, cinemaScope)
+	if err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	flaggedDiffusions := []map[string]interface{}{}
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&maintenance).Error; err != nil {
+			return err
+		}
+
+		var diffusions []models.Diffusion
+		err := tx.Where("hall_id = ? and show_time > ? and show_time < ?", maintenance.HallID, maintenance.FromDate.Add(-maxShowDuration), maintenance.ToDate).
+			Find(&diffusions).Error
+		if err != nil {
+			return err
+		}
+
+		var flaggedIDs []uint
+		for _, diffusion := range diffusions {
+			if !maintenance.Overlaps(diffusion) {
+				continue
+			}
+			flaggedIDs = append(flaggedIDs, diffusion.ID)
+			flaggedDiffusions = append(flaggedDiffusions, map[string]interface{}{
+				"id":       diffusion.ID,
+				"movieID":  diffusion.MovieID,
+				"showTime": diffusion.ShowTime,
+			})
+		}
+		if len(flaggedIDs) == 0 {
+			return nil
+		}
+
+		return tx.Model(&models.Diffusion{}).Where("id in ?", flaggedIDs).Update("maintenance_conflict", true).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ADDING_MAINTENANCE_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":               "MAINTENANCE_ADDED",
+		"maintenanceID":         maintenance.ID,
+		"count":                 len(flaggedDiffusions),
+		"conflictingDiffusions": flaggedDiffusions,
+	}
+}
+
+func (moviesRepo *MoviesRepo) GetHallMaintenances(hallID string, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if hallID == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_HALL_ID",
+		}
+	}
+	if _, status, err := moviesRepo.getManagedHall(hallID, cinemaScope); err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	var maintenances []models.HallMaintenance
+	err := database.Where("hall_id = ? and to_date > ?", hallID, time.Now()).
+		Order("from_date ASC").
+		Find(&maintenances).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_MAINTENANCES_FAILED",
+		}
+	}
+
+	var conflictingDiffusions []models.Diffusion
+	err = database.Where("hall_id = ? and maintenance_conflict = ? and show_time > ?", hallID, true, time.Now()).
+		Preload("Movie", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "title")
+		}).
+		Order("show_time ASC").
+		Find(&conflictingDiffusions).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_DIFFUSIONS_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":                 len(maintenances),
+		"maintenances":          maintenances,
+		"conflictingDiffusions": conflictingDiffusions,
+	}
+}
+
+func (moviesRepo *MoviesRepo) DeleteHallMaintenance(id string, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var maintenance models.HallMaintenance
+	err := database.Where("id = ?", id).First(&maintenance).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "MAINTENANCE_NOT_FOUND",
+		}
+	}
+	if _, status, err := moviesRepo.getManagedHall(maintenance.HallID, cinemaScope); err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&maintenance).Error; err != nil {
+			return err
+		}
+
+		// Clear the flags that no other window justifies:
+		var remaining []models.HallMaintenance
+		if err := tx.Where("hall_id = ?", maintenance.HallID).Find(&remaining).Error; err != nil {
+			return err
+		}
+		var flagged []models.Diffusion
+		if err := tx.Where("hall_id = ? and maintenance_conflict = ?", maintenance.HallID, true).Find(&flagged).Error; err != nil {
+			return err
+		}
+
+		var clearedIDs []uint
+		for _, diffusion := range flagged {
+			isStillConflicting := false
+			for _, other := range remaining {
+				if other.Overlaps(diffusion) {
+					isStillConflicting = true
+					break
+				}
+			}
+			if !isStillConflicting {
+				clearedIDs = append(clearedIDs, diffusion.ID)
+			}
+		}
+		if len(clearedIDs) == 0 {
+			return nil
+		}
+
+		return tx.Model(&models.Diffusion{}).Where("id in ?", clearedIDs).Update("maintenance_conflict", false).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "DELETING_MAINTENANCE_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "MAINTENANCE_DELETED",
+	}
+}
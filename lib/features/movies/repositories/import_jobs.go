@@ -0,0 +1,281 @@
+package movies
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
+	gorm "gorm.io/gorm"
+)
+
+const importItemTimeout = time.Minute
+
+func (moviesRepo *MoviesRepo) ImportMovies(job models.ImportJob, userID uint) (int, map[string]interface{}) {
+	// Validate inputs:
+	if err := job.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	job.ID = 0
+	job.Items = nil
+	job.Status = models.ImportJobPending
+	job.Error = ""
+	job.FinishedAt = nil
+	job.CreatedByID = userID
+
+	database := moviesRepo.database
+
+	err := database.Create(&job).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "CREATING_IMPORT_JOB_FAILED",
+		}
+	}
+
+	// The job outlives the request:
+	go moviesRepo.runImportJob(context.Background(), job)
+
+	return http.StatusAccepted, map[string]interface{}{
+		"message": "IMPORT_JOB_STARTED",
+		"jobID":   job.ID,
+	}
+}
+
+func (moviesRepo *MoviesRepo) GetImportJob(id string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var job models.ImportJob
+	err := database.Where("id = ?", id).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id")
+		}).
+		First(&job).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "IMPORT_JOB_NOT_FOUND",
+		}
+	}
+
+	// Count the items per status:
+	counts := map[string]int{
+		models.ImportItemPending:  0,
+		models.ImportItemImported: 0,
+		models.ImportItemSkipped:  0,
+		models.ImportItemFailed:   0,
+	}
+	for _, item := range job.Items {
+		counts[item.Status]++
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"job":    job,
+		"counts": counts,
+	}
+}
+
+func (moviesRepo *MoviesRepo) runImportJob(ctx context.Context, job models.ImportJob) {
+	database := moviesRepo.database
+
+	database.Model(&job).Update("status", models.ImportJobRunning)
+
+	items, err := moviesRepo.getImportJobItems(ctx, job)
+	if err != nil {
+		log.Printf("import job %v failed: %v", job.ID, err.Error())
+		moviesRepo.finishImportJob(&job, models.ImportJobFailed, "FETCHING_TMDB_LIST_FAILED")
+		return
+	}
+
+	if len(items) > 0 {
+		err = database.Create(&items).Error
+		if err != nil {
+			moviesRepo.finishImportJob(&job, models.ImportJobFailed, "CREATING_IMPORT_ITEMS_FAILED")
+			return
+		}
+	}
+
+	for i := range items {
+		moviesRepo.runImportJobItem(ctx, &items[i], job.PO)
+	}
+
+	moviesRepo.finishImportJob(&job, models.ImportJobCompleted, "")
+}
+
+// FailInterruptedImportJobs fails the jobs left pending or running by a
+// previous run of the server, whose goroutines are gone.
+func (moviesRepo *MoviesRepo) FailInterruptedImportJobs() {
+	database := moviesRepo.database
+
+	interruptedJobIDs := database.Model(&models.ImportJob{}).
+		Select("id").
+		Where("status IN ?", []string{models.ImportJobPending, models.ImportJobRunning})
+
+	err := database.Model(&models.ImportJobItem{}).
+		Where("status = ? AND import_job_id IN (?)", models.ImportItemPending, interruptedJobIDs).
+		Updates(map[string]interface{}{
+			"status": models.ImportItemFailed,
+			"error":  "IMPORT_JOB_INTERRUPTED",
+		}).Error
+	if err != nil {
+		log.Printf("failing interrupted import items failed: %v", err.Error())
+		return
+	}
+
+	finishedAt := time.Now()
+	err = database.Model(&models.ImportJob{}).
+		Where("status IN ?", []string{models.ImportJobPending, models.ImportJobRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportJobFailed,
+			"error":       "IMPORT_JOB_INTERRUPTED",
+			"finished_at": &finishedAt,
+		}).Error
+	if err != nil {
+		log.Printf("failing interrupted import jobs failed: %v", err.Error())
+	}
+}
+
+func (moviesRepo *MoviesRepo) finishImportJob(job *models.ImportJob, status string, jobError string) {
+	finishedAt := time.Now()
+	moviesRepo.database.Model(job).Updates(map[string]interface{}{
+		"status":      status,
+		"error":       jobError,
+		"finished_at": &finishedAt,
+	})
+}
+
+// getImportJobItems lists the movies of the job source, once each.
+func (moviesRepo *MoviesRepo) getImportJobItems(ctx context.Context, job models.ImportJob) ([]models.ImportJobItem, error) {
+	var items []models.ImportJobItem
+	seen := make(map[uint]bool)
+	addItem := func(tmdbID uint, title string) {
+		if seen[tmdbID] {
+			return
+		}
+		seen[tmdbID] = true
+		items = append(items, models.ImportJobItem{
+			ImportJobID: job.ID,
+			TMDBID:      tmdbID,
+			Title:       title,
+			Status:      models.ImportItemPending,
+		})
+	}
+
+	if job.Source == models.ImportSourceIDs {
+		for _, tmdbID := range job.TMDBIDs {
+			addItem(tmdbID, "")
+		}
+		return items, nil
+	}
+
+	tmdbAPI := moviesRepo.tmdbAPI
+
+	for page := 1; page <= job.Pages; page++ {
+		var movies *tmdb.MoviesPage
+		var err error
+		if job.Source == models.ImportSourceNowPlaying {
+			movies, err = tmdbAPI.GetNowPlayingMovies(ctx, page)
+		} else {
+			movies, err = tmdbAPI.GetUpcomingMovies(ctx, page)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, movie := range movies.Results {
+			addItem(uint(movie.ID), movie.OriginalTitle)
+		}
+
+		if page >= movies.TotalPages {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+func (moviesRepo *MoviesRepo) runImportJobItem(ctx context.Context, item *models.ImportJobItem, po string) {
+	ctx, cancel := context.WithTimeout(ctx, importItemTimeout)
+	defer cancel()
+
+	defer moviesRepo.database.Save(item)
+
+	if moviesRepo.isMovieImported(int(item.TMDBID), "") {
+		item.Status = models.ImportItemSkipped
+		item.Error = "MOVIE_ALREADY_IMPORTED"
+		return
+	}
+
+	// Get the trailer:
+	videos, err := moviesRepo.tmdbAPI.GetMovieVideos(ctx, int(item.TMDBID))
+	if err != nil {
+		item.Status = models.ImportItemFailed
+		item.Error = "TRAILERS_FETCHING_FAILED"
+		return
+	}
+
+	item.TrailerVideoID = bestTrailerVideoID(videos.Results)
+	if item.TrailerVideoID == "" {
+		item.Status = models.ImportItemFailed
+		item.Error = "TRAILER_NOT_FOUND"
+		return
+	}
+
+	movie, status, err := moviesRepo.importMovie(ctx, int(item.TMDBID), item.TrailerVideoID, "", po)
+	if status == http.StatusConflict {
+		item.Status = models.ImportItemSkipped
+		item.Error = err.Error()
+		return
+	}
+	if err != nil {
+		item.Status = models.ImportItemFailed
+		item.Error = err.Error()
+		return
+	}
+
+	item.Status = models.ImportItemImported
+	item.Error = ""
+	item.Title = movie.Title
+	item.MovieID = &movie.ID
+}
+
+// bestTrailerVideoID picks the YouTube video to use as the movie trailer,
+// preferring official trailers, then teasers, in the best quality.
+func bestTrailerVideoID(videos []tmdb.Video) string {
+	bestVideoID := ""
+	bestScore := -1
+	for _, video := range videos {
+		if video.Site != "YouTube" || video.Key == "" {
+			continue
+		}
+
+		score := 0
+		if video.Official {
+			score += 4
+		}
+		switch video.Type {
+		case "Trailer":
+			score += 2
+		case "Teaser":
+			score += 1
+		}
+		if video.Size >= 1080 {
+			score += 1
+		}
+
+		if score > bestScore {
+			bestVideoID = video.Key
+			bestScore = score
+		}
+	}
+	return bestVideoID
+}
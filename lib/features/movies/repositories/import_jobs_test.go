@@ -0,0 +1,122 @@
+package movies
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
+)
+
+func newFakeMoviesRepo(fake *fakeTMDB) *MoviesRepo {
+	return &MoviesRepo{
+		tmdbAPI: tmdb.NewClient(fake.config()),
+	}
+}
+
+func TestGetImportJobItemsFromIDs(t *testing.T) {
+	fake := newFakeTMDB()
+	defer fake.Close()
+
+	job := models.ImportJob{
+		ID:      7,
+		Source:  models.ImportSourceIDs,
+		TMDBIDs: []uint{438631, 693134, 438631},
+	}
+	items, err := newFakeMoviesRepo(fake).getImportJobItems(context.Background(), job)
+	if err != nil {
+		t.Fatalf("getImportJobItems: %v", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2 without duplicates", len(items))
+	}
+	for i, tmdbID := range []uint{438631, 693134} {
+		item := items[i]
+		if item.TMDBID != tmdbID || item.ImportJobID != job.ID || item.Status != models.ImportItemPending {
+			t.Errorf("item %d = %+v, want pending tmdb movie %d of job %d", i, item, tmdbID, job.ID)
+		}
+	}
+}
+
+func TestGetImportJobItemsFromList(t *testing.T) {
+	fake := newFakeTMDB()
+	defer fake.Close()
+	fake.addMovie(fakeMovie{
+		Details:    tmdb.MovieDetails{ID: 438631, Title: "Dune", OriginalTitle: "Dune"},
+		NowPlaying: true,
+	})
+	fake.addMovie(fakeMovie{
+		Details:  tmdb.MovieDetails{ID: 693134, Title: "Dune: Part Two", OriginalTitle: "Dune: Part Two"},
+		Upcoming: true,
+	})
+
+	job := models.ImportJob{
+		Source: models.ImportSourceNowPlaying,
+		Pages:  3,
+	}
+	items, err := newFakeMoviesRepo(fake).getImportJobItems(context.Background(), job)
+	if err != nil {
+		t.Fatalf("getImportJobItems: %v", err)
+	}
+
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want the movie now playing only", len(items))
+	}
+	if items[0].TMDBID != 438631 || items[0].Title != "Dune" {
+		t.Errorf("item = %+v, want Dune", items[0])
+	}
+}
+
+func TestGetImportJobItemsRetriesServerErrors(t *testing.T) {
+	fake := newFakeTMDB()
+	defer fake.Close()
+	fake.addMovie(fakeMovie{
+		Details:  tmdb.MovieDetails{ID: 438631, OriginalTitle: "Dune"},
+		Upcoming: true,
+	})
+	fake.failNext(http.StatusBadGateway, http.StatusServiceUnavailable)
+
+	job := models.ImportJob{
+		Source: models.ImportSourceUpcoming,
+		Pages:  1,
+	}
+	items, err := newFakeMoviesRepo(fake).getImportJobItems(context.Background(), job)
+	if err != nil {
+		t.Fatalf("getImportJobItems: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+}
+
+func TestGetImportJobItemsFailsOnClientErrors(t *testing.T) {
+	fake := newFakeTMDB()
+	defer fake.Close()
+	fake.failNext(http.StatusUnauthorized)
+
+	job := models.ImportJob{
+		Source: models.ImportSourceNowPlaying,
+		Pages:  1,
+	}
+	_, err := newFakeMoviesRepo(fake).getImportJobItems(context.Background(), job)
+
+	var apiError *tmdb.APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("err = %v, want a 401 api error", err)
+	}
+}
+
+func TestBestTrailerVideoID(t *testing.T) {
+	videos := []tmdb.Video{
+		{Key: "vimeo", Site: "Vimeo", Type: "Trailer", Official: true, Size: 1080},
+		{Key: "teaser", Site: "YouTube", Type: "Teaser", Official: true, Size: 1080},
+		{Key: "fan", Site: "YouTube", Type: "Trailer", Size: 1080},
+		{Key: "official", Site: "YouTube", Type: "Trailer", Official: true, Size: 1080},
+	}
+	if videoID := bestTrailerVideoID(videos); videoID != "official" {
+		t.Errorf("trailer = %q, want the official YouTube trailer", videoID)
+	}
+}
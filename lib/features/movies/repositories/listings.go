@@ -0,0 +1,38 @@
+package movies
+
+import (
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+var moviesListing = tools.ListingOptions{
+	Model: models.Movie{},
+	SortFields: map[string]string{
+		"id":           "id",
+		"title":        "title",
+		"rate":         "rate",
+		"voteCount":    "vote_count",
+		"trailerViews": "trailer_views",
+		"duration":     "duration",
+	},
+	DefaultSort: "id",
+}
+
+var hallsListing = tools.ListingOptions{
+	Model: models.Hall{},
+	SortFields: map[string]string{
+		"id":   "id",
+		"name": "name",
+	},
+	DefaultSort: "id",
+}
+
+var diffusionsListing = tools.ListingOptions{
+	Model: models.Diffusion{},
+	SortFields: map[string]string{
+		"id":        "id",
+		"showTime":  "show_time",
+		"seatPrice": "seat_price",
+	},
+	DefaultSort: "showTime",
+}
@@ -0,0 +1,49 @@
+package movies
+
+import (
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+// localizeDiffusions fills the local show times of the diffusions, in the time
+// zone of their halls.
+func (moviesRepo *MoviesRepo) localizeDiffusions(diffusions []models.Diffusion) {
+	if len(diffusions) == 0 {
+		return
+	}
+
+	var hallIDs []uint
+	for _, diffusion := range diffusions {
+		hallIDs = append(hallIDs, diffusion.HallID)
+	}
+
+	var halls []models.Hall
+	moviesRepo.database.Unscoped().Preload("Cinema").Where("id in ?", hallIDs).Find(&halls)
+
+	locations := make(map[uint]*time.Location)
+	for index := range halls {
+		locations[halls[index].ID] = halls[index].Location()
+	}
+
+	for index := range diffusions {
+		location, ok := locations[diffusions[index].HallID]
+		if !ok {
+			location = time.UTC
+		}
+		diffusions[index].SetLocalTime(location)
+	}
+}
+
+func (moviesRepo *MoviesRepo) getCinemaLocation(cinemaID uint) *time.Location {
+	if cinemaID == 0 {
+		return time.UTC
+	}
+
+	var cinema models.Cinema
+	err := moviesRepo.database.Where("id = ?", cinemaID).First(&cinema).Error
+	if err != nil {
+		return time.UTC
+	}
+	return cinema.Location()
+}
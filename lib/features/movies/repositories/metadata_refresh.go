@@ -0,0 +1,239 @@
+package movies
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+const (
+	MetadataRefreshInterval = 6 * time.Hour
+	metadataRefreshTimeout  = 30 * time.Second
+	// Search results looked at to find the TMDB ID of a movie:
+	tmdbIDSearchPages = 3
+)
+
+// StartMetadataRefresh refreshes the metadata of the movies with upcoming
+// diffusions every interval, until the context is done.
+func (moviesRepo *MoviesRepo) StartMetadataRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				moviesRepo.RefreshMoviesMetadata(ctx)
+			}
+		}
+	}()
+}
+
+func (moviesRepo *MoviesRepo) RefreshMoviesMetadata(ctx context.Context) (int, map[string]interface{}) {
+	database := moviesRepo.database
+
+	// Get the movies with upcoming diffusions:
+	upcomingMovieIDs := database.Model(&models.Diffusion{}).
+		Select("movie_id").
+		Where("show_time > ?", time.Now())
+
+	var movies []models.Movie
+	err := database.Where("id IN (?)", upcomingMovieIDs).Find(&movies).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "GETTING_MOVIES_FAILED",
+		}
+	}
+
+	refreshedIDs := []uint{}
+	failedIDs := []uint{}
+	for i := range movies {
+		if ctx.Err() != nil {
+			break
+		}
+
+		err := moviesRepo.refreshMovieMetadata(ctx, &movies[i])
+		if err != nil {
+			log.Printf("refreshing movie %v failed: %v", movies[i].ID, err.Error())
+			failedIDs = append(failedIDs, movies[i].ID)
+			continue
+		}
+		refreshedIDs = append(refreshedIDs, movies[i].ID)
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"refreshedIDs": refreshedIDs,
+		"failedIDs":    failedIDs,
+	}
+}
+
+// BackfillTMDBIDs finds the TMDB IDs of the movies imported before they were
+// kept, so that their metadata is refreshed and they are not imported again.
+func (moviesRepo *MoviesRepo) BackfillTMDBIDs(ctx context.Context) {
+	database := moviesRepo.database
+
+	var movies []models.Movie
+	err := database.Where("tmdb_id IS NULL AND pic_url <> ?", "").Find(&movies).Error
+	if err != nil {
+		log.Printf("getting movies without tmdb id failed: %v", err.Error())
+		return
+	}
+
+	for _, movie := range movies {
+		if ctx.Err() != nil {
+			return
+		}
+		tmdbID, err := moviesRepo.findTMDBID(ctx, movie)
+		if err != nil {
+			log.Printf("finding movie %v tmdb id failed: %v", movie.ID, err.Error())
+			continue
+		}
+		if tmdbID == 0 {
+			continue
+		}
+		err = database.Model(&movie).Update("tmdb_id", tmdbID).Error
+		if err != nil {
+			log.Printf("backfilling movie %v tmdb id failed: %v", movie.ID, err.Error())
+		}
+	}
+}
+
+// findTMDBID searches TMDB for the movie by its original title. The result
+// with the same poster is the movie, or else the only one with the same
+// original title and language. It returns 0 when none matches.
+func (moviesRepo *MoviesRepo) findTMDBID(ctx context.Context, movie models.Movie) (uint, error) {
+	tmdbAPI := moviesRepo.tmdbAPI
+
+	var sameTitleIDs []int
+	for page := 1; page <= tmdbIDSearchPages; page++ {
+		results, err := tmdbAPI.SearchMovies(ctx, movie.Title, page)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, result := range results.Results {
+			if tmdbAPI.ImageURL(result.PosterPath) == movie.PicURL {
+				return uint(result.ID), nil
+			}
+			if result.OriginalTitle == movie.Title && strings.EqualFold(result.OriginalLanguage, movie.Language) {
+				sameTitleIDs = append(sameTitleIDs, result.ID)
+			}
+		}
+		if page >= results.TotalPages {
+			break
+		}
+	}
+
+	if len(sameTitleIDs) == 1 {
+		return uint(sameTitleIDs[0]), nil
+	}
+	return 0, nil
+}
+
+// refreshMovieMetadata updates the changed TMDB and YouTube fields, the
+// translations and the certifications of the movie, except the ones overridden
+// by an admin. Movies added by hand only have their trailer views refreshed.
+func (moviesRepo *MoviesRepo) refreshMovieMetadata(ctx context.Context, movie *models.Movie) error {
+	ctx, cancel := context.WithTimeout(ctx, metadataRefreshTimeout)
+	defer cancel()
+
+	updates := make(map[string]interface{})
+
+	// Get trailer views, the trailer itself may have been changed by an admin:
+	if videoID := youtubeVideoID(movie.TrailerURL); videoID != "" {
+		trailerViews, _, err := moviesRepo.getTrailerViews(ctx, videoID)
+		if err == nil && trailerViews != movie.TrailerViews {
+			updates["trailer_views"] = trailerViews
+		}
+	}
+
+	if movie.TMDBID != nil {
+		err := moviesRepo.refreshTMDBMetadata(ctx, movie, updates)
+		if err != nil {
+			return err
+		}
+	}
+
+	updates["last_synced_at"] = time.Now()
+	return moviesRepo.database.Model(movie).Updates(updates).Error
+}
+
+// refreshTMDBMetadata adds the changed TMDB fields to the updates, and
+// replaces the cast, the translations and the certifications of the movie.
+func (moviesRepo *MoviesRepo) refreshTMDBMetadata(ctx context.Context, movie *models.Movie, updates map[string]interface{}) error {
+	tmdbAPI := moviesRepo.tmdbAPI
+
+	details, err := tmdbAPI.GetMovie(ctx, int(*movie.TMDBID), "")
+	if err != nil {
+		return err
+	}
+
+	if details.OriginalTitle != "" && details.OriginalTitle != movie.Title && !movie.IsOverridden("title") {
+		updates["title"] = details.OriginalTitle
+	}
+	if details.Overview != "" && details.Overview != movie.Description && !movie.IsOverridden("description") {
+		updates["description"] = details.Overview
+	}
+	if details.OriginalLanguage != "" && details.OriginalLanguage != movie.Language && !movie.IsOverridden("language") {
+		updates["language"] = details.OriginalLanguage
+	}
+	picURL := tmdbAPI.ImageURL(details.PosterPath)
+	if picURL != "" && picURL != movie.PicURL && !movie.IsOverridden("picURL") {
+		updates["pic_url"] = picURL
+	}
+	if details.VoteAverage != movie.Rate {
+		updates["rate"] = details.VoteAverage
+	}
+	if uint(details.VoteCount) != movie.VoteCount {
+		updates["vote_count"] = uint(details.VoteCount)
+	}
+	if details.Runtime != nil && time.Duration(*details.Runtime)*time.Minute != movie.Duration {
+		updates["duration"] = time.Duration(*details.Runtime) * time.Minute
+	}
+
+	// Replace the cast:
+	cast, err := moviesRepo.getMovieCastFromTMDB(ctx, int(*movie.TMDBID))
+	if err != nil {
+		return err
+	}
+
+	var refreshedMovie models.Movie
+	for _, actor := range cast {
+		moviesRepo.addActorToMovie(&refreshedMovie, actor)
+	}
+
+	database := moviesRepo.database
+
+	if len(refreshedMovie.Cast) > 0 {
+		err = database.Model(movie).Association("Cast").Replace(refreshedMovie.Cast)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = moviesRepo.syncMovieTranslations(ctx, movie)
+	if err != nil {
+		return err
+	}
+
+	return moviesRepo.syncMovieCertifications(ctx, movie)
+}
+
+func youtubeVideoID(trailerURL string) string {
+	parsedURL, err := url.Parse(trailerURL)
+	if err != nil {
+		return ""
+	}
+
+	if parsedURL.Host == "youtu.be" {
+		return strings.TrimPrefix(parsedURL.Path, "/")
+	}
+	return parsedURL.Query().Get("v")
+}
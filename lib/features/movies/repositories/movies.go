@@ -1,11 +1,14 @@
 package movies
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,12 +16,13 @@ import (
 	mysql "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/mysql"
 	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
 	youtube "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/youtube"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
 	gorm "gorm.io/gorm"
 )
 
 type MoviesRepo struct {
 	database   *gorm.DB
-	tmdbAPI    tmdb.Config
+	tmdbAPI    *tmdb.Client
 	youtubeAPI youtube.Config
 }
 
@@ -30,7 +34,7 @@ func NewMoviesRepository() *MoviesRepo {
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetMoviesFromTMDB(query string, pageString string) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetMoviesFromTMDB(ctx context.Context, query string, pageString string) (int, map[string]interface{}) {
 
 	// Validate inputs:
 	if query == "" {
@@ -46,63 +50,30 @@ func (moviesRepo *MoviesRepo) GetMoviesFromTMDB(query string, pageString string)
 		}
 	}
 
-	if page == 0 {
-		page = 1
-	}
-
 	tmdbAPI := moviesRepo.tmdbAPI
 
 	// Make tmdb request:
-	url := fmt.Sprintf(
-		"%v/search/movie?query=%v&api_key=%v&page=%v",
-		tmdbAPI.BaseURL,
-		query,
-		tmdbAPI.ApiKey,
-		page,
-	)
-	response, err := http.Get(url)
+	body, err := tmdbAPI.SearchMovies(ctx, query, page)
 	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
+		return tmdbErrorStatus(err), map[string]interface{}{
 			"error": "QUERING_FAILED",
 		}
 	}
-	defer response.Body.Close()
-
-	var body map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&body)
-	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "DECODING_FAILED",
-		}
-	}
 
 	// Fill the result map
 	result := make(map[string]interface{})
-	result["page"] = body["page"]
-	result["totalPages"] = body["total_pages"]
-
-	APIMovies, ok := body["results"].([]interface{})
-	if !ok {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "RESULTS_CAST_FAILED",
-		}
-	}
+	result["page"] = body.Page
+	result["totalPages"] = body.TotalPages
 
 	var movies []map[string]interface{}
-	for _, apiMovieInterface := range APIMovies {
-		APIMovie, ok := apiMovieInterface.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
+	for _, APIMovie := range body.Results {
 		// Initialize the movie map
 		movie := make(map[string]interface{})
-		movie["tmdbID"] = APIMovie["id"]
-		movie["title"] = APIMovie["original_title"]
+		movie["tmdbID"] = APIMovie.ID
+		movie["title"] = APIMovie.OriginalTitle
 
-		posterPath, ok := APIMovie["poster_path"].(string)
-		if ok {
-			movie["picURL"] = tmdbAPI.ImagesBaseURL + posterPath
+		if picURL := tmdbAPI.ImageURL(APIMovie.PosterPath); picURL != "" {
+			movie["picURL"] = picURL
 		}
 
 		movies = append(movies, movie)
@@ -112,8 +83,9 @@ func (moviesRepo *MoviesRepo) GetMoviesFromTMDB(query string, pageString string)
 	return http.StatusOK, result
 }
 
-func (moviesRepo *MoviesRepo) GetMovieTrailersFromTMDB(tmdbID string) (int, map[string]interface{}) {
-	if tmdbID == "" {
+func (moviesRepo *MoviesRepo) GetMovieTrailersFromTMDB(ctx context.Context, tmdbIDString string) (int, map[string]interface{}) {
+	tmdbID, err := strconv.Atoi(tmdbIDString)
+	if err != nil || tmdbID <= 0 {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "INVALID_ID",
 		}
@@ -121,61 +93,27 @@ func (moviesRepo *MoviesRepo) GetMovieTrailersFromTMDB(tmdbID string) (int, map[
 
 	tmdbAPI := moviesRepo.tmdbAPI
 
-	// Build the API URL
-	url := fmt.Sprintf(
-		"%v/movie/%v/videos?api_key=%v",
-		tmdbAPI.BaseURL,
-		tmdbID,
-		tmdbAPI.ApiKey,
-	)
-
 	// Make the API request
-	response, err := http.Get(url)
-	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "QUERYING_FAILED",
-		}
-	}
-	defer response.Body.Close()
-
-	// Decode the response body
-	var body map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&body)
+	body, err := tmdbAPI.GetMovieVideos(ctx, tmdbID)
 	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
+		return tmdbErrorStatus(err), map[string]interface{}{
 			"error": "TRAILERS_FETCHING_FAILED",
 		}
 	}
 
 	result := make(map[string]interface{})
 
-	APITrailers, ok := body["results"].([]interface{})
-	if !ok {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "TRAILERS_CAST_FAILED",
-		}
-	}
-
 	var trailers []map[string]interface{}
-	for _, apiTrailerInterface := range APITrailers {
-		APITrailer, ok := apiTrailerInterface.(map[string]interface{})
-		if !ok {
+	for _, APITrailer := range body.Results {
+		if APITrailer.Site != "YouTube" || APITrailer.Key == "" {
 			continue
 		}
 
 		// Initialize the movie map:
 		trailer := make(map[string]interface{})
-
-		site := APITrailer["site"].(string)
-		if site == "YouTube" {
-			youtubeKey := APITrailer["key"].(string)
-			trailer["url"] = fmt.Sprintf("https://www.youtube.com/watch?v=%v", youtubeKey)
-		} else {
-			continue
-		}
-
-		trailer["title"] = APITrailer["name"]
-		trailer["isOfficial"] = APITrailer["official"]
+		trailer["url"] = fmt.Sprintf("https://www.youtube.com/watch?v=%v", APITrailer.Key)
+		trailer["title"] = APITrailer.Name
+		trailer["isOfficial"] = APITrailer.Official
 
 		trailers = append(trailers, trailer)
 	}
@@ -185,110 +123,104 @@ func (moviesRepo *MoviesRepo) GetMovieTrailersFromTMDB(tmdbID string) (int, map[
 	return http.StatusOK, result
 }
 
-func (moviesRepo *MoviesRepo) AddMovie(tmdbID string, trailerVideoID string, language string, po string) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) AddMovie(ctx context.Context, tmdbIDString string, trailerVideoID string, language string, po string) (int, map[string]interface{}) {
 	// Validate input arguments
-	if tmdbID == "" || trailerVideoID == "" || po == ""{
+	tmdbID, err := strconv.Atoi(tmdbIDString)
+	if err != nil || tmdbID <= 0 || trailerVideoID == "" || po == "" {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "INVALID_ARGS",
 		}
 	}
 
-	tmdbAPI := moviesRepo.tmdbAPI
-
-	// Build the API URL
-	url := fmt.Sprintf(
-		"%v/movie/%v?api_key=%v",
-		tmdbAPI.BaseURL,
-		tmdbID,
-		tmdbAPI.ApiKey,
-	)
-
-	// Make the API request
-	response, err := http.Get(url)
+	_, status, err := moviesRepo.importMovie(ctx, tmdbID, trailerVideoID, language, po)
 	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "QUERYING_FAILED",
+		return status, map[string]interface{}{
+			"error": err.Error(),
 		}
 	}
-	defer response.Body.Close()
 
-	// Decode the response body
-	var body map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&body)
+	return http.StatusOK, map[string]interface{}{
+		"message": "MOVIE_ADDED",
+	}
+}
+
+// importMovie creates the movie from its TMDB details, translations and cast,
+// unless it was already imported. The display language defaults to the
+// original language of the movie.
+func (moviesRepo *MoviesRepo) importMovie(ctx context.Context, tmdbID int, trailerVideoID string, language string, po string) (*models.Movie, int, error) {
+	tmdbAPI := moviesRepo.tmdbAPI
+
+	// Make the API request
+	body, err := tmdbAPI.GetMovie(ctx, tmdbID, "")
 	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "DECODING_FAILED",
-		}
+		return nil, tmdbErrorStatus(err), errors.New("QUERYING_FAILED")
 	}
 
-	// Get display language:
-	displayLanguage := body["original_language"].(string)
+	// Avoid importing the same movie twice:
+	if moviesRepo.isMovieImported(body.ID, tmdbAPI.ImageURL(body.PosterPath)) {
+		return nil, http.StatusConflict, errors.New("MOVIE_ALREADY_IMPORTED")
+	}
 
 	// Get Trailer:
 	trailerURL := fmt.Sprintf("https://www.youtube.com/watch?v=%v", trailerVideoID)
-	trailerViews, status, err := moviesRepo.getTrailerViews(trailerVideoID)
+	trailerViews, status, err := moviesRepo.getTrailerViews(ctx, trailerVideoID)
 	if err != nil {
-		return status, map[string]interface{}{
-			"error": err.Error(),
-		}
+		return nil, status, err
+	}
+
+	var duration time.Duration
+	if body.Runtime != nil {
+		duration = time.Duration(*body.Runtime) * time.Minute
 	}
 
 	// Create the movie object
+	syncedAt := time.Now()
+	movieTMDBID := uint(body.ID)
 	movie := models.Movie{
-		Title:        body["original_title"].(string),
-		Description:  body["overview"].(string),
-		Rate:         body["vote_average"].(float64),
+		TMDBID:       &movieTMDBID,
+		Title:        body.OriginalTitle,
+		Description:  body.Overview,
+		Rate:         body.VoteAverage,
 		TrailerURL:   trailerURL,
 		TrailerViews: trailerViews,
-		Duration:     time.Duration(body["runtime"].(float64) * float64(time.Minute)), // Change uint to float64 for runtime
-		VoteCount:    uint(body["vote_count"].(float64)),                              // Change uint to float64 for vote_count
-		PicURL:       tmdbAPI.ImagesBaseURL + body["poster_path"].(string),
+		Duration:     duration,
+		VoteCount:    uint(body.VoteCount),
+		PicURL:       tmdbAPI.ImageURL(body.PosterPath),
 		PO:           po,
-		Language:     displayLanguage,
+		Language:     body.OriginalLanguage,
+		LastSyncedAt: &syncedAt,
+	}
+	if language = strings.ToLower(strings.TrimSpace(language)); language != "" {
+		movie.Language = language
+		movie.AddOverride("language")
 	}
 
 	database := moviesRepo.database
 
 	// Get and store types
-	APITypes, ok := body["genres"].([]interface{})
-	if !ok {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "TYPES_CAST_FAILED",
-		}
-	}
-
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	wg.Add(len(APITypes))
-	for _, apiTypeInterface := range APITypes {
-		APIType, ok := apiTypeInterface.(map[string]interface{})
-		if !ok {
-			wg.Done()
+	for _, APIType := range body.Genres {
+		if APIType.Name == "" {
 			continue
 		}
 
 		// Add the movie type to the slice
-		movieType, ok := APIType["name"].(string)
-		if ok {
-			go func() {
-				defer wg.Done()
-				mu.Lock()
-				moviesRepo.addMovieType(&movie, movieType)
-				mu.Unlock()
-			}()
-		} else {
-			wg.Done()
-			continue
-		}
+		movieType := APIType
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.Lock()
+			moviesRepo.addMovieType(&movie, movieType)
+			mu.Unlock()
+		}()
 	}
 	wg.Wait()
 
 	// Get and add Cast:
-	cast, err := moviesRepo.getMovieCastFromTMDB(tmdbID)
+	cast, err := moviesRepo.getMovieCastFromTMDB(ctx, tmdbID)
 	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": err.Error(),
-		}
+		return nil, tmdbErrorStatus(err), errors.New("CAST_FETCHING_FAILED")
 	}
 
 	wg.Add(len(cast))
@@ -304,18 +236,47 @@ func (moviesRepo *MoviesRepo) AddMovie(tmdbID string, trailerVideoID string, lan
 	wg.Wait()
 
 	err = database.Create(&movie).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// Imported meanwhile by another request:
+		return nil, http.StatusConflict, errors.New("MOVIE_ALREADY_IMPORTED")
+	}
 	if err != nil {
-		return http.StatusInternalServerError, map[string]interface{}{
-			"error": "ERROR_ADDING_MOVIE",
-		}
+		return nil, http.StatusInternalServerError, errors.New("ERROR_ADDING_MOVIE")
 	}
 
-	return http.StatusOK, map[string]interface{}{
-		"message": "MOVIE_ADDED",
+	// Translations and certifications are not required to show the movie:
+	err = moviesRepo.syncMovieTranslations(ctx, &movie)
+	if err != nil {
+		log.Printf("fetching movie %v translations failed: %v", movie.ID, err.Error())
+	}
+	err = moviesRepo.syncMovieCertifications(ctx, &movie)
+	if err != nil {
+		log.Printf("fetching movie %v certifications failed: %v", movie.ID, err.Error())
+	}
+
+	return &movie, http.StatusOK, nil
+}
+
+// isMovieImported tells whether the TMDB movie was imported. The movies
+// imported before their TMDB ID was kept are matched on their poster, until
+// their ID is backfilled.
+func (moviesRepo *MoviesRepo) isMovieImported(tmdbID int, picURL string) bool {
+	query := moviesRepo.database.Model(&models.Movie{}).Where("tmdb_id = ?", tmdbID)
+	if picURL != "" {
+		query = query.Or("tmdb_id IS NULL AND pic_url = ?", picURL)
 	}
+
+	var count int64
+	query.Count(&count)
+	return count > 0
 }
 
-func (moviesRepo *MoviesRepo) getTrailerViews(videoID string) (uint, int, error) {
+// YouTube is only asked for the views of trailers:
+var youtubeClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+func (moviesRepo *MoviesRepo) getTrailerViews(ctx context.Context, videoID string) (uint, int, error) {
 	type YouTubeResponse struct {
 		Items []struct {
 			Statistics struct {
@@ -326,8 +287,11 @@ func (moviesRepo *MoviesRepo) getTrailerViews(videoID string) (uint, int, error)
 
 	youtubeAPI := moviesRepo.youtubeAPI
 	url := fmt.Sprintf("%v/videos?part=statistics&id=%s&key=%s", youtubeAPI.BaseURL, videoID, youtubeAPI.ApiKey)
-	fmt.Println(url)
-	resp, err := http.Get(url)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, http.StatusInternalServerError, errors.New("FETCHING_TRAILER_DATA_FAILED")
+	}
+	resp, err := youtubeClient.Do(request)
 	if err != nil {
 		return 0, http.StatusInternalServerError, errors.New("FETCHING_TRAILER_DATA_FAILED")
 	}
@@ -350,60 +314,25 @@ func (moviesRepo *MoviesRepo) getTrailerViews(videoID string) (uint, int, error)
 	return uint(viewCount), http.StatusOK, nil
 }
 
-func (moviesRepo *MoviesRepo) getMovieCastFromTMDB(tmdbID string) ([]models.Actor, error) {
+func (moviesRepo *MoviesRepo) getMovieCastFromTMDB(ctx context.Context, tmdbID int) ([]models.Actor, error) {
 	tmdbAPI := moviesRepo.tmdbAPI
 
-	// Build the API URL
-	url := fmt.Sprintf(
-		"%v/movie/%v/credits?api_key=%v",
-		tmdbAPI.BaseURL,
-		tmdbID,
-		tmdbAPI.ApiKey,
-	)
-
-	fmt.Println(url)
-
 	// Make the API request
-	response, err := http.Get(url)
-	if err != nil {
-		return nil, errors.New("CAST_FETCHING_FAILED")
-	}
-	defer response.Body.Close()
-
-	// Decode the response body
-	var body map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&body)
+	body, err := tmdbAPI.GetMovieCredits(ctx, tmdbID)
 	if err != nil {
-		return nil, errors.New("CAST_FETCHING_FAILED")
-	}
-
-	APICast, ok := body["cast"].([]interface{})
-	if !ok {
-		return nil, errors.New("CAST_CAST_FAILED")
+		return nil, err
 	}
 
 	var cast []models.Actor
-	for _, apiActorInterface := range APICast {
-		APIActor, ok := apiActorInterface.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		actorPicURL, ok := APIActor["profile_path"].(string)
-		if !ok || actorPicURL == "" {
-			actorPicURL = ""
-		} else {
-			actorPicURL = tmdbAPI.ImagesBaseURL + actorPicURL
-		}
-
-		actorName, ok := APIActor["name"].(string)
-		if !ok {
+	for _, APIActor := range body.Cast {
+		if APIActor.Name == "" {
 			continue
 		}
 
 		actor := models.Actor{
-			Name:   actorName,
-			PicURL: actorPicURL,
+			TMDBID: uint(APIActor.ID),
+			Name:   APIActor.Name,
+			PicURL: tmdbAPI.ImageURL(APIActor.ProfilePath),
 		}
 
 		cast = append(cast, actor)
@@ -412,18 +341,37 @@ func (moviesRepo *MoviesRepo) getMovieCastFromTMDB(tmdbID string) ([]models.Acto
 	return cast, nil
 }
 
-func (moviesRepo *MoviesRepo) addMovieType(movie *models.Movie, typeName string) error {
+// tmdbErrorStatus maps the tmdb client errors to the response status.
+func tmdbErrorStatus(err error) int {
+	var apiError *tmdb.APIError
+	switch {
+	case errors.Is(err, tmdb.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &apiError):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (moviesRepo *MoviesRepo) addMovieType(movie *models.Movie, genre tmdb.Genre) error {
 	var movieType models.Type
 	database := moviesRepo.database
-	if err := database.Where("name = ?", typeName).First(&movieType).Error; err != nil {
+	if err := database.Where("tmdb_id = ? OR name = ?", genre.ID, genre.Name).First(&movieType).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			movieType = models.Type{
-				Name: typeName,
+				TMDBID: uint(genre.ID),
+				Name:   genre.Name,
 			}
 			if err := database.Create(&movieType).Error; err != nil {
 				return errors.New("ADDING_TYPE_FAILED")
 			}
 		}
+	} else if movieType.TMDBID == 0 {
+		// Types added before TMDB ids were stored:
+		database.Model(&movieType).Update("tmdb_id", genre.ID)
 	}
 	movie.Type = append(movie.Type, movieType)
 	return nil
@@ -431,18 +379,38 @@ func (moviesRepo *MoviesRepo) addMovieType(movie *models.Movie, typeName string)
 
 func (moviesRepo *MoviesRepo) addActorToMovie(movie *models.Movie, actor models.Actor) error {
 	database := moviesRepo.database
-	if err := database.Where("name = ?", actor.Name).First(&actor).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			if err := database.Create(&actor).Error; err != nil {
-				return errors.New("ADDING_ACTOR_FAILED")
-			}
+
+	// The TMDB id tells apart the actors whose names are spelled differently:
+	var existingActor models.Actor
+	err := gorm.ErrRecordNotFound
+	if actor.TMDBID != 0 {
+		err = database.Where("tmdb_id = ?", actor.TMDBID).First(&existingActor).Error
+	}
+	if err == gorm.ErrRecordNotFound {
+		// Actors added before TMDB ids were stored are matched on their name,
+		// but not the homonyms with another TMDB id:
+		query := database.Where("name = ?", actor.Name)
+		if actor.TMDBID != 0 {
+			query = query.Where("tmdb_id IS NULL OR tmdb_id = 0")
+		}
+		err = query.First(&existingActor).Error
+		if err == nil && actor.TMDBID != 0 {
+			database.Model(&existingActor).Update("tmdb_id", actor.TMDBID)
+		}
+	}
+
+	if err == nil {
+		actor = existingActor
+	} else if err == gorm.ErrRecordNotFound {
+		if err := database.Create(&actor).Error; err != nil {
+			return errors.New("ADDING_ACTOR_FAILED")
 		}
 	}
 	movie.Cast = append(movie.Cast, actor)
 	return nil
 }
 
-func (moviesRepo *MoviesRepo) GetMovie(ID string) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetMovie(ID string, locale string) (int, map[string]interface{}) {
 	if ID == "" {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "INVALID_ID",
@@ -459,25 +427,41 @@ func (moviesRepo *MoviesRepo) GetMovie(ID string) (int, map[string]interface{})
 		}
 	}
 
+	moviesRepo.translateMovies([]*models.Movie{&movie}, locale)
+
 	return http.StatusOK, map[string]interface{}{
 		"movie": movie,
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetMovies() (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetMovies(listing tools.ListingQuery, locale string) (int, map[string]interface{}) {
+	if err := listing.Validate(moviesListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
 	var movies []models.Movie
 	database := moviesRepo.database
 
-	err := database.Preload("Type").Find(&movies).Error
+	page, err := tools.FindPage(database.Model(&models.Movie{}).Preload("Type"), &movies, listing)
 	if err != nil {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "FOUNDING_MOVIE_FAILED",
 		}
 	}
 
+	moviesToTranslate := make([]*models.Movie, len(movies))
+	for index := range movies {
+		moviesToTranslate[index] = &movies[index]
+	}
+	moviesRepo.translateMovies(moviesToTranslate, locale)
+
 	return http.StatusOK, map[string]interface{}{
-		"count":  len(movies),
-		"movies": movies,
+		"count":      len(movies),
+		"total":      page.Total,
+		"nextCursor": page.NextCursor,
+		"movies":     tools.SelectFields(movies, listing.Fields),
 	}
 }
 
@@ -502,24 +486,56 @@ func (moviesRepo *MoviesRepo) UpdateMovie(newMovie models.Movie) (int, map[strin
 	// Updating movie
 	if newMovie.Title != "" {
 		movie.Title = newMovie.Title
+		movie.AddOverride("title")
 	}
 	if newMovie.Description != "" {
 		movie.Description = newMovie.Description
+		movie.AddOverride("description")
 	}
 	if newMovie.TrailerURL != "" {
 		movie.TrailerURL = newMovie.TrailerURL
+		movie.AddOverride("trailerURL")
 	}
 	if newMovie.PicURL != "" {
 		movie.PicURL = newMovie.PicURL
+		movie.AddOverride("picURL")
 	}
 	if newMovie.Language != "" {
 		movie.Language = newMovie.Language
+		movie.AddOverride("language")
 	}
 	if newMovie.PO != "" {
 		movie.PO = newMovie.PO
 	}
+	// The certification shown on the movie is the one of the default country:
+	var certification *models.MovieCertification
+	if newMovie.Certification != "" {
+		certification = &models.MovieCertification{
+			MovieID:       movie.ID,
+			Country:       models.DefaultCertificationCountry,
+			Certification: newMovie.Certification,
+			MinimumAge:    newMovie.MinimumAge,
+		}
+		if err := certification.Validate(); err != nil {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			}
+		}
+		movie.Certification = certification.Certification
+		movie.MinimumAge = certification.MinimumAge
+		movie.AddOverride("certification")
+	}
 
-	err = database.Save(&movie).Error
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&movie).Error; err != nil {
+			return err
+		}
+		if certification == nil {
+			return nil
+		}
+		_, err := saveAdminCertification(tx, *certification)
+		return err
+	})
 	if err != nil {
 		return http.StatusInternalServerError, map[string]interface{}{
 			"error": "UPDATING_MOVIE_FAILED",
@@ -531,16 +547,29 @@ func (moviesRepo *MoviesRepo) UpdateMovie(newMovie models.Movie) (int, map[strin
 	}
 }
 
-func (moviesRepo *MoviesRepo) AddHall(hall models.Hall) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) AddHall(hall models.Hall, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
 	if err := hall.ValidateHall(); err != nil {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": err.Error(),
 		}
 	}
+	if !cinemaScope.Includes(hall.CinemaID) {
+		return http.StatusForbidden, map[string]interface{}{
+			"error": "CINEMA_ACCESS_DENIED",
+		}
+	}
 
 	database := moviesRepo.database
 
-	err := database.Create(&hall).Error
+	err := database.Where("id = ?", hall.CinemaID).First(&models.Cinema{}).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "CINEMA_NOT_FOUND",
+		}
+	}
+
+	hall.Cinema = nil
+	err = database.Create(&hall).Error
 	if err != nil {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "HALL_CREATION_FAILED",
@@ -552,7 +581,7 @@ func (moviesRepo *MoviesRepo) AddHall(hall models.Hall) (int, map[string]interfa
 	}
 }
 
-func (moviesRepo *MoviesRepo) AddDiffusion(diffuion models.Diffusion) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) AddDiffusion(diffuion models.Diffusion, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
 	err := diffuion.Validate()
 	if err != nil {
 		return http.StatusBadRequest, map[string]interface{}{
@@ -576,36 +605,42 @@ func (moviesRepo *MoviesRepo) AddDiffusion(diffuion models.Diffusion) (int, map[
 		}
 	}
 
-	var hall models.Hall
-	err = database.Where("id = ?", hallID).First(&hall).Error
+	hall, status, err := moviesRepo.getManagedHall(hallID, cinemaScope)
 	if err != nil {
-		return http.StatusBadRequest, map[string]interface{}{
-			"error": "HALL_NOT_FOUND",
+		return status, map[string]interface{}{
+			"error": err.Error(),
 		}
 	}
-
-	var seats []models.Seat
-	for i := 0; i < int(hall.RowsCount); i++ {
-		rowLetter := rune('A' + i)
-		for j := 0; j < int(hall.ColumnsCount); j++ {
-			column := j + 1
-			seats = append(seats, models.Seat{
-				SeatRow:    string(rowLetter),
-				SeatColumn: column,
-				Status:     "availble",
-			})
+	if !hall.SupportsFormat(diffuion.Format) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "FORMAT_NOT_SUPPORTED_BY_HALL",
 		}
 	}
 
 	diffusion := models.Diffusion{
-		MovieID:      movie.ID,
-		ShowTime:     showTime,
-		ShowDuration: showDuration,
-		HallID:       hallID,
-		SeatPrice:    seatPrice,
-		SeatsStatus:  seats,
+		MovieID:          movie.ID,
+		ShowTime:         showTime,
+		ShowDuration:     showDuration,
+		HallID:           hallID,
+		SeatPrice:        seatPrice,
+		DiffusionVersion: diffuion.DiffusionVersion,
+	}
+
+	conflicts, _, err := findScheduleConflicts(database, []models.Diffusion{diffusion}, nil)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "CHECKING_SCHEDULE_FAILED",
+		}
+	}
+	if len(conflicts) > 0 {
+		return http.StatusConflict, map[string]interface{}{
+			"error":     errScheduleConflicts.Error(),
+			"conflicts": conflicts,
+		}
 	}
 
+	diffusion.SeatsStatus = newHallSeats(hall)
+
 	movie.Diffusions = append(movie.Diffusions, diffusion)
 
 	err = database.Save(&movie).Error
@@ -620,11 +655,41 @@ func (moviesRepo *MoviesRepo) AddDiffusion(diffuion models.Diffusion) (int, map[
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetHalls() (int, map[string]interface{}) {
+func newHallSeats(hall models.Hall) []models.Seat {
+	var seats []models.Seat
+	for i := 0; i < int(hall.RowsCount); i++ {
+		rowLetter := rune('A' + i)
+		for j := 0; j < int(hall.ColumnsCount); j++ {
+			column := j + 1
+			seats = append(seats, models.Seat{
+				SeatRow:    string(rowLetter),
+				SeatColumn: column,
+				Status:     "availble",
+			})
+		}
+	}
+	return seats
+}
+
+func (moviesRepo *MoviesRepo) GetHalls(cinemaID uint, cinemaScope tools.CinemaScope, listing tools.ListingQuery) (int, map[string]interface{}) {
+	if err := listing.Validate(hallsListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
 	database := moviesRepo.database
 
+	query := database.Model(&models.Hall{})
+	if cinemaID != 0 {
+		query = query.Where("cinema_id = ?", cinemaID)
+	}
+	if !cinemaScope.IsSuperAdmin {
+		query = query.Where("cinema_id in ?", cinemaScope.CinemaIDs)
+	}
+
 	var halls []models.Hall
-	err := database.Find(&halls).Error
+	page, err := tools.FindPage(query, &halls, listing)
 	if err != nil {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "FETCHING_HALLS_FAILED",
@@ -632,8 +697,10 @@ func (moviesRepo *MoviesRepo) GetHalls() (int, map[string]interface{}) {
 	}
 
 	return http.StatusOK, map[string]interface{}{
-		"count": len(halls),
-		"halls": halls,
+		"count":      len(halls),
+		"total":      page.Total,
+		"nextCursor": page.NextCursor,
+		"halls":      tools.SelectFields(halls, listing.Fields),
 	}
 }
 
@@ -656,21 +723,22 @@ func (moviesRepo *MoviesRepo) DeleteMovie(id string) (int, map[string]interface{
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetAllWeeksUntilNextYear() (int, map[string]interface{}) {
-	weeks := getAllWeeksUntilNextYear()
+func (moviesRepo *MoviesRepo) GetAllWeeksUntilNextYear(cinemaID uint) (int, map[string]interface{}) {
+	weeks := getAllWeeksUntilNextYear(moviesRepo.getCinemaLocation(cinemaID))
 	return http.StatusOK, map[string]interface{}{
 		"count": len(weeks),
 		"weeks": weeks,
 	}
 }
 
-func getAllWeeksUntilNextYear() []models.Week {
-	now := time.Now()
-	nextYearSameDay := time.Date(now.Year()+1, now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+// getAllWeeksUntilNextYear lists the Sunday to Saturday weeks of the coming
+// year, with their boundaries at midnight in the given location.
+func getAllWeeksUntilNextYear(location *time.Location) []models.Week {
+	now := time.Now().In(location)
+	nextYearSameDay := now.AddDate(1, 0, 0)
 
 	weeks := []models.Week{}
-	for current := now; current.Before(nextYearSameDay); current = current.AddDate(0, 0, 7) {
-		weekStart := current.AddDate(0, 0, -int(current.Weekday()))
+	for weekStart := tools.WeekStart(now, location); weekStart.Before(nextYearSameDay); weekStart = weekStart.AddDate(0, 0, 7) {
 		weekEnd := weekStart.AddDate(0, 0, 6)
 		weeks = append(weeks, models.Week{
 			FromDate: weekStart,
@@ -681,7 +749,19 @@ func getAllWeeksUntilNextYear() []models.Week {
 	return weeks
 }
 
-func (moviesRepo *MoviesRepo) GetDiffusionsForAdmin(startDate time.Time, endDate time.Time, hallID uint) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetDiffusionsForAdmin(startDate time.Time, endDate time.Time, hallID uint, cinemaScope tools.CinemaScope, locale string) (int, map[string]interface{}) {
+	hall, status, err := moviesRepo.getManagedHall(hallID, cinemaScope)
+	if err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	// The week is read in the time zone of the hall:
+	location := hall.Location()
+	startDate, _ = tools.DayBounds(startDate, location)
+	endDate, _ = tools.DayBounds(endDate, location)
+
 	if startDate.Weekday() != time.Sunday {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "START_DATE_MUST_BE_SUNDAY",
@@ -698,10 +778,10 @@ func (moviesRepo *MoviesRepo) GetDiffusionsForAdmin(startDate time.Time, endDate
 	database := moviesRepo.database
 
 	var diffusions []models.Diffusion
-	endDate = endDate.Add(24 * time.Hour)
-	err := database.Where("show_time between ? AND ? and hall_id = ?", startDate, endDate, hallID).
+	endDate = endDate.AddDate(0, 0, 1)
+	err = database.Where("show_time >= ? AND show_time < ? and hall_id = ?", startDate, endDate, hallID).
 		Preload("Movie", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id", "title")
+			return db.Select("id", "title", "certification", "minimum_age")
 		}).Find(&diffusions).Error
 	if err != nil {
 		return http.StatusInternalServerError, map[string]interface{}{
@@ -709,35 +789,69 @@ func (moviesRepo *MoviesRepo) GetDiffusionsForAdmin(startDate time.Time, endDate
 		}
 	}
 
+	moviesRepo.translateDiffusions(diffusions, locale)
+
 	// Group diffusions by day of the week
 	groupedDiffusions := make(map[string][]map[string]interface{})
 	for _, diffusion := range diffusions {
-		dayOfWeek := diffusion.ShowTime.Weekday().String()
+		localShowTime := diffusion.ShowTime.In(location)
+		dayOfWeek := localShowTime.Weekday().String()
 		if groupedDiffusions[dayOfWeek] == nil {
 			groupedDiffusions[dayOfWeek] = []map[string]interface{}{}
 		}
 
-		fromHour := diffusion.ShowTime.Format("15:04:05")
-		toHour := diffusion.ShowTime.Add(diffusion.ShowDuration).Format("15:04:05")
+		fromHour := localShowTime.Format("15:04:05")
+		toHour := localShowTime.Add(diffusion.ShowDuration).Format("15:04:05")
 		groupedDiffusions[dayOfWeek] = append(groupedDiffusions[dayOfWeek], map[string]interface{}{
-			"id":        diffusion.ID,
-			"movieID":   diffusion.MovieID,
-			"title":     diffusion.Movie.Title,
-			"startHour": fromHour,
-			"endHour":   toHour,
+			"id":                  diffusion.ID,
+			"movieID":             diffusion.MovieID,
+			"title":               diffusion.Movie.Title,
+			"certification":       diffusion.Movie.Certification,
+			"showTime":            diffusion.ShowTime.UTC(),
+			"localShowTime":       localShowTime,
+			"startHour":           fromHour,
+			"endHour":             toHour,
+			"seriesID":            diffusion.SeriesID,
+			"maintenanceConflict": diffusion.MaintenanceConflict,
 		})
 	}
 
 	return http.StatusOK, map[string]interface{}{
 		"count":      len(diffusions),
+		"timezone":   location.String(),
 		"diffusions": groupedDiffusions,
 	}
 }
 
-func (moviesRepo *MoviesRepo) DeleteDiffusion(id string) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) DeleteDiffusion(id string, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
 	database := moviesRepo.database
 
-	err := database.Unscoped().Delete(&models.Diffusion{}, id).Error
+	var diffusion models.Diffusion
+	err := database.Where("id = ?", id).First(&diffusion).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "DIFFUSION_NOT_FOUND",
+		}
+	}
+	if _, status, err := moviesRepo.getManagedHall(diffusion.HallID, cinemaScope); err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	// Its reservations go with it, so do their notifications not sent yet:
+	err = database.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Notification{}).
+			Where("status = ? AND reservation_id IN (?)",
+				models.NotificationPending,
+				tx.Unscoped().Model(&models.Reservation{}).Select("id").Where("diffusion_id = ?", diffusion.ID),
+			).
+			Update("status", models.NotificationCanceled).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Diffusion{}, diffusion.ID).Error
+	})
 	if err != nil {
 		return http.StatusInternalServerError, map[string]interface{}{
 			"error": "DELETING_DIFFUSION_FAILED",
@@ -749,11 +863,13 @@ func (moviesRepo *MoviesRepo) DeleteDiffusion(id string) (int, map[string]interf
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetTopDiffusion() (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetTopDiffusion(userID uint, cinemaID uint, locale string) (int, map[string]interface{}) {
 	database := moviesRepo.database
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
 
 	var diffusion models.Diffusion
-	err := database.Joins("JOIN movies ON movies.id = diffusions.movie_id").
+	err := database.Scopes(moviesRepo.diffusionsInCinema(cinemaID)).
+		Joins("JOIN movies ON movies.id = diffusions.movie_id").
 		Order("rate desc").
 		Preload("Movie").
 		First(&diffusion).Error
@@ -763,32 +879,56 @@ func (moviesRepo *MoviesRepo) GetTopDiffusion() (int, map[string]interface{}) {
 		}
 	}
 
+	diffusions := []models.Diffusion{diffusion}
+	moviesRepo.localizeDiffusions(diffusions)
+	moviesRepo.priceDiffusions(diffusions)
+	moviesRepo.translateDiffusions(diffusions, locale)
+	diffusion = diffusions[0]
+
 	return http.StatusOK, map[string]interface{}{
 		"diffusion": diffusion,
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetDiffusionsByDay(day time.Time) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetDiffusionsByDay(day time.Time, userID uint, cinemaID uint, version models.DiffusionVersion, locale string) (int, map[string]interface{}) {
+	if err := version.ValidateFilter(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
 	database := moviesRepo.database
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
 
-	startDate := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
-	endDate := startDate.AddDate(0, 0, 1)
+	// The day is sliced in the time zone of the cinema:
+	location := day.Location()
+	if cinemaID != 0 {
+		location = moviesRepo.getCinemaLocation(cinemaID)
+	}
+	startDate, endDate := tools.DayBounds(day, location)
 
 	var diffusions []models.Diffusion
-	err := database.Preload("Movie").Where("show_time between ? and ?", startDate, endDate).Find(&diffusions).Error
+	err := database.Scopes(moviesRepo.diffusionsInCinema(cinemaID), moviesRepo.diffusionsWithVersion(version)).
+		Preload("Movie").
+		Where("show_time >= ? and show_time < ?", startDate, endDate).
+		Find(&diffusions).Error
 	if err != nil {
 		return http.StatusInternalServerError, map[string]interface{}{
 			"error": "FETCHING_DIFFUSIONS_FAILED",
 		}
 	}
 
+	moviesRepo.localizeDiffusions(diffusions)
+	moviesRepo.priceDiffusions(diffusions)
+	moviesRepo.translateDiffusions(diffusions, locale)
+
 	return http.StatusOK, map[string]interface{}{
 		"count":      len(diffusions),
 		"diffusions": diffusions,
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetMostPopularDiffusionsTrailers(trailersCount string) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetMostPopularDiffusionsTrailers(trailersCount string, userID uint, cinemaID uint, locale string) (int, map[string]interface{}) {
 	count, err := strconv.Atoi(trailersCount)
 	if err != nil || count <= 0 {
 		return http.StatusBadRequest, map[string]interface{}{
@@ -798,8 +938,11 @@ func (moviesRepo *MoviesRepo) GetMostPopularDiffusionsTrailers(trailersCount str
 
 	database := moviesRepo.database
 
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
+
 	var diffusions []models.Diffusion
-	err = database.Joins("join movies on diffusions.movie_id = movies.id").
+	err = database.Scopes(moviesRepo.diffusionsInCinema(cinemaID)).
+		Joins("join movies on diffusions.movie_id = movies.id").
 		Preload("Movie").
 		Order("movies.trailer_views DESC").
 		Limit(count).
@@ -811,43 +954,74 @@ func (moviesRepo *MoviesRepo) GetMostPopularDiffusionsTrailers(trailersCount str
 		}
 	}
 
+	moviesRepo.localizeDiffusions(diffusions)
+	moviesRepo.priceDiffusions(diffusions)
+	moviesRepo.translateDiffusions(diffusions, locale)
+
 	return http.StatusOK, map[string]interface{}{
 		"count":      len(diffusions),
 		"diffusions": diffusions,
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetDiffusionsForUsers() (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetDiffusionsForUsers(userID uint, cinemaID uint, version models.DiffusionVersion, listing tools.ListingQuery, locale string) (int, map[string]interface{}) {
+	if err := version.ValidateFilter(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	if err := listing.Validate(diffusionsListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
 	database := moviesRepo.database
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
 
 	var diffusions []models.Diffusion
-	err := database.Preload("Movie").Find(&diffusions).Error
+	query := database.Model(&models.Diffusion{}).
+		Scopes(moviesRepo.diffusionsInCinema(cinemaID), moviesRepo.diffusionsWithVersion(version)).
+		Preload("Movie")
+	page, err := tools.FindPage(query, &diffusions, listing)
 	if err != nil {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "FATCHING_DIFFUSIONS_FAILED",
 		}
 	}
 
+	moviesRepo.localizeDiffusions(diffusions)
+	moviesRepo.priceDiffusions(diffusions)
+	moviesRepo.translateDiffusions(diffusions, locale)
+
 	return http.StatusOK, map[string]interface{}{
 		"count":      len(diffusions),
-		"diffusions": diffusions,
+		"total":      page.Total,
+		"nextCursor": page.NextCursor,
+		"diffusions": tools.SelectFields(diffusions, listing.Fields),
 	}
 }
 
-func (moviesRepo *MoviesRepo) GetMoviesDiffusions(id string) (int, map[string]interface{}) {
+func (moviesRepo *MoviesRepo) GetMoviesDiffusions(id string, userID uint, cinemaID uint, version models.DiffusionVersion, locale string) (int, map[string]interface{}) {
 	if id == "" {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "INVALID_ID",
 		}
 	}
+	if err := version.ValidateFilter(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
 
 	var movie models.Movie
 	database := moviesRepo.database
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
 
 	err := database.Preload("Type").
 		Preload("Cast").
 		Preload("Diffusions", func(db *gorm.DB) *gorm.DB {
-			return db.Order("diffusions.show_time ASC")
+			return db.Scopes(moviesRepo.diffusionsInCinema(cinemaID), moviesRepo.diffusionsWithVersion(version)).Order("diffusions.show_time ASC")
 		}).
 		Where("id = ?", id).
 		First(&movie).Error
@@ -857,6 +1031,10 @@ func (moviesRepo *MoviesRepo) GetMoviesDiffusions(id string) (int, map[string]in
 		}
 	}
 
+	moviesRepo.localizeDiffusions(movie.Diffusions)
+	moviesRepo.priceDiffusions(movie.Diffusions)
+	moviesRepo.translateMovies([]*models.Movie{&movie}, locale)
+
 	return http.StatusOK, map[string]interface{}{
 		"movie": movie,
 	}
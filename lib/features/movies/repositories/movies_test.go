@@ -0,0 +1,155 @@
+package movies
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
+	youtube "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/youtube"
+	sqlite "github.com/glebarez/sqlite"
+	gorm "gorm.io/gorm"
+	logger "gorm.io/gorm/logger"
+)
+
+// newTestDatabase returns an in memory database with the tables of the movie
+// import.
+func newTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%v?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	database, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger:         logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	err = database.AutoMigrate(
+		&models.Actor{},
+		&models.Type{},
+		&models.Movie{},
+		&models.MovieTranslation{},
+		&models.TypeTranslation{},
+		&models.MovieCertification{},
+		&models.ImportJob{},
+		&models.ImportJobItem{},
+	)
+	if err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	return database
+}
+
+// newFakeYouTube answers the statistics of every video with the given views.
+func newFakeYouTube(t *testing.T, viewCount string) youtube.Config {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeFakeResponse(w, http.StatusOK, map[string]any{
+			"items": []map[string]any{
+				{"statistics": map[string]any{"viewCount": viewCount}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return youtube.Config{ApiKey: "fake", BaseURL: server.URL}
+}
+
+func TestImportMovie(t *testing.T) {
+	fake := newFakeTMDB()
+	defer fake.Close()
+	posterPath := "/dune.jpg"
+	runtime := 155
+	fake.addMovie(fakeMovie{
+		Details: tmdb.MovieDetails{
+			ID:               438631,
+			Title:            "Dune",
+			OriginalTitle:    "Dune",
+			OriginalLanguage: "en",
+			Overview:         "Paul Atreides leads nomadic tribes.",
+			PosterPath:       &posterPath,
+			Runtime:          &runtime,
+			VoteAverage:      7.8,
+			VoteCount:        12000,
+			Genres:           []tmdb.Genre{{ID: 878, Name: "Science Fiction"}},
+		},
+		Videos: []tmdb.Video{
+			{Key: "teaser", Site: "YouTube", Type: "Teaser", Official: true, Size: 1080},
+			{Key: "official", Site: "YouTube", Type: "Trailer", Official: true, Size: 1080},
+		},
+		Cast: []tmdb.CastMember{
+			{ID: 1190668, Name: "Timothée Chalamet"},
+			{ID: 505710, Name: "Zendaya"},
+		},
+		ReleaseDates: []tmdb.CountryReleaseDates{
+			{
+				Country:      "US",
+				ReleaseDates: []tmdb.ReleaseDate{{Certification: "PG-13", Type: tmdb.ReleaseTypeTheatrical}},
+			},
+			{
+				Country:      "FR",
+				ReleaseDates: []tmdb.ReleaseDate{{Certification: "12", Type: tmdb.ReleaseTypeDigital}, {Certification: "TP", Type: tmdb.ReleaseTypeTheatrical}},
+			},
+		},
+	})
+
+	database := newTestDatabase(t)
+	moviesRepo := newFakeMoviesRepo(fake)
+	moviesRepo.database = database
+	moviesRepo.youtubeAPI = newFakeYouTube(t, "42000")
+
+	item := models.ImportJobItem{ImportJobID: 1, TMDBID: 438631, Status: models.ImportItemPending}
+	database.Create(&item)
+	moviesRepo.runImportJobItem(context.Background(), &item, "admin")
+
+	if item.Status != models.ImportItemImported || item.MovieID == nil || item.TrailerVideoID != "official" {
+		t.Fatalf("item = %+v, want imported with the official trailer", item)
+	}
+
+	var movie models.Movie
+	err := database.Preload("Cast").Preload("Type").Preload("Certifications").First(&movie, *item.MovieID).Error
+	if err != nil {
+		t.Fatalf("getting the imported movie: %v", err)
+	}
+	if movie.TMDBID == nil || *movie.TMDBID != 438631 || movie.Title != "Dune" || movie.Language != "en" {
+		t.Errorf("movie = %+v, want Dune in english", movie)
+	}
+	if movie.PicURL != "https://image.tmdb.org/t/p/w300/dune.jpg" || movie.TrailerURL != "https://www.youtube.com/watch?v=official" {
+		t.Errorf("poster = %q, trailer = %q", movie.PicURL, movie.TrailerURL)
+	}
+	if movie.TrailerViews != 42000 || movie.Duration.Minutes() != 155 {
+		t.Errorf("trailer views = %d, duration = %v", movie.TrailerViews, movie.Duration)
+	}
+	if len(movie.Cast) != 2 || len(movie.Type) != 1 || movie.Type[0].Name != "Science Fiction" {
+		t.Errorf("cast = %+v, types = %+v", movie.Cast, movie.Type)
+	}
+
+	// The theatrical certification is kept, and the US one is the default:
+	certifications := make(map[string]string)
+	for _, certification := range movie.Certifications {
+		certifications[certification.Country] = certification.Certification
+	}
+	if certifications["US"] != "PG-13" || certifications["FR"] != "TP" {
+		t.Errorf("certifications = %v, want PG-13 in US and TP in FR", certifications)
+	}
+	if movie.Certification != "PG-13" || movie.MinimumAge != 13 {
+		t.Errorf("default certification = %q from %d, want PG-13 from 13", movie.Certification, movie.MinimumAge)
+	}
+
+	var translationsCount int64
+	database.Model(&models.MovieTranslation{}).Where("movie_id = ?", movie.ID).Count(&translationsCount)
+	if translationsCount != int64(len(models.SupportedLocales)) {
+		t.Errorf("got %d translations, want one per locale", translationsCount)
+	}
+
+	// Importing it again is skipped:
+	again := models.ImportJobItem{ImportJobID: 1, TMDBID: 438631, Status: models.ImportItemPending}
+	database.Create(&again)
+	moviesRepo.runImportJobItem(context.Background(), &again, "admin")
+	if again.Status != models.ImportItemSkipped || again.Error != "MOVIE_ALREADY_IMPORTED" {
+		t.Errorf("item = %+v, want skipped as already imported", again)
+	}
+}
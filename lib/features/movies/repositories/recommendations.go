@@ -0,0 +1,261 @@
+package movies
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+const (
+	defaultRecommendationsCount = 10
+	maxRecommendationsCount     = 50
+
+	genreAffinityWeight = 3.0
+	castAffinityWeight  = 2.0
+	rateWeight          = 1.0
+	trailerViewsWeight  = 0.5
+	seatsSoldWeight     = 1.0
+)
+
+type recommendation struct {
+	Diffusion models.Diffusion `json:"diffusion"`
+	Score     float64          `json:"score"`
+	Reasons   []string         `json:"reasons"`
+}
+
+// recommendationSignals is what the ranking knows about a candidate movie.
+type recommendationSignals struct {
+	genreAffinity float64
+	castAffinity  float64
+	rate          float64
+	trailerViews  uint
+	seatsSold     int64
+}
+
+// GetRecommendations ranks the next diffusion of each movie the user has not
+// booked yet, by how close its genres and cast are to the ones the user booked
+// and by how popular it is. Users without history get the popular movies.
+func (moviesRepo *MoviesRepo) GetRecommendations(countString string, userID uint, cinemaID uint, locale string) (int, map[string]interface{}) {
+	count := defaultRecommendationsCount
+	if countString != "" {
+		var err error
+		count, err = strconv.Atoi(countString)
+		if err != nil || count <= 0 || count > maxRecommendationsCount {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": "INVALID_COUNT",
+			}
+		}
+	}
+
+	database := moviesRepo.database
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
+
+	// Read the history of the user:
+	bookedMovies := database.Model(&models.Reservation{}).
+		Select("DISTINCT diffusions.movie_id").
+		Joins("JOIN diffusions ON diffusions.id = reservations.diffusion_id").
+		Where("reservations.user_id = ?", userID)
+
+	var bookedMovieIDs []uint
+	if err := bookedMovies.Scan(&bookedMovieIDs).Error; err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_HISTORY_FAILED",
+		}
+	}
+
+	genreAffinities, err := moviesRepo.getAffinities("movie_types", "type_id", bookedMovieIDs)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_HISTORY_FAILED",
+		}
+	}
+	castAffinities, err := moviesRepo.getAffinities("movie_actors", "actor_id", bookedMovieIDs)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_HISTORY_FAILED",
+		}
+	}
+
+	// Find the next diffusion of every movie not booked yet:
+	nextShowTimes := database.Model(&models.Diffusion{}).
+		Select("diffusions.movie_id, MIN(diffusions.show_time) AS next_show_time").
+		Scopes(moviesRepo.diffusionsInCinema(cinemaID)).
+		Where("diffusions.show_time > ?", time.Now()).
+		Group("diffusions.movie_id")
+	query := database.Scopes(moviesRepo.diffusionsInCinema(cinemaID)).
+		Joins("JOIN (?) AS next_diffusions ON next_diffusions.movie_id = diffusions.movie_id AND next_diffusions.next_show_time = diffusions.show_time", nextShowTimes).
+		Preload("Movie.Type").
+		Order("diffusions.id ASC")
+	if len(bookedMovieIDs) > 0 {
+		query = query.Where("diffusions.movie_id NOT IN ?", bookedMovieIDs)
+	}
+
+	var candidates []models.Diffusion
+	if err := query.Find(&candidates).Error; err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FATCHING_DIFFUSIONS_FAILED",
+		}
+	}
+
+	var diffusions []models.Diffusion
+	var movieIDs []uint
+	seenMovies := make(map[uint]bool)
+	for _, diffusion := range candidates {
+		if seenMovies[diffusion.MovieID] {
+			continue
+		}
+		seenMovies[diffusion.MovieID] = true
+		diffusions = append(diffusions, diffusion)
+		movieIDs = append(movieIDs, diffusion.MovieID)
+	}
+
+	signals := make(map[uint]*recommendationSignals)
+	for _, diffusion := range diffusions {
+		signals[diffusion.MovieID] = &recommendationSignals{
+			rate:         diffusion.Movie.Rate,
+			trailerViews: diffusion.Movie.TrailerViews,
+		}
+		for _, movieType := range diffusion.Movie.Type {
+			signals[diffusion.MovieID].genreAffinity += genreAffinities[movieType.ID]
+		}
+	}
+
+	if len(movieIDs) > 0 {
+		if len(castAffinities) > 0 {
+			var actorIDs []uint
+			for actorID := range castAffinities {
+				actorIDs = append(actorIDs, actorID)
+			}
+			var castRows []struct {
+				MovieID uint
+				ActorID uint
+			}
+			database.Table("movie_actors").
+				Select("movie_id, actor_id").
+				Where("movie_id IN ? AND actor_id IN ?", movieIDs, actorIDs).
+				Scan(&castRows)
+			for _, row := range castRows {
+				signals[row.MovieID].castAffinity += castAffinities[row.ActorID]
+			}
+		}
+
+		var seatsRows []struct {
+			MovieID   uint
+			SeatsSold int64
+		}
+		database.Table("seats").
+			Select("diffusions.movie_id, COUNT(*) AS seats_sold").
+			Joins("JOIN diffusions ON diffusions.id = seats.diffusion_id").
+			Where("seats.status = ? AND diffusions.movie_id IN ?", "reserved", movieIDs).
+			Group("diffusions.movie_id").
+			Scan(&seatsRows)
+		for _, row := range seatsRows {
+			signals[row.MovieID].seatsSold = row.SeatsSold
+		}
+	}
+
+	recommendations := rankRecommendations(diffusions, signals)
+	if len(recommendations) > count {
+		recommendations = recommendations[:count]
+	}
+
+	recommendedDiffusions := make([]models.Diffusion, len(recommendations))
+	for index := range recommendations {
+		recommendedDiffusions[index] = recommendations[index].Diffusion
+	}
+	moviesRepo.localizeDiffusions(recommendedDiffusions)
+	moviesRepo.priceDiffusions(recommendedDiffusions)
+	moviesRepo.translateDiffusions(recommendedDiffusions, locale)
+	for index := range recommendations {
+		recommendations[index].Diffusion = recommendedDiffusions[index]
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":           len(recommendations),
+		"personalized":    len(bookedMovieIDs) > 0,
+		"recommendations": recommendations,
+	}
+}
+
+// getAffinities returns the share of the booked movies linked to each genre or
+// actor through the given join table.
+func (moviesRepo *MoviesRepo) getAffinities(joinTable string, column string, bookedMovieIDs []uint) (map[uint]float64, error) {
+	affinities := make(map[uint]float64)
+	if len(bookedMovieIDs) == 0 {
+		return affinities, nil
+	}
+
+	var rows []struct {
+		ID    uint
+		Count int64
+	}
+	err := moviesRepo.database.Table(joinTable).
+		Select(column+" AS id, COUNT(*) AS count").
+		Where("movie_id IN ?", bookedMovieIDs).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		affinities[row.ID] = float64(row.Count) / float64(len(bookedMovieIDs))
+	}
+	return affinities, nil
+}
+
+// rankRecommendations scores the diffusions, the popularity signals being
+// scaled on the best candidate. Equal scores keep the order of the movie ids,
+// so that the ranking is deterministic.
+func rankRecommendations(diffusions []models.Diffusion, signals map[uint]*recommendationSignals) []recommendation {
+	var maxTrailerViews uint
+	var maxSeatsSold int64
+	for _, signal := range signals {
+		maxTrailerViews = max(maxTrailerViews, signal.trailerViews)
+		maxSeatsSold = max(maxSeatsSold, signal.seatsSold)
+	}
+
+	recommendations := make([]recommendation, 0, len(diffusions))
+	for _, diffusion := range diffusions {
+		signal := signals[diffusion.MovieID]
+
+		score := genreAffinityWeight*signal.genreAffinity +
+			castAffinityWeight*signal.castAffinity +
+			rateWeight*signal.rate/10
+		if maxTrailerViews > 0 {
+			score += trailerViewsWeight * math.Log1p(float64(signal.trailerViews)) / math.Log1p(float64(maxTrailerViews))
+		}
+		if maxSeatsSold > 0 {
+			score += seatsSoldWeight * float64(signal.seatsSold) / float64(maxSeatsSold)
+		}
+
+		reasons := []string{}
+		if signal.genreAffinity > 0 {
+			reasons = append(reasons, "GENRE_AFFINITY")
+		}
+		if signal.castAffinity > 0 {
+			reasons = append(reasons, "CAST_AFFINITY")
+		}
+		if len(reasons) == 0 {
+			reasons = append(reasons, "POPULAR")
+		}
+
+		recommendations = append(recommendations, recommendation{
+			Diffusion: diffusion,
+			Score:     math.Round(score*1000) / 1000,
+			Reasons:   reasons,
+		})
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Diffusion.MovieID < recommendations[j].Diffusion.MovieID
+	})
+	return recommendations
+}
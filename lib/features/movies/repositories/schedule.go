@@ -0,0 +1,126 @@
+package movies
+
+import (
+	"sort"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	gorm "gorm.io/gorm"
+)
+
+// Longest show we expect in a hall, used to widen the lookup window so that a
+// diffusion starting before the checked period but still running is found.
+const maxShowDuration = 24 * time.Hour
+
+type diffusionConflict struct {
+	ShowTime      time.Time `json:"showTime"`
+	EndTime       time.Time `json:"endTime"`
+	HallID        uint      `json:"hallID"`
+	ConflictsWith uint      `json:"conflictsWith,omitempty"`
+	MaintenanceID uint      `json:"maintenanceID,omitempty"`
+	Reason        string    `json:"reason"`
+}
+
+// findScheduleConflicts checks the candidates against the maintenance windows
+// and the diffusions already scheduled in their halls, and against each other.
+// It returns the conflicts and the indexes of the candidates that cannot be
+// scheduled.
+func findScheduleConflicts(database *gorm.DB, candidates []models.Diffusion, excludedIDs []uint) ([]diffusionConflict, map[int]bool, error) {
+	conflicts := []diffusionConflict{}
+	conflicting := make(map[int]bool)
+
+	// Group candidates by hall:
+	hallsCandidates := make(map[uint][]int)
+	for index, candidate := range candidates {
+		hallsCandidates[candidate.HallID] = append(hallsCandidates[candidate.HallID], index)
+	}
+
+	for hallID, indexes := range hallsCandidates {
+		sort.Slice(indexes, func(i, j int) bool {
+			return candidates[indexes[i]].ShowTime.Before(candidates[indexes[j]].ShowTime)
+		})
+
+		fromDate := candidates[indexes[0]].ShowTime.Add(-maxShowDuration)
+		toDate := fromDate
+		for _, index := range indexes {
+			candidate := candidates[index]
+			if end := candidate.ShowTime.Add(candidate.ShowDuration); end.After(toDate) {
+				toDate = end
+			}
+		}
+
+		// Get the hall schedule:
+		query := database.Model(&models.Diffusion{}).
+			Where("hall_id = ? and show_time > ? and show_time < ?", hallID, fromDate, toDate)
+		if len(excludedIDs) > 0 {
+			query = query.Where("id not in ?", excludedIDs)
+		}
+		var scheduled []models.Diffusion
+		if err := query.Find(&scheduled).Error; err != nil {
+			return nil, nil, err
+		}
+
+		// Get the hall maintenance windows:
+		var maintenances []models.HallMaintenance
+		err := database.Where("hall_id = ? and to_date > ? and from_date < ?", hallID, fromDate, toDate).
+			Find(&maintenances).Error
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var accepted []models.Diffusion
+		for _, index := range indexes {
+			candidate := candidates[index]
+			candidateEnd := candidate.ShowTime.Add(candidate.ShowDuration)
+
+			conflict := diffusionConflict{
+				ShowTime: candidate.ShowTime,
+				EndTime:  candidateEnd,
+				HallID:   hallID,
+			}
+			for _, maintenance := range maintenances {
+				if maintenance.Overlaps(candidate) {
+					conflict.MaintenanceID = maintenance.ID
+					conflict.Reason = "HALL_UNDER_MAINTENANCE"
+					break
+				}
+			}
+			for _, diffusion := range scheduled {
+				if conflict.Reason != "" {
+					break
+				}
+				if overlaps(candidate, diffusion) {
+					conflict.ConflictsWith = diffusion.ID
+					conflict.Reason = "HALL_ALREADY_BOOKED"
+					break
+				}
+			}
+			if conflict.Reason == "" {
+				for _, diffusion := range accepted {
+					if overlaps(candidate, diffusion) {
+						conflict.Reason = "OVERLAPS_ANOTHER_OCCURRENCE"
+						break
+					}
+				}
+			}
+
+			if conflict.Reason != "" {
+				conflicts = append(conflicts, conflict)
+				conflicting[index] = true
+				continue
+			}
+			accepted = append(accepted, candidate)
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].ShowTime.Before(conflicts[j].ShowTime)
+	})
+	return conflicts, conflicting, nil
+}
+
+func overlaps(first models.Diffusion, second models.Diffusion) bool {
+	firstEnd := first.ShowTime.Add(first.ShowDuration)
+	secondEnd := second.ShowTime.Add(second.ShowDuration)
+	return first.ShowTime.Before(secondEnd) && second.ShowTime.Before(firstEnd)
+}
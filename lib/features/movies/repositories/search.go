@@ -0,0 +1,241 @@
+package movies
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+// Filters each facet ignores, so that its counts show what picking another
+// value would give:
+const (
+	facetGenres    = "genres"
+	facetLanguages = "languages"
+	facetRatings   = "ratings"
+)
+
+type genreFacet struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+type languageFacet struct {
+	Language string `json:"language"`
+	Count    int64  `json:"count"`
+}
+
+type ratingFacet struct {
+	Rate  int   `json:"rate"`
+	Count int64 `json:"count"`
+}
+
+func (moviesRepo *MoviesRepo) SearchMovies(values url.Values, userID uint, cinemaID uint, locale string) (int, map[string]interface{}) {
+	cinemaID = moviesRepo.resolveCinemaID(userID, cinemaID)
+	search, err := models.ParseMovieSearch(values, moviesRepo.getCinemaLocation(cinemaID))
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	var total int64
+	err = database.Model(&models.Movie{}).
+		Scopes(moviesRepo.movieSearchFilters(search, cinemaID, "")).
+		Count(&total).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "SEARCHING_MOVIES_FAILED",
+		}
+	}
+
+	var order interface{} = "movies.rate DESC, movies.id ASC"
+	if search.Query != "" {
+		// Title matches come first:
+		order = clause.OrderBy{Expression: clause.Expr{
+			SQL:                "movies.title LIKE ? DESC, movies.rate DESC, movies.id ASC",
+			Vars:               []interface{}{likePattern(search.Query)},
+			WithoutParentheses: true,
+		}}
+	}
+
+	var movies []models.Movie
+	err = database.Scopes(moviesRepo.movieSearchFilters(search, cinemaID, "")).
+		Preload("Type").
+		Order(order).
+		Offset((search.Page - 1) * search.PageSize).
+		Limit(search.PageSize).
+		Find(&movies).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "SEARCHING_MOVIES_FAILED",
+		}
+	}
+
+	facets, err := moviesRepo.getMovieSearchFacets(search, cinemaID, locale)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_FACETS_FAILED",
+		}
+	}
+
+	moviesToTranslate := make([]*models.Movie, len(movies))
+	for index := range movies {
+		moviesToTranslate[index] = &movies[index]
+	}
+	moviesRepo.translateMovies(moviesToTranslate, locale)
+
+	return http.StatusOK, map[string]interface{}{
+		"count":    len(movies),
+		"total":    total,
+		"page":     search.Page,
+		"pageSize": search.PageSize,
+		"movies":   movies,
+		"facets":   facets,
+	}
+}
+
+// movieSearchFilters applies the text query and the filters of the search to
+// a movies query, except the one of the given facet.
+func (moviesRepo *MoviesRepo) movieSearchFilters(search models.MovieSearch, cinemaID uint, exceptFacet string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		database := moviesRepo.database
+
+		if search.Query != "" {
+			pattern := likePattern(search.Query)
+			castMovies := database.Table("movie_actors").
+				Select("movie_actors.movie_id").
+				Joins("JOIN actors ON actors.id = movie_actors.actor_id").
+				Where("actors.name LIKE ?", pattern)
+			translatedMovies := database.Model(&models.MovieTranslation{}).
+				Select("movie_id").
+				Where("title LIKE ? OR description LIKE ?", pattern, pattern)
+			db = db.Where(
+				"movies.title LIKE ? OR movies.description LIKE ? OR movies.id IN (?) OR movies.id IN (?)",
+				pattern, pattern, castMovies, translatedMovies,
+			)
+		}
+
+		if len(search.TypeIDs) > 0 && exceptFacet != facetGenres {
+			typedMovies := database.Table("movie_types").Select("movie_id").Where("type_id IN ?", search.TypeIDs)
+			db = db.Where("movies.id IN (?)", typedMovies)
+		}
+		if search.Language != "" && exceptFacet != facetLanguages {
+			db = db.Where("movies.language = ?", search.Language)
+		}
+		if exceptFacet != facetRatings {
+			if search.MinRate != nil {
+				db = db.Where("movies.rate >= ?", *search.MinRate)
+			}
+			if search.MaxRate != nil {
+				db = db.Where("movies.rate <= ?", *search.MaxRate)
+			}
+		}
+		if search.MinDuration != nil {
+			db = db.Where("movies.duration >= ?", *search.MinDuration)
+		}
+		if search.MaxDuration != nil {
+			db = db.Where("movies.duration <= ?", *search.MaxDuration)
+		}
+
+		if search.HasDiffusionWindow() {
+			diffusedMovies := database.Model(&models.Diffusion{}).
+				Select("diffusions.movie_id").
+				Scopes(moviesRepo.diffusionsInCinema(cinemaID)).
+				Where("diffusions.show_time > ?", time.Now())
+			if search.From != nil {
+				diffusedMovies = diffusedMovies.Where("diffusions.show_time >= ?", *search.From)
+			}
+			if search.To != nil {
+				diffusedMovies = diffusedMovies.Where("diffusions.show_time < ?", *search.To)
+			}
+			db = db.Where("movies.id IN (?)", diffusedMovies)
+		}
+
+		return db
+	}
+}
+
+// getMovieSearchFacets counts the matching movies per genre, language and
+// rating.
+func (moviesRepo *MoviesRepo) getMovieSearchFacets(search models.MovieSearch, cinemaID uint, locale string) (map[string]interface{}, error) {
+	database := moviesRepo.database
+
+	matchingMovies := func(exceptFacet string) *gorm.DB {
+		return database.Model(&models.Movie{}).
+			Select("movies.id").
+			Scopes(moviesRepo.movieSearchFilters(search, cinemaID, exceptFacet))
+	}
+
+	genres := []genreFacet{}
+	err := database.Table("movie_types").
+		Select("types.id, types.name, COUNT(*) AS count").
+		Joins("JOIN types ON types.id = movie_types.type_id").
+		Where("movie_types.movie_id IN (?)", matchingMovies(facetGenres)).
+		Group("types.id, types.name").
+		Order("count DESC, types.name ASC").
+		Scan(&genres).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if locale != "" && len(genres) > 0 {
+		var typeIDs []uint
+		for _, genre := range genres {
+			typeIDs = append(typeIDs, genre.ID)
+		}
+		var translations []models.TypeTranslation
+		database.Where("type_id IN ? AND locale = ?", typeIDs, locale).Find(&translations)
+		typeNames := make(map[uint]string)
+		for _, translation := range translations {
+			typeNames[translation.TypeID] = translation.Name
+		}
+		for index := range genres {
+			if name, ok := typeNames[genres[index].ID]; ok {
+				genres[index].Name = name
+			}
+		}
+	}
+
+	languages := []languageFacet{}
+	err = database.Model(&models.Movie{}).
+		Select("movies.language, COUNT(*) AS count").
+		Where("movies.id IN (?)", matchingMovies(facetLanguages)).
+		Where("movies.language <> ''").
+		Group("movies.language").
+		Order("count DESC, movies.language ASC").
+		Scan(&languages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := []ratingFacet{}
+	err = database.Model(&models.Movie{}).
+		Select("FLOOR(movies.rate) AS rate, COUNT(*) AS count").
+		Where("movies.id IN (?)", matchingMovies(facetRatings)).
+		Group("FLOOR(movies.rate)").
+		Order("rate DESC").
+		Scan(&ratings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		facetGenres:    genres,
+		facetLanguages: languages,
+		facetRatings:   ratings,
+	}, nil
+}
+
+// likePattern matches the text anywhere, its wildcards taken literally.
+func likePattern(text string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return "%" + escaper.Replace(text) + "%"
+}
@@ -0,0 +1,202 @@
+package movies
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
+)
+
+// fakeTMDB is an in memory TMDB used to test the movie import offline:
+//
+//	fake := newFakeTMDB()
+//	defer fake.Close()
+//	fake.addMovie(fakeMovie{Details: tmdb.MovieDetails{ID: 1, Title: "Dune"}})
+//	moviesRepo := &MoviesRepo{tmdbAPI: tmdb.NewClient(fake.config())}
+type fakeTMDB struct {
+	*httptest.Server
+	movies   map[int]fakeMovie
+	failures []int
+	mu       sync.Mutex
+}
+
+type fakeMovie struct {
+	Details      tmdb.MovieDetails
+	Videos       []tmdb.Video
+	Cast         []tmdb.CastMember
+	ReleaseDates []tmdb.CountryReleaseDates
+	NowPlaying   bool
+	Upcoming     bool
+}
+
+func newFakeTMDB() *fakeTMDB {
+	fake := &fakeTMDB{
+		movies: make(map[int]fakeMovie),
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /search/movie", fake.searchMovies)
+	router.HandleFunc("GET /movie/now_playing", fake.listMovies(func(movie fakeMovie) bool { return movie.NowPlaying }))
+	router.HandleFunc("GET /movie/upcoming", fake.listMovies(func(movie fakeMovie) bool { return movie.Upcoming }))
+	router.HandleFunc("GET /movie/{id}", fake.getMovie)
+	router.HandleFunc("GET /movie/{id}/videos", fake.getMovieVideos)
+	router.HandleFunc("GET /movie/{id}/credits", fake.getMovieCredits)
+	router.HandleFunc("GET /movie/{id}/release_dates", fake.getMovieReleaseDates)
+
+	fake.Server = httptest.NewServer(fake.failing(router))
+	return fake
+}
+
+// config returns a client configuration pointing to the fake server, without
+// rate limiting nor caching.
+func (fake *fakeTMDB) config() tmdb.Config {
+	return tmdb.Config{
+		ApiKey:        "fake",
+		BaseURL:       fake.URL,
+		ImagesBaseURL: "https://image.tmdb.org/t/p/w300",
+		Timeout:       5 * time.Second,
+		MaxRetries:    3,
+	}
+}
+
+func (fake *fakeTMDB) addMovie(movie fakeMovie) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.movies[movie.Details.ID] = movie
+}
+
+// failNext makes the next requests answer with the given status codes, one
+// per request, before serving normally again.
+func (fake *fakeTMDB) failNext(statusCodes ...int) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.failures = append(fake.failures, statusCodes...)
+}
+
+func (fake *fakeTMDB) failing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		statusCode := 0
+		if len(fake.failures) > 0 {
+			statusCode = fake.failures[0]
+			fake.failures = fake.failures[1:]
+		}
+		fake.mu.Unlock()
+
+		if statusCode != 0 {
+			if statusCode == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeFakeResponse(w, statusCode, map[string]any{
+				"status_message": http.StatusText(statusCode),
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (fake *fakeTMDB) searchMovies(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("query"))
+	fake.listMovies(func(movie fakeMovie) bool {
+		return strings.Contains(strings.ToLower(movie.Details.Title), query)
+	})(w, r)
+}
+
+func (fake *fakeTMDB) listMovies(matches func(movie fakeMovie) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		results := []tmdb.MovieSummary{}
+		for _, movie := range fake.movies {
+			if matches(movie) {
+				results = append(results, tmdb.MovieSummary{
+					ID:            movie.Details.ID,
+					Title:         movie.Details.Title,
+					OriginalTitle: movie.Details.OriginalTitle,
+					Overview:      movie.Details.Overview,
+					PosterPath:    movie.Details.PosterPath,
+					VoteAverage:   movie.Details.VoteAverage,
+					VoteCount:     movie.Details.VoteCount,
+				})
+			}
+		}
+		fake.mu.Unlock()
+
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].ID < results[j].ID
+		})
+
+		writeFakeResponse(w, http.StatusOK, tmdb.MoviesPage{
+			Page:         1,
+			TotalPages:   1,
+			TotalResults: len(results),
+			Results:      results,
+		})
+	}
+}
+
+func (fake *fakeTMDB) getMovie(w http.ResponseWriter, r *http.Request) {
+	movie, ok := fake.findMovie(w, r)
+	if ok {
+		writeFakeResponse(w, http.StatusOK, movie.Details)
+	}
+}
+
+func (fake *fakeTMDB) getMovieVideos(w http.ResponseWriter, r *http.Request) {
+	movie, ok := fake.findMovie(w, r)
+	if ok {
+		writeFakeResponse(w, http.StatusOK, tmdb.Videos{
+			ID:      movie.Details.ID,
+			Results: append([]tmdb.Video{}, movie.Videos...),
+		})
+	}
+}
+
+func (fake *fakeTMDB) getMovieCredits(w http.ResponseWriter, r *http.Request) {
+	movie, ok := fake.findMovie(w, r)
+	if ok {
+		writeFakeResponse(w, http.StatusOK, tmdb.Credits{
+			ID:   movie.Details.ID,
+			Cast: append([]tmdb.CastMember{}, movie.Cast...),
+		})
+	}
+}
+
+func (fake *fakeTMDB) getMovieReleaseDates(w http.ResponseWriter, r *http.Request) {
+	movie, ok := fake.findMovie(w, r)
+	if ok {
+		writeFakeResponse(w, http.StatusOK, tmdb.ReleaseDates{
+			ID:      movie.Details.ID,
+			Results: append([]tmdb.CountryReleaseDates{}, movie.ReleaseDates...),
+		})
+	}
+}
+
+func (fake *fakeTMDB) findMovie(w http.ResponseWriter, r *http.Request) (fakeMovie, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+
+	fake.mu.Lock()
+	movie, ok := fake.movies[id]
+	fake.mu.Unlock()
+
+	if err != nil || !ok {
+		writeFakeResponse(w, http.StatusNotFound, map[string]any{
+			"status_message": "The resource you requested could not be found.",
+		})
+		return fakeMovie{}, false
+	}
+	return movie, true
+}
+
+func writeFakeResponse(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
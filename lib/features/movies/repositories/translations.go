@@ -0,0 +1,250 @@
+package movies
+
+import (
+	"context"
+	"net/http"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+func (moviesRepo *MoviesRepo) GetMovieTranslations(id string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := moviesRepo.database
+
+	var movie models.Movie
+	err := database.Where("id = ?", id).
+		Preload("Translations").
+		Preload("Type.Translations").
+		First(&movie).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "MOVIE_NOT_FOUND",
+		}
+	}
+
+	var types []map[string]interface{}
+	for _, movieType := range movie.Type {
+		types = append(types, map[string]interface{}{
+			"id":           movieType.ID,
+			"name":         movieType.Name,
+			"translations": movieType.Translations,
+		})
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"movieID":      movie.ID,
+		"translations": movie.Translations,
+		"types":        types,
+	}
+}
+
+func (moviesRepo *MoviesRepo) UpdateMovieTranslation(newTranslation models.MovieTranslation) (int, map[string]interface{}) {
+	// Validate inputs:
+	if err := newTranslation.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	err := database.Where("id = ?", newTranslation.MovieID).First(&models.Movie{}).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "MOVIE_NOT_FOUND",
+		}
+	}
+
+	var translation models.MovieTranslation
+	database.Where(models.MovieTranslation{
+		MovieID: newTranslation.MovieID,
+		Locale:  newTranslation.Locale,
+	}).FirstOrInit(&translation)
+
+	if newTranslation.Title != "" {
+		translation.Title = newTranslation.Title
+	}
+	if newTranslation.Description != "" {
+		translation.Description = newTranslation.Description
+	}
+	translation.AdminEdited = true
+
+	err = database.Save(&translation).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "UPDATING_TRANSLATION_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":     "TRANSLATION_UPDATED",
+		"translation": translation,
+	}
+}
+
+func (moviesRepo *MoviesRepo) UpdateTypeTranslation(newTranslation models.TypeTranslation) (int, map[string]interface{}) {
+	// Validate inputs:
+	if err := newTranslation.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := moviesRepo.database
+
+	err := database.Where("id = ?", newTranslation.TypeID).First(&models.Type{}).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "TYPE_NOT_FOUND",
+		}
+	}
+
+	var translation models.TypeTranslation
+	database.Where(models.TypeTranslation{
+		TypeID: newTranslation.TypeID,
+		Locale: newTranslation.Locale,
+	}).FirstOrInit(&translation)
+
+	translation.Name = newTranslation.Name
+	translation.AdminEdited = true
+
+	err = database.Save(&translation).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "UPDATING_TRANSLATION_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":     "TRANSLATION_UPDATED",
+		"translation": translation,
+	}
+}
+
+// syncMovieTranslations fetches the movie title, description and genre names
+// in every supported locale, leaving the translations edited by an admin
+// untouched.
+func (moviesRepo *MoviesRepo) syncMovieTranslations(ctx context.Context, movie *models.Movie) error {
+	tmdbAPI := moviesRepo.tmdbAPI
+	database := moviesRepo.database
+
+	for _, locale := range models.SupportedLocales {
+		details, err := tmdbAPI.GetMovie(ctx, int(*movie.TMDBID), locale)
+		if err != nil {
+			return err
+		}
+
+		var translation models.MovieTranslation
+		database.Where(models.MovieTranslation{
+			MovieID: movie.ID,
+			Locale:  locale,
+		}).FirstOrInit(&translation)
+
+		if !translation.AdminEdited && (details.Title != "" || details.Overview != "") {
+			translation.Title = details.Title
+			translation.Description = details.Overview
+			err = database.Save(&translation).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, genre := range details.Genres {
+			var movieType models.Type
+			err := database.Where("tmdb_id = ?", genre.ID).First(&movieType).Error
+			if err != nil || genre.Name == "" {
+				continue
+			}
+
+			var typeTranslation models.TypeTranslation
+			database.Where(models.TypeTranslation{
+				TypeID: movieType.ID,
+				Locale: locale,
+			}).FirstOrInit(&typeTranslation)
+
+			if typeTranslation.AdminEdited || typeTranslation.Name == genre.Name {
+				continue
+			}
+
+			typeTranslation.Name = genre.Name
+			err = database.Save(&typeTranslation).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// translateMovies replaces the titles, descriptions and genre names of the
+// movies by their translation in the locale, when there is one.
+func (moviesRepo *MoviesRepo) translateMovies(movies []*models.Movie, locale string) {
+	if locale == "" || len(movies) == 0 {
+		return
+	}
+
+	var movieIDs []uint
+	var typeIDs []uint
+	for _, movie := range movies {
+		if movie.ID == 0 {
+			continue
+		}
+		movieIDs = append(movieIDs, movie.ID)
+		for _, movieType := range movie.Type {
+			typeIDs = append(typeIDs, movieType.ID)
+		}
+	}
+	if len(movieIDs) == 0 {
+		return
+	}
+
+	database := moviesRepo.database
+
+	var translations []models.MovieTranslation
+	database.Where("movie_id IN ? AND locale = ?", movieIDs, locale).Find(&translations)
+
+	movieTranslations := make(map[uint]models.MovieTranslation)
+	for _, translation := range translations {
+		movieTranslations[translation.MovieID] = translation
+	}
+
+	typeNames := make(map[uint]string)
+	if len(typeIDs) > 0 {
+		var typeTranslations []models.TypeTranslation
+		database.Where("type_id IN ? AND locale = ?", typeIDs, locale).Find(&typeTranslations)
+		for _, translation := range typeTranslations {
+			typeNames[translation.TypeID] = translation.Name
+		}
+	}
+
+	for _, movie := range movies {
+		if translation, ok := movieTranslations[movie.ID]; ok {
+			if translation.Title != "" {
+				movie.Title = translation.Title
+			}
+			if translation.Description != "" {
+				movie.Description = translation.Description
+			}
+		}
+
+		for index := range movie.Type {
+			if name, ok := typeNames[movie.Type[index].ID]; ok {
+				movie.Type[index].Name = name
+			}
+		}
+	}
+}
+
+func (moviesRepo *MoviesRepo) translateDiffusions(diffusions []models.Diffusion, locale string) {
+	movies := make([]*models.Movie, len(diffusions))
+	for index := range diffusions {
+		movies[index] = &diffusions[index].Movie
+	}
+	moviesRepo.translateMovies(movies, locale)
+}
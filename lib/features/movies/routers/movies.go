@@ -36,23 +36,52 @@ func (moviesRouter *MoviesRouter) RegisterRouts() {
 		middlewares.AuthorizationWithEmailVerification,
 		middlewares.AuthorizationWithAdminCheck,
 	)
+	authorizationWithCinemaAdminCheck := tools.MiddlewareChain(
+		middlewares.Authorization,
+		middlewares.AuthorizationWithEmailVerification,
+		middlewares.AuthorizationWithCinemaAdminCheck,
+	)
 
 	router.HandleFunc("GET /getMoviesFromTMDB", authorizationWithEmailVerification(http.HandlerFunc(controller.GetMoviesFromTMDB)))
 	router.HandleFunc("GET /getMovieTrailersFromTMDB", authorizationWithEmailVerification(http.HandlerFunc(controller.GetMovieTrailersFromTMDB)))
 	router.HandleFunc("POST /addMovie", authorizationWithEmailVerification(http.HandlerFunc(controller.AddMovie)))
+	router.HandleFunc("POST /importMovies", authorizationWithAdminCheck(http.HandlerFunc(controller.ImportMovies)))
+	router.HandleFunc("GET /getImportJob", authorizationWithAdminCheck(http.HandlerFunc(controller.GetImportJob)))
 	router.HandleFunc("GET /getMovie", authorizationWithEmailVerification(http.HandlerFunc(controller.GetMovie)))
 	router.HandleFunc("GET /getMovies", authorizationWithAdminCheck(http.HandlerFunc(controller.GetMovies)))
+	router.HandleFunc("GET /search", authorizationWithEmailVerification(http.HandlerFunc(controller.SearchMovies)))
 	router.HandleFunc("PUT /updateMovie", authorizationWithAdminCheck(http.HandlerFunc(controller.UpdateMovie)))
-	router.HandleFunc("POST /addHall", authorizationWithAdminCheck(http.HandlerFunc(controller.AddHall)))
-	router.HandleFunc("POST /addDiffusion", authorizationWithAdminCheck(http.HandlerFunc(controller.AddDiffusion)))
-	router.HandleFunc("GET /getHalls", authorizationWithAdminCheck(http.HandlerFunc(controller.GetHalls)))
-	router.HandleFunc("GET /getAllWeeksUntilNextYear", authorizationWithAdminCheck(http.HandlerFunc(controller.GetAllWeeksUntilNextYear)))
+	router.HandleFunc("POST /refreshMoviesMetadata", authorizationWithAdminCheck(http.HandlerFunc(controller.RefreshMoviesMetadata)))
+	router.HandleFunc("GET /getMovieTranslations", authorizationWithAdminCheck(http.HandlerFunc(controller.GetMovieTranslations)))
+	router.HandleFunc("PUT /updateMovieTranslation", authorizationWithAdminCheck(http.HandlerFunc(controller.UpdateMovieTranslation)))
+	router.HandleFunc("PUT /updateTypeTranslation", authorizationWithAdminCheck(http.HandlerFunc(controller.UpdateTypeTranslation)))
+	router.HandleFunc("GET /getMovieCertifications", authorizationWithEmailVerification(http.HandlerFunc(controller.GetMovieCertifications)))
+	router.HandleFunc("PUT /setMovieCertification", authorizationWithAdminCheck(http.HandlerFunc(controller.SetMovieCertification)))
+	router.HandleFunc("GET /actors", authorizationWithEmailVerification(http.HandlerFunc(controller.SearchActors)))
+	router.HandleFunc("GET /actors/{id}", authorizationWithEmailVerification(http.HandlerFunc(controller.GetActor)))
+	router.HandleFunc("POST /mergeActors", authorizationWithAdminCheck(http.HandlerFunc(controller.MergeActors)))
+	router.HandleFunc("GET /genres/{id}", authorizationWithEmailVerification(http.HandlerFunc(controller.GetGenre)))
+	router.HandleFunc("POST /addHall", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.AddHall)))
+	router.HandleFunc("POST /addDiffusion", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.AddDiffusion)))
+	router.HandleFunc("POST /addDiffusionSeries", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.AddDiffusionSeries)))
+	router.HandleFunc("GET /getDiffusionSeries", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.GetDiffusionSeries)))
+	router.HandleFunc("PUT /updateDiffusionSeries", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.UpdateDiffusionSeries)))
+	router.HandleFunc("DELETE /cancelDiffusionSeries", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.CancelDiffusionSeries)))
+	router.HandleFunc("POST /copyWeek", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.CopyWeek)))
+	router.HandleFunc("GET /getHalls", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.GetHalls)))
+	router.HandleFunc("PUT /updateHall", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.UpdateHall)))
+	router.HandleFunc("DELETE /deleteHall", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.DeleteHall)))
+	router.HandleFunc("POST /addHallMaintenance", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.AddHallMaintenance)))
+	router.HandleFunc("GET /getHallMaintenances", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.GetHallMaintenances)))
+	router.HandleFunc("DELETE /deleteHallMaintenance", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.DeleteHallMaintenance)))
+	router.HandleFunc("GET /getAllWeeksUntilNextYear", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.GetAllWeeksUntilNextYear)))
 	router.HandleFunc("DELETE /deleteMovie", authorizationWithAdminCheck(http.HandlerFunc(controller.DeleteMovie)))
-	router.HandleFunc("POST /getDiffusionsForAdmin", authorizationWithEmailVerification(http.HandlerFunc(controller.GetDiffusionsForAdmin)))
-	router.HandleFunc("DELETE /deleteDiffusion", authorizationWithAdminCheck(http.HandlerFunc(controller.DeleteDiffusion)))
+	router.HandleFunc("POST /getDiffusionsForAdmin", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.GetDiffusionsForAdmin)))
+	router.HandleFunc("DELETE /deleteDiffusion", authorizationWithCinemaAdminCheck(http.HandlerFunc(controller.DeleteDiffusion)))
 	router.HandleFunc("GET /getTopDiffusion", authorizationWithEmailVerification(http.HandlerFunc(controller.GetTopDiffusion)))
 	router.HandleFunc("POST /getDiffusionsByDay", authorizationWithEmailVerification(http.HandlerFunc(controller.GetDiffusionsByDay)))
 	router.HandleFunc("GET /getMostPopularDiffusionsTrailers", authorizationWithEmailVerification(http.HandlerFunc(controller.GetMostPopularDiffusionsTrailers)))
+	router.HandleFunc("GET /recommendations", authorizationWithEmailVerification(http.HandlerFunc(controller.GetRecommendations)))
 	router.HandleFunc("GET /getDiffusionsForUsers", authorizationWithEmailVerification(http.HandlerFunc(controller.GetDiffusionsForUsers)))
 	router.HandleFunc("GET /getMoviesDiffusions", authorizationWithEmailVerification(http.HandlerFunc(controller.GetMoviesDiffusions)))
 }
@@ -2,11 +2,14 @@ package reservations
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	reservationsRepo "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/reservations/repositories"
 	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
 )
 
 type ReservationsController struct {
@@ -42,11 +45,22 @@ func (reservationsController *ReservationsController) CreatePaymentIntent(w http
 	var body map[string]any
 	json.NewDecoder(r.Body).Decode(&body)
 
-	amount := body["amount"].(float64)
-	currency := body["currency"].(string)
+	var seatIDs []uint
+	seatIDsJSON, _ := json.Marshal(body["seatIDs"])
+	json.Unmarshal(seatIDsJSON, &seatIDs)
+	points, _ := body["points"].(float64)
+	promoCode, _ := body["promoCode"].(string)
+	giftCardCode, _ := body["giftCardCode"].(string)
+	var concessions []reservationsRepo.ConcessionOrder
+	concessionsJSON, _ := json.Marshal(body["concessions"])
+	json.Unmarshal(concessionsJSON, &concessions)
+	diffusionID, _ := body["diffusionID"].(float64)
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	id := uint(auth["id"].(float64))
 
 	reservationsRepo := reservationsController.reservationsRepo
-	status, result := reservationsRepo.CreatePaymentIntent(int64(amount), currency)
+	status, result := reservationsRepo.CreatePaymentIntent(seatIDs, concessions, uint(max(points, 0)), promoCode, giftCardCode, uint(max(diffusionID, 0)), id)
 
 	w.WriteHeader(status)
 	response, _ := json.Marshal(&result)
@@ -93,7 +107,22 @@ func (reservationsController *ReservationsController) UpdateReservation(w http.R
 
 	reservationsRepo := reservationsController.reservationsRepo
 
-	status, result := reservationsRepo.UpdateReservation(body)
+	status, result := reservationsRepo.UpdateReservation(body, tools.GetCinemaScope(r))
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) CheckInReservation(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TicketCode string `json:"ticketCode"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	reservationsRepo := reservationsController.reservationsRepo
+
+	status, result := reservationsRepo.CheckInReservation(body.TicketCode, tools.GetCinemaScope(r))
 
 	w.WriteHeader(status)
 	response, _ := json.Marshal(&result)
@@ -102,6 +131,7 @@ func (reservationsController *ReservationsController) UpdateReservation(w http.R
 
 func (reservationsController *ReservationsController) GetReservations(w http.ResponseWriter, r *http.Request) {
 	var body struct {
+		CinemaID   uint      `json:"cinemaID"`
 		HallName   string    `json:"hallName"`
 		MovieTitle string    `json:"movieTitle"`
 		ShowTime   time.Time `json:"showTime"`
@@ -112,10 +142,13 @@ func (reservationsController *ReservationsController) GetReservations(w http.Res
 	reservationsRepo := reservationsController.reservationsRepo
 
 	status, result := reservationsRepo.GetReservations(
+		body.CinemaID,
 		body.HallName,
 		body.MovieTitle,
 		body.ShowTime,
 		body.IsExpired,
+		tools.GetCinemaScope(r),
+		tools.GetListingQuery(r),
 	)
 
 	w.WriteHeader(status)
@@ -129,7 +162,7 @@ func (reservationsController *ReservationsController) GetUserReservations(w http
 
 	reservationsRepo := reservationsController.reservationsRepo
 
-	status, result := reservationsRepo.GetUserReservations(userID)
+	status, result := reservationsRepo.GetUserReservations(userID, tools.GetListingQuery(r))
 
 	w.WriteHeader(status)
 	response, _ := json.Marshal(&result)
@@ -158,4 +191,314 @@ func (reservationsController *ReservationsController) GetReservation(w http.Resp
 	w.WriteHeader(status)
 	response, _ := json.Marshal(&result)
 	w.Write(response)
-}
\ No newline at end of file
+}
+func (reservationsController *ReservationsController) CheckPromoCode(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	diffusionID, _ := strconv.Atoi(r.URL.Query().Get("diffusionID"))
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+
+	var result map[string]interface{}
+	promoCode, status, err := reservationsRepo.CheckPromoCode(code, userID, uint(max(diffusionID, 0)))
+	if err != nil {
+		result = map[string]interface{}{
+			"error": err.Error(),
+		}
+	} else {
+		result = map[string]interface{}{
+			"promoCode": promoCode,
+		}
+	}
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) AddPromoCode(w http.ResponseWriter, r *http.Request) {
+	var body models.PromoCode
+	json.NewDecoder(r.Body).Decode(&body)
+
+	reservationsRepo := reservationsController.reservationsRepo
+
+	status, result := reservationsRepo.AddPromoCode(body)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) UpdatePromoCode(w http.ResponseWriter, r *http.Request) {
+	var body models.PromoCode
+	json.NewDecoder(r.Body).Decode(&body)
+
+	reservationsRepo := reservationsController.reservationsRepo
+
+	status, result := reservationsRepo.UpdatePromoCode(body)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) GetPromoCodes(w http.ResponseWriter, r *http.Request) {
+	reservationsRepo := reservationsController.reservationsRepo
+
+	status, result := reservationsRepo.GetPromoCodes(tools.GetListingQuery(r))
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) DeletePromoCode(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	reservationsRepo := reservationsController.reservationsRepo
+
+	status, result := reservationsRepo.DeletePromoCode(id)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) CreateGiftCardIntent(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+
+	amount, _ := body["amount"].(float64)
+	currency, _ := body["currency"].(string)
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	id := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.CreateGiftCardIntent(int64(amount), currency, id)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) AddGiftCard(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+
+	paymentIntent, _ := body["paymentIntent"].(string)
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	id := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.AddGiftCard(paymentIntent, id)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) GetGiftCard(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetGiftCard(code)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) GetUserGiftCards(w http.ResponseWriter, r *http.Request) {
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetUserGiftCards(userID, tools.GetListingQuery(r))
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) SuggestSeats(w http.ResponseWriter, r *http.Request) {
+	queries := r.URL.Query()
+	preferences := reservationsRepo.SeatPreferences{
+		Center:     queries.Get("center") == "true",
+		Aisle:      queries.Get("aisle") == "true",
+		Accessible: queries.Get("accessible") == "true",
+	}
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.SuggestSeats(queries.Get("diffusionID"), queries.Get("partySize"), queries.Get("count"), preferences)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+
+	diffusionID, _ := body["diffusionID"].(float64)
+	partySize, _ := body["partySize"].(float64)
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.JoinWaitlist(models.WaitlistEntry{
+		DiffusionID: uint(max(diffusionID, 0)),
+		UserID:      userID,
+		PartySize:   uint(max(partySize, 0)),
+	})
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) GetWaitlist(w http.ResponseWriter, r *http.Request) {
+	diffusionID, _ := strconv.Atoi(r.URL.Query().Get("diffusionID"))
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetWaitlistEntry(uint(max(diffusionID, 0)), userID)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	diffusionID, _ := strconv.Atoi(r.PathValue("diffusionID"))
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.LeaveWaitlist(uint(max(diffusionID, 0)), userID)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) GetReservationNotifications(w http.ResponseWriter, r *http.Request) {
+	reservationID, _ := strconv.Atoi(r.URL.Query().Get("reservationID"))
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetReservationNotifications(uint(max(reservationID, 0)), userID)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetUserCalendar(userID)
+
+	writeFile(w, status, result, "calendar", "text/calendar; charset=utf-8", "reservations.ics")
+}
+
+func (reservationsController *ReservationsController) GetFeedCalendar(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetFeedCalendar(token)
+
+	writeFile(w, status, result, "calendar", "text/calendar; charset=utf-8", "reservations.ics")
+}
+
+func (reservationsController *ReservationsController) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetCalendarFeed(userID, false)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+func (reservationsController *ReservationsController) ResetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetCalendarFeed(userID, true)
+
+	w.WriteHeader(status)
+	response, _ := json.Marshal(&result)
+	w.Write(response)
+}
+
+// writeFile writes the file the result holds under the key, or its error as
+// JSON.
+func writeFile(w http.ResponseWriter, status int, result map[string]interface{}, key string, contentType string, filename string) {
+	file, ok := result[key].([]byte)
+	if !ok {
+		w.WriteHeader(status)
+		response, _ := json.Marshal(&result)
+		w.Write(response)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%v"`, filename))
+	w.WriteHeader(status)
+	w.Write(file)
+}
+
+func (reservationsController *ReservationsController) GetTicketPDF(w http.ResponseWriter, r *http.Request) {
+	reservationID, _ := strconv.Atoi(r.PathValue("id"))
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetReservationTicket(uint(max(reservationID, 0)), userID)
+
+	writeFile(w, status, result, "pdf", "application/pdf", fmt.Sprintf("ticket-%v.pdf", reservationID))
+}
+
+func (reservationsController *ReservationsController) GetInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	reservationID, _ := strconv.Atoi(r.PathValue("id"))
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.GetReservationInvoice(uint(max(reservationID, 0)), userID)
+
+	writeFile(w, status, result, "pdf", "application/pdf", fmt.Sprintf("invoice-%v.pdf", reservationID))
+}
+
+func (reservationsController *ReservationsController) IssueInvoice(w http.ResponseWriter, r *http.Request) {
+	reservationID, _ := strconv.Atoi(r.PathValue("id"))
+
+	var billing models.InvoiceBilling
+	json.NewDecoder(r.Body).Decode(&billing)
+
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	userID := uint(auth["id"].(float64))
+
+	reservationsRepo := reservationsController.reservationsRepo
+	status, result := reservationsRepo.IssueReservationInvoice(uint(max(reservationID, 0)), userID, billing)
+
+	writeFile(w, status, result, "pdf", "application/pdf", fmt.Sprintf("invoice-%v.pdf", reservationID))
+}
@@ -0,0 +1,62 @@
+package reservations
+
+import (
+	"errors"
+	"net/http"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	gorm "gorm.io/gorm"
+)
+
+// CheckAgeRestriction refuses the diffusion to a user too young to watch its
+// movie in the country of the cinema.
+func (reservationsRepo *ReservationsRepo) CheckAgeRestriction(userID uint, diffusionID uint) (int, error) {
+	database := reservationsRepo.database
+
+	var diffusion models.Diffusion
+	err := database.Where("id = ?", diffusionID).
+		Scopes(withAgeRestriction("")).
+		First(&diffusion).Error
+	if err != nil {
+		return http.StatusBadRequest, errors.New("INVALID_DIFFUSION_ID")
+	}
+
+	minimumAge := diffusionMinimumAge(diffusion)
+	if minimumAge == 0 {
+		return http.StatusOK, nil
+	}
+
+	var user models.User
+	err = database.Where("id = ?", userID).Select("id", "birth_day").First(&user).Error
+	if err != nil {
+		return http.StatusBadRequest, errors.New("INVALID_USER_ID")
+	}
+	if user.BirthDay.IsZero() {
+		return http.StatusForbidden, errors.New("BIRTHDAY_REQUIRED")
+	}
+
+	showTime := diffusion.ShowTime.In(diffusion.Hall.Location())
+	if user.AgeAt(showTime) < int(minimumAge) {
+		return http.StatusForbidden, errors.New("AGE_RESTRICTED")
+	}
+
+	return http.StatusOK, nil
+}
+
+// withAgeRestriction preloads what the minimum age of a diffusion depends on,
+// under the given association prefix (e.g. "Diffusion.").
+func withAgeRestriction(prefix string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload(prefix + "Movie.Certifications").Preload(prefix + "Hall.Cinema")
+	}
+}
+
+// diffusionMinimumAge returns the minimum age to attend the diffusion, which
+// must be loaded withAgeRestriction.
+func diffusionMinimumAge(diffusion models.Diffusion) uint {
+	country := models.DefaultCertificationCountry
+	if diffusion.Hall != nil && diffusion.Hall.Cinema != nil && diffusion.Hall.Cinema.Country != "" {
+		country = diffusion.Hall.Cinema.Country
+	}
+	return diffusion.Movie.MinimumAgeIn(country)
+}
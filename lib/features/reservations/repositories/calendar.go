@@ -0,0 +1,148 @@
+package reservations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+// Past reservations stay in the calendar feed during:
+const calendarHistory = 90 * 24 * time.Hour
+
+// GetUserCalendar returns the reservations of the user as an iCalendar file.
+func (reservationsRepo *ReservationsRepo) GetUserCalendar(userID uint) (int, map[string]interface{}) {
+	database := reservationsRepo.database
+
+	recentDiffusionIDs := database.Model(&models.Diffusion{}).
+		Select("id").
+		Where("show_time > ?", time.Now().Add(-calendarHistory))
+
+	var reservations []models.Reservation
+	err := database.Where("user_id = ? AND diffusion_id IN (?)", userID, recentDiffusionIDs).
+		Preload("Seats").
+		Preload("Diffusion.Movie").
+		Preload("Diffusion.Hall.Cinema").
+		Find(&reservations).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_RESERVATIONS_FAILED",
+		}
+	}
+
+	var events []tools.CalendarEvent
+	for _, reservation := range reservations {
+		if reservation.Diffusion.Hall == nil {
+			continue
+		}
+		events = append(events, reservationCalendarEvent(reservation))
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"calendar": tools.EncodeCalendar("Kinema reservations", events),
+	}
+}
+
+// GetFeedCalendar returns the calendar of the user the feed token belongs to.
+func (reservationsRepo *ReservationsRepo) GetFeedCalendar(token string) (int, map[string]interface{}) {
+	if token == "" {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "CALENDAR_FEED_NOT_FOUND",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var user models.User
+	err := database.Select("id").Where("calendar_token = ?", token).First(&user).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "CALENDAR_FEED_NOT_FOUND",
+		}
+	}
+
+	return reservationsRepo.GetUserCalendar(user.ID)
+}
+
+// GetCalendarFeed returns the secret path of the calendar feed of the user,
+// creating it on the first call. Resetting it revokes the previous one.
+func (reservationsRepo *ReservationsRepo) GetCalendarFeed(userID uint, reset bool) (int, map[string]interface{}) {
+	database := reservationsRepo.database
+
+	var user models.User
+	err := database.Select("id", "calendar_token").Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "USER_NOT_FOUND",
+		}
+	}
+
+	if user.CalendarToken == nil || reset {
+		token, err := newCalendarToken()
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "CREATING_CALENDAR_FEED_FAILED",
+			}
+		}
+		err = database.Model(&user).Update("calendar_token", token).Error
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "CREATING_CALENDAR_FEED_FAILED",
+			}
+		}
+		user.CalendarToken = &token
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"path": fmt.Sprintf("/api/v1/reservations/calendar/%v/reservations.ics", *user.CalendarToken),
+	}
+}
+
+func newCalendarToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// reservationCalendarEvent describes the diffusion of the reservation, its
+// movie, hall and seats being loaded.
+func reservationCalendarEvent(reservation models.Reservation) tools.CalendarEvent {
+	diffusion := reservation.Diffusion
+	hall := diffusion.Hall
+
+	duration := diffusion.ShowDuration
+	if duration <= 0 {
+		duration = diffusion.Movie.Duration
+	}
+
+	location := hall.Name
+	if hall.Cinema != nil {
+		location = fmt.Sprintf("%v, %v", hall.Cinema.Name, hall.Name)
+	}
+
+	var seatLabels []string
+	for _, seat := range reservation.Seats {
+		seatLabels = append(seatLabels, models.SeatLabel(seat.SeatRow, seat.SeatColumn))
+	}
+
+	return tools.CalendarEvent{
+		UID:         fmt.Sprintf("reservation-%v@kinema", reservation.ID),
+		Start:       diffusion.ShowTime,
+		End:         diffusion.ShowTime.Add(duration),
+		Summary:     diffusion.Movie.Title,
+		Location:    location,
+		Description: fmt.Sprintf("Reservation #%v\nSeats: %v", reservation.ID, strings.Join(seatLabels, ", ")),
+	}
+}
+
+// reservationCalendar is the calendar attached to the confirmation.
+func reservationCalendar(reservation models.Reservation) []byte {
+	return tools.EncodeCalendar("", []tools.CalendarEvent{reservationCalendarEvent(reservation)})
+}
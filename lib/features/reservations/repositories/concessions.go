@@ -0,0 +1,163 @@
+package reservations
+
+import (
+	"errors"
+	"strings"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+var errConcessionOutOfStock = errors.New("CONCESSION_OUT_OF_STOCK")
+
+// ConcessionOrder is a line of concessions asked for at checkout.
+type ConcessionOrder struct {
+	VariantID uint `json:"variantID"`
+	Quantity  uint `json:"quantity"`
+}
+
+// priceConcessions builds the lines of the concessions ordered in the cinema
+// of the diffusion, locking their stocks, and returns their amount in minor
+// units of the currency of the cinema, which the seats are priced in too.
+func priceConcessions(tx *gorm.DB, orders []ConcessionOrder, diffusionID uint, userID uint) ([]models.ReservationItem, int64, error) {
+	if len(orders) == 0 {
+		return nil, 0, nil
+	}
+
+	var diffusion models.Diffusion
+	err := tx.Preload("Hall.Cinema").Where("id = ?", diffusionID).First(&diffusion).Error
+	if err != nil || diffusion.Hall == nil || diffusion.Hall.Cinema == nil {
+		return nil, 0, errors.New("INVALID_DIFFUSION_ID")
+	}
+	cinema := diffusion.Hall.Cinema
+
+	// Merge the lines of the same variant:
+	var variantIDs []uint
+	quantities := make(map[uint]uint)
+	for _, order := range orders {
+		if order.VariantID == 0 || order.Quantity == 0 {
+			return nil, 0, errors.New("INVALID_CONCESSIONS")
+		}
+		if quantities[order.VariantID] == 0 {
+			variantIDs = append(variantIDs, order.VariantID)
+		}
+		quantities[order.VariantID] += order.Quantity
+	}
+
+	var items []models.ReservationItem
+	var amount int64
+	for _, variantID := range variantIDs {
+		var stock models.ConcessionStock
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("cinema_id = ? AND variant_id = ?", cinema.ID, variantID).
+			First(&stock).Error
+		if err != nil {
+			return nil, 0, errors.New("CONCESSION_NOT_AVAILABLE")
+		}
+		if stock.Quantity < quantities[variantID] {
+			return nil, 0, errConcessionOutOfStock
+		}
+
+		var variant models.ConcessionVariant
+		err = tx.Preload("Item").Where("id = ?", variantID).First(&variant).Error
+		if err != nil || variant.Item == nil {
+			return nil, 0, errors.New("CONCESSION_NOT_AVAILABLE")
+		}
+
+		item := models.ReservationItem{
+			UserID:    userID,
+			CinemaID:  cinema.ID,
+			VariantID: variantID,
+			Name:      variant.Item.Name + " - " + variant.Name,
+			Quantity:  quantities[variantID],
+			UnitPrice: stock.Price,
+		}
+		items = append(items, item)
+		amount += item.Amount()
+	}
+
+	return items, amount, nil
+}
+
+// holdConcessions takes the lines out of the stocks of the cinema until the
+// payment intent is paid.
+func holdConcessions(tx *gorm.DB, items []models.ReservationItem, paymentIntentID string) error {
+	for _, item := range items {
+		result := tx.Model(&models.ConcessionStock{}).
+			Where("cinema_id = ? AND variant_id = ? AND quantity >= ?", item.CinemaID, item.VariantID, item.Quantity).
+			Update("quantity", gorm.Expr("quantity - ?", item.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errConcessionOutOfStock
+		}
+
+		item.PaymentIntentID = paymentIntentID
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restockConcessions puts the lines that were not handed over back in the
+// stocks of the cinema, then removes them.
+func restockConcessions(tx *gorm.DB, items []models.ReservationItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	var itemIDs []uint
+	for _, item := range items {
+		itemIDs = append(itemIDs, item.ID)
+		if item.Fulfilled {
+			continue
+		}
+		err := tx.Model(&models.ConcessionStock{}).
+			Where("cinema_id = ? AND variant_id = ?", item.CinemaID, item.VariantID).
+			Update("quantity", gorm.Expr("quantity + ?", item.Quantity)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("id IN ?", itemIDs).Delete(&models.ReservationItem{}).Error
+}
+
+// settleReservationItems ties the concessions held for the payment intent to
+// the reservation.
+func settleReservationItems(tx *gorm.DB, reservation models.Reservation) error {
+	return tx.Model(&models.ReservationItem{}).
+		Where("payment_intent_id = ? AND user_id = ? AND reservation_id IS NULL", getPaymentIntentID(reservation.PaymentIntent), reservation.UserID).
+		Update("reservation_id", reservation.ID).Error
+}
+
+// releaseReservationItems restocks the concessions of the cancelled
+// reservation.
+func releaseReservationItems(tx *gorm.DB, reservation models.Reservation) error {
+	var items []models.ReservationItem
+	if err := tx.Where("reservation_id = ?", reservation.ID).Find(&items).Error; err != nil {
+		return err
+	}
+	return restockConcessions(tx, items)
+}
+
+// fulfilReservationItems marks the concessions of the reservation as handed
+// over at the counter, and returns the ones to hand over.
+func fulfilReservationItems(tx *gorm.DB, reservationID uint) ([]models.ReservationItem, error) {
+	var items []models.ReservationItem
+	err := tx.Where("reservation_id = ? AND fulfilled = ?", reservationID, false).Find(&items).Error
+	if err != nil || len(items) == 0 {
+		return items, err
+	}
+
+	err = tx.Model(&models.ReservationItem{}).
+		Where("reservation_id = ? AND fulfilled = ?", reservationID, false).
+		Update("fulfilled", true).Error
+	return items, err
+}
+
+func isConcessionError(err error) bool {
+	return strings.HasPrefix(err.Error(), "CONCESSION_") || err.Error() == "INVALID_CONCESSIONS"
+}
@@ -0,0 +1,319 @@
+package reservations
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+// GetReservationTicket renders the ticket of the reservation as a PDF file.
+func (reservationsRepo *ReservationsRepo) GetReservationTicket(reservationID uint, userID uint) (int, map[string]interface{}) {
+	if reservationID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_RESERVATION_ID",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	reservation, err := getNotifiedReservation(database.Where("user_id = ?", userID), reservationID)
+	if err != nil || reservation.Diffusion.Hall == nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "RESERVATION_NOT_FOUND",
+		}
+	}
+
+	ticket, err := renderTicket(reservation)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "RENDERING_TICKET_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"pdf": ticket,
+	}
+}
+
+// GetReservationInvoice renders the invoice issued for the reservation as a
+// PDF file.
+func (reservationsRepo *ReservationsRepo) GetReservationInvoice(reservationID uint, userID uint) (int, map[string]interface{}) {
+	if reservationID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_RESERVATION_ID",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var invoice models.Invoice
+	err := database.Where("reservation_id = ? AND user_id = ?", reservationID, userID).First(&invoice).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "INVOICE_NOT_FOUND",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"pdf": renderInvoice(invoice),
+	}
+}
+
+// IssueReservationInvoice issues the invoice of the reservation to the
+// billing, once, and renders it as a PDF file.
+func (reservationsRepo *ReservationsRepo) IssueReservationInvoice(reservationID uint, userID uint, billing models.InvoiceBilling) (int, map[string]interface{}) {
+	if reservationID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_RESERVATION_ID",
+		}
+	}
+	if err := billing.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var invoice models.Invoice
+	err := database.Where("reservation_id = ? AND user_id = ?", reservationID, userID).First(&invoice).Error
+	if err == nil {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "INVOICE_ALREADY_ISSUED",
+		}
+	}
+
+	reservation, err := getNotifiedReservation(database.Where("user_id = ?", userID), reservationID)
+	if err != nil || reservation.Diffusion.Hall == nil || reservation.Diffusion.Hall.Cinema == nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "RESERVATION_NOT_FOUND",
+		}
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = issueInvoice(tx, reservation, billing)
+		return err
+	})
+	if err != nil {
+		// The invoice may have been issued meanwhile:
+		if database.Where("reservation_id = ?", reservationID).First(&models.Invoice{}).Error == nil {
+			return http.StatusConflict, map[string]interface{}{
+				"error": "INVOICE_ALREADY_ISSUED",
+			}
+		}
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ISSUING_INVOICE_FAILED",
+		}
+	}
+
+	return http.StatusCreated, map[string]interface{}{
+		"pdf": renderInvoice(invoice),
+	}
+}
+
+// issueInvoice issues the invoice of the reservation, with the next number of
+// the year.
+func issueInvoice(tx *gorm.DB, reservation models.Reservation, billing models.InvoiceBilling) (models.Invoice, error) {
+	cinema := reservation.Diffusion.Hall.Cinema
+	issuedAt := time.Now()
+	year := issuedAt.In(cinema.Location()).Year()
+
+	// Lock the sequence of the year, so that numbers follow each other:
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InvoiceSequence{Year: year}).Error
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	var sequence models.InvoiceSequence
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("year = ?", year).First(&sequence).Error
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	sequence.Sequence++
+	err = tx.Model(&sequence).Update("sequence", sequence.Sequence).Error
+	if err != nil {
+		return models.Invoice{}, err
+	}
+
+	if billing.Name == "" {
+		var user models.User
+		tx.Select("id", "full_name").Where("id = ?", reservation.UserID).First(&user)
+		billing.Name = user.FullName
+	}
+
+	invoice := models.Invoice{
+		Number:        models.InvoiceNumber(year, sequence.Sequence),
+		Year:          year,
+		Sequence:      sequence.Sequence,
+		ReservationID: reservation.ID,
+		UserID:        reservation.UserID,
+		CinemaID:      cinema.ID,
+		Seller:        cinema.Name,
+		SellerAddress: cinema.Address,
+		Billing:       billing,
+		Lines:         invoiceLines(reservation),
+		PaymentMethod: reservation.PaymentMethod,
+		Currency:      reservation.Currency,
+		IssuedAt:      issuedAt,
+	}
+	if cinema.VATRate != nil {
+		invoice.VATRate = *cinema.VATRate
+	}
+	invoice.SetAmounts()
+
+	err = tx.Create(&invoice).Error
+	return invoice, err
+}
+
+// invoiceLines bills the seats and the concessions of the reservation, the
+// seats bearing the discounts so that the lines add up to what was paid.
+func invoiceLines(reservation models.Reservation) []models.InvoiceLine {
+	var itemsLines []models.InvoiceLine
+	var itemsAmount uint
+	for _, item := range reservation.Items {
+		itemsLines = append(itemsLines, models.InvoiceLine{
+			Description: item.Name,
+			Quantity:    item.Quantity,
+			Amount:      uint(item.Amount()),
+		})
+		itemsAmount += uint(item.Amount())
+	}
+
+	var ticketsAmount uint
+	if reservation.Amount > itemsAmount {
+		ticketsAmount = reservation.Amount - itemsAmount
+	}
+	diffusion := reservation.Diffusion
+	ticketsLine := models.InvoiceLine{
+		Description: fmt.Sprintf("Tickets, %v, %v", diffusion.Movie.Title, diffusion.ShowTime.In(diffusion.Hall.Location()).Format("02/01/2006 15:04")),
+		Quantity:    uint(len(reservation.Seats)),
+		Amount:      ticketsAmount,
+	}
+
+	return append([]models.InvoiceLine{ticketsLine}, itemsLines...)
+}
+
+// renderTicket renders the ticket of the reservation, its diffusion, seats and
+// concessions being loaded.
+func renderTicket(reservation models.Reservation) ([]byte, error) {
+	if reservation.TicketCode == nil {
+		return nil, errors.New("MISSING_TICKET_CODE")
+	}
+
+	diffusion := reservation.Diffusion
+	hall := diffusion.Hall
+
+	document := tools.NewPDFDocument()
+	page := document.AddPage()
+
+	page.Text(50, 70, 24, true, "KINEMA")
+	page.Text(50, 92, 11, false, "Ticket")
+	page.Line(50, 105, tools.PDFPageWidth-50, 105, 1)
+
+	page.Text(50, 140, 18, true, diffusion.Movie.Title)
+
+	var seatLabels []string
+	for _, seat := range reservation.Seats {
+		seatLabels = append(seatLabels, models.SeatLabel(seat.SeatRow, seat.SeatColumn))
+	}
+	location := hall.Location()
+	details := [][2]string{
+		{"Reservation", fmt.Sprintf("#%v", reservation.ID)},
+		{"Hall", hall.Name},
+		{"Show time", diffusion.ShowTime.In(location).Format("Monday 02 January 2006 15:04 MST")},
+		{"Ends at", diffusion.ShowTime.Add(diffusion.ShowDuration).In(location).Format("15:04")},
+		{"Seats", strings.Join(seatLabels, ", ")},
+	}
+	if hall.Cinema != nil {
+		details = append([][2]string{{"Cinema", hall.Cinema.Name}}, details...)
+	}
+	for _, item := range reservation.Items {
+		details = append(details, [2]string{"Concession", fmt.Sprintf("%v x %v", item.Quantity, item.Name)})
+	}
+
+	y := 175.0
+	for _, detail := range details {
+		page.Text(50, y, 11, true, detail[0])
+		page.Text(160, y, 11, false, detail[1])
+		y += 20
+	}
+
+	code := *reservation.TicketCode
+	if err := page.Barcode(50, y+20, 1.2, 60, code); err != nil {
+		return nil, err
+	}
+	page.Text(50, y+95, 10, false, code)
+	page.Text(50, y+125, 9, false, "Show this ticket at the entrance.")
+
+	return document.Bytes(), nil
+}
+
+// renderInvoice renders the invoice as it was issued.
+func renderInvoice(invoice models.Invoice) []byte {
+	document := tools.NewPDFDocument()
+	page := document.AddPage()
+
+	page.Text(50, 70, 24, true, "INVOICE")
+	page.Text(50, 92, 11, false, fmt.Sprintf("Number: %v", invoice.Number))
+	page.Text(50, 108, 11, false, fmt.Sprintf("Date: %v", invoice.IssuedAt.Format("02/01/2006")))
+	page.Text(50, 124, 11, false, fmt.Sprintf("Reservation: #%v", invoice.ReservationID))
+
+	// Seller and buyer:
+	page.Text(50, 165, 11, true, "From")
+	page.Text(50, 181, 11, false, invoice.Seller)
+	page.Text(50, 197, 11, false, invoice.SellerAddress)
+	page.Text(320, 165, 11, true, "Billed to")
+	page.Text(320, 181, 11, false, invoice.Billing.Name)
+	page.Text(320, 197, 11, false, invoice.Billing.Address)
+	if invoice.Billing.VATNumber != "" {
+		page.Text(320, 213, 11, false, fmt.Sprintf("VAT number: %v", invoice.Billing.VATNumber))
+	}
+
+	// Lines:
+	y := 260.0
+	page.Text(50, y, 11, true, "Description")
+	page.Text(380, y, 11, true, "Quantity")
+	page.Text(460, y, 11, true, "Amount")
+	page.Line(50, y+8, tools.PDFPageWidth-50, y+8, 0.5)
+	y += 28
+	for _, line := range invoice.Lines {
+		description := line.Description
+		if len(description) > 60 {
+			description = description[:57] + "..."
+		}
+		page.Text(50, y, 10, false, description)
+		page.Text(380, y, 10, false, fmt.Sprint(line.Quantity))
+		page.Text(460, y, 10, false, formatAmount(int64(line.Amount), invoice.Currency))
+		y += 18
+	}
+	page.Line(50, y-6, tools.PDFPageWidth-50, y-6, 0.5)
+
+	// Tax breakdown:
+	y += 14
+	totals := [][2]string{
+		{"Net amount", formatAmount(int64(invoice.NetAmount), invoice.Currency)},
+		{fmt.Sprintf("VAT %v%%", strconv.FormatFloat(math.Round(invoice.VATRate*10000)/100, 'f', -1, 64)), formatAmount(int64(invoice.TaxAmount), invoice.Currency)},
+		{"Total", formatAmount(int64(invoice.Amount), invoice.Currency)},
+	}
+	for index, total := range totals {
+		bold := index == len(totals)-1
+		page.Text(320, y, 11, bold, total[0])
+		page.Text(460, y, 11, bold, total[1])
+		y += 18
+	}
+	page.Text(50, y+10, 10, false, fmt.Sprintf("Paid by %v.", strings.ReplaceAll(invoice.PaymentMethod, "+", " and ")))
+
+	page.Barcode(50, y+40, 1, 45, invoice.Number)
+	page.Text(50, y+100, 9, false, invoice.Number)
+
+	return document.Bytes()
+}
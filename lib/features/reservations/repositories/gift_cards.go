@@ -0,0 +1,334 @@
+package reservations
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	loyalty "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/loyalty"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	stripe "github.com/stripe/stripe-go"
+	paymentintent "github.com/stripe/stripe-go/paymentintent"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+// Payments fully covered by gift cards are not sent to Stripe. They get a
+// reference shaped like a payment intent, starting with this prefix:
+const giftCardPaymentPrefix = "gc_"
+
+var errGiftCardBalance = errors.New("GIFT_CARD_BALANCE_CHANGED")
+
+var giftCardsListing = tools.ListingOptions{
+	Model: models.GiftCard{},
+	SortFields: map[string]string{
+		"id":        "id",
+		"createdAt": "created_at",
+		"balance":   "balance",
+	},
+	DefaultSort:  "-id",
+	DefaultLimit: tools.DefaultListingLimit,
+}
+
+func isGiftCardPayment(paymentIntentID string) bool {
+	return strings.HasPrefix(paymentIntentID, giftCardPaymentPrefix)
+}
+
+// cancelPayment makes sure that the payment intent cannot be paid anymore.
+func cancelPayment(paymentIntentID string) error {
+	if isGiftCardPayment(paymentIntentID) || isPendingPayment(paymentIntentID) {
+		return nil
+	}
+	payment, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil {
+		return err
+	}
+	if payment.Status != stripe.PaymentIntentStatusCanceled {
+		_, err = paymentintent.Cancel(paymentIntentID, nil)
+	}
+	return err
+}
+
+func (reservationsRepo *ReservationsRepo) CreateGiftCardIntent(amount int64, currency string, userID uint) (int, map[string]interface{}) {
+	if amount < loyalty.Instance.MinimumCharge || len(currency) != 3 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ARGS",
+		}
+	}
+
+	params := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(amount),
+		Currency: stripe.String(strings.ToLower(currency)),
+	}
+	params.AddMetadata("userID", strconv.FormatUint(uint64(userID), 10))
+	params.AddMetadata("giftCard", "true")
+
+	payment, err := paymentintent.New(params)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "CREATING_PAYMENT_INTENT_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"paymentIntent": payment.ClientSecret,
+		"amount":        payment.Amount,
+		"currency":      payment.Currency,
+	}
+}
+
+// AddGiftCard issues the gift card bought through the payment intent.
+func (reservationsRepo *ReservationsRepo) AddGiftCard(paymentIntent string, userID uint) (int, map[string]interface{}) {
+	if !strings.Contains(paymentIntent, "_") || isGiftCardPayment(paymentIntent) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_PAYMENT_INTENT",
+		}
+	}
+	paymentIntentID := getPaymentIntentID(paymentIntent)
+
+	payment, err := paymentintent.Get(paymentIntentID, nil)
+	if err != nil || payment.Metadata["giftCard"] != "true" || payment.Metadata["userID"] != strconv.FormatUint(uint64(userID), 10) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_PAYMENT_INTENT",
+		}
+	}
+	if payment.Status != stripe.PaymentIntentStatusSucceeded {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "PAYMENT_HAS_NOT_BEEN_EFFECTED",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	err = database.Where("payment_intent_id = ?", paymentIntentID).First(&models.GiftCard{}).Error
+	if err == nil {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "GIFT_CARD_ALREADY_ADDED",
+		}
+	}
+
+	code, err := models.NewGiftCardCode()
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ADDING_GIFT_CARD_FAILED",
+		}
+	}
+	giftCard := models.GiftCard{
+		Code:            code,
+		Currency:        payment.Currency,
+		InitialBalance:  payment.Amount,
+		Balance:         payment.Amount,
+		PurchaserID:     userID,
+		PaymentIntentID: paymentIntentID,
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&giftCard).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.GiftCardTransaction{
+			GiftCardID:      giftCard.ID,
+			UserID:          userID,
+			Kind:            models.GiftCardPurchase,
+			Amount:          giftCard.Balance,
+			PaymentIntentID: paymentIntentID,
+		}).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ADDING_GIFT_CARD_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":  "GIFT_CARD_ADDED",
+		"giftCard": giftCard,
+	}
+}
+
+// GetGiftCard returns the balance of the gift card with the code.
+func (reservationsRepo *ReservationsRepo) GetGiftCard(code string) (int, map[string]interface{}) {
+	code = models.NormalizeGiftCardCode(code)
+	if code == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_CODE",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var giftCard models.GiftCard
+	err := database.Where("code = ?", code).First(&giftCard).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "GIFT_CARD_NOT_FOUND",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"giftCard": giftCard,
+	}
+}
+
+// GetUserGiftCards returns the gift cards the user bought.
+func (reservationsRepo *ReservationsRepo) GetUserGiftCards(userID uint, listing tools.ListingQuery) (int, map[string]interface{}) {
+	if err := listing.Validate(giftCardsListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var giftCards []models.GiftCard
+	page, err := tools.FindPage(database.Model(&models.GiftCard{}).Where("purchaser_id = ?", userID), &giftCards, listing)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_GIFT_CARDS_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":      len(giftCards),
+		"total":      page.Total,
+		"nextCursor": page.NextCursor,
+		"giftCards":  tools.SelectFields(giftCards, listing.Fields),
+	}
+}
+
+// getSpendableGiftCard fetches the gift card, locked within transactions, and
+// checks that it can pay in the currency.
+func getSpendableGiftCard(tx *gorm.DB, code string, currency string) (models.GiftCard, int, error) {
+	var giftCard models.GiftCard
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", models.NormalizeGiftCardCode(code)).
+		First(&giftCard).Error
+	if err != nil {
+		return giftCard, http.StatusNotFound, errors.New("GIFT_CARD_NOT_FOUND")
+	}
+	if err := giftCard.Spendable(currency); err != nil {
+		return giftCard, http.StatusBadRequest, err
+	}
+	return giftCard, http.StatusOK, nil
+}
+
+// addGiftCardAmount changes the balance of the gift card and records it.
+func addGiftCardAmount(tx *gorm.DB, transaction models.GiftCardTransaction) error {
+	if transaction.Amount == 0 {
+		return nil
+	}
+
+	query := tx.Model(&models.GiftCard{}).Where("id = ?", transaction.GiftCardID)
+	if transaction.Amount < 0 {
+		query = query.Where("balance >= ?", -transaction.Amount)
+	}
+	result := query.Update("balance", gorm.Expr("balance + ?", transaction.Amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errGiftCardBalance
+	}
+
+	return tx.Create(&transaction).Error
+}
+
+// getGiftCardTenders returns the gift card redemptions the user holds for the
+// payment intent and that were not given back yet.
+func getGiftCardTenders(tx *gorm.DB, paymentIntentID string, userID uint) ([]models.GiftCardTransaction, error) {
+	var tenders []models.GiftCardTransaction
+	err := tx.Where("payment_intent_id = ? AND user_id = ? AND kind = ? AND reservation_id IS NULL AND released = ?", paymentIntentID, userID, models.GiftCardRedeem, false).
+		Preload("GiftCard").
+		Find(&tenders).Error
+	return tenders, err
+}
+
+// settleGiftCardTenders ties the gift card redemptions held for the payment
+// intent to the reservation.
+func settleGiftCardTenders(tx *gorm.DB, reservation models.Reservation, tenders []models.GiftCardTransaction) error {
+	if len(tenders) == 0 {
+		return nil
+	}
+
+	var tenderIDs []uint
+	for _, tender := range tenders {
+		tenderIDs = append(tenderIDs, tender.ID)
+	}
+	result := tx.Model(&models.GiftCardTransaction{}).
+		Where("id IN ? AND reservation_id IS NULL AND released = ?", tenderIDs, false).
+		Update("reservation_id", reservation.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(tenderIDs)) {
+		return errGiftCardBalance
+	}
+	return nil
+}
+
+// refundGiftCardTenders credits back the gift cards the reservation was paid
+// with.
+func refundGiftCardTenders(tx *gorm.DB, reservation models.Reservation) error {
+	var tenders []models.GiftCardTransaction
+	err := tx.Where("reservation_id = ? AND kind = ?", reservation.ID, models.GiftCardRedeem).Find(&tenders).Error
+	if err != nil {
+		return err
+	}
+
+	for _, tender := range tenders {
+		err := addGiftCardAmount(tx, models.GiftCardTransaction{
+			GiftCardID:    tender.GiftCardID,
+			UserID:        reservation.UserID,
+			Kind:          models.GiftCardRefund,
+			Amount:        -tender.Amount,
+			ReservationID: &reservation.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseGiftCardRedemptions gives back the gift card amounts held for the
+// payment intent. Each hold is marked released before its amount is given
+// back, so that concurrent releases give it back once.
+func releaseGiftCardRedemptions(tx *gorm.DB, paymentIntentID string) error {
+	var holds []models.GiftCardTransaction
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_intent_id = ? AND kind = ? AND reservation_id IS NULL AND released = ?", paymentIntentID, models.GiftCardRedeem, false).
+		Find(&holds).Error
+	if err != nil {
+		return err
+	}
+
+	for _, hold := range holds {
+		result := tx.Model(&models.GiftCardTransaction{}).
+			Where("id = ? AND reservation_id IS NULL AND released = ?", hold.ID, false).
+			Update("released", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		err := addGiftCardAmount(tx, models.GiftCardTransaction{
+			GiftCardID:      hold.GiftCardID,
+			UserID:          hold.UserID,
+			Kind:            models.GiftCardRelease,
+			Amount:          -hold.Amount,
+			PaymentIntentID: hold.PaymentIntentID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isGiftCardError(err error) bool {
+	return strings.HasPrefix(err.Error(), "GIFT_CARD_")
+}
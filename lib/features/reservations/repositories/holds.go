@@ -0,0 +1,80 @@
+package reservations
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	gorm "gorm.io/gorm"
+)
+
+const HoldsReleaseInterval = 5 * time.Minute
+
+// StartHoldsRelease releases the holds of the payment intents that were not
+// paid in time every interval, until the context is done.
+func (reservationsRepo *ReservationsRepo) StartHoldsRelease(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reservationsRepo.ReleaseStaleHolds()
+			}
+		}
+	}()
+}
+
+// ReleaseStaleHolds gives back what the payment intents that were not paid in
+// time hold, without waiting for their users to pay again, and passes the
+// seats of the expired waitlist offers to the next waiting parties.
+func (reservationsRepo *ReservationsRepo) ReleaseStaleHolds() {
+	reservationsRepo.releaseStalePaymentHolds()
+	reservationsRepo.processExpiredOffers()
+}
+
+// releaseStalePaymentHolds cancels the payment intents that have held points,
+// promo codes, gift card amounts or concessions for too long, then gives back
+// all they hold. The holds of an intent that cannot be canceled are kept, as
+// it could still be paid.
+func (reservationsRepo *ReservationsRepo) releaseStalePaymentHolds() {
+	database := reservationsRepo.database
+	staleBefore := time.Now().Add(-redemptionHoldDuration)
+
+	var paymentIntentIDs []string
+	for _, holds := range []*gorm.DB{
+		database.Model(&models.LoyaltyEntry{}).Where("kind = ? AND reservation_id IS NULL AND released = ?", models.LoyaltyRedeem, false),
+		database.Model(&models.PromoRedemption{}).Where("reservation_id IS NULL"),
+		database.Model(&models.GiftCardTransaction{}).Where("kind = ? AND reservation_id IS NULL AND released = ?", models.GiftCardRedeem, false),
+		database.Model(&models.ReservationItem{}).Where("reservation_id IS NULL"),
+	} {
+		var stalePaymentIntentIDs []string
+		err := holds.Where("created_at < ?", staleBefore).Distinct().Pluck("payment_intent_id", &stalePaymentIntentIDs).Error
+		if err != nil {
+			log.Printf("getting stale payment holds failed: %v", err.Error())
+			continue
+		}
+		paymentIntentIDs = append(paymentIntentIDs, stalePaymentIntentIDs...)
+	}
+	slices.Sort(paymentIntentIDs)
+	paymentIntentIDs = slices.Compact(paymentIntentIDs)
+
+	for _, paymentIntentID := range paymentIntentIDs {
+		// The intent must not be paid once its holds are given back:
+		if err := cancelPayment(paymentIntentID); err != nil {
+			continue
+		}
+
+		err := database.Transaction(func(tx *gorm.DB) error {
+			return releasePaymentHolds(tx, paymentIntentID)
+		})
+		if err != nil {
+			log.Printf("releasing payment %v holds failed: %v", paymentIntentID, err.Error())
+		}
+	}
+}
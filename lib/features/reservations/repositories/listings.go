@@ -0,0 +1,16 @@
+package reservations
+
+import (
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+)
+
+var reservationsListing = tools.ListingOptions{
+	Model: models.Reservation{},
+	SortFields: map[string]string{
+		"id":        "id",
+		"createdAt": "created_at",
+		"amount":    "amount",
+	},
+	DefaultSort: "-id",
+}
@@ -0,0 +1,145 @@
+package reservations
+
+import (
+	"errors"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	loyalty "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/loyalty"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+// Redeemed points are held from the creation of the payment intent. They are
+// given back when no reservation was paid with the intent after this delay:
+const redemptionHoldDuration = time.Hour
+
+var errInsufficientPoints = errors.New("INSUFFICIENT_POINTS")
+
+// addPoints changes the balance of the user and records it in the ledger.
+func addPoints(tx *gorm.DB, entry models.LoyaltyEntry) error {
+	if entry.Points == 0 {
+		return nil
+	}
+
+	query := tx.Model(&models.User{}).Where("id = ?", entry.UserID)
+	if entry.Points < 0 {
+		query = query.Where("fidelity_points >= ?", -entry.Points)
+	}
+	result := query.Update("fidelity_points", gorm.Expr("fidelity_points + ?", entry.Points))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientPoints
+	}
+
+	return tx.Create(&entry).Error
+}
+
+// settleReservationPoints ties the points redeemed through the payment intent
+// to the reservation, and grants the points its payment earns. Only the part
+// charged by Stripe earns points, not the one paid with gift cards.
+func settleReservationPoints(tx *gorm.DB, reservation models.Reservation) error {
+	err := tx.Model(&models.LoyaltyEntry{}).
+		Where("payment_intent_id = ? AND kind = ? AND reservation_id IS NULL AND released = ?", getPaymentIntentID(reservation.PaymentIntent), models.LoyaltyRedeem, false).
+		Update("reservation_id", reservation.ID).Error
+	if err != nil {
+		return err
+	}
+
+	chargedAmount := int64(reservation.Amount) - int64(reservation.GiftCardAmount)
+	points := loyalty.Instance.PointsEarned(max(chargedAmount, 0), reservation.Currency)
+	return addPoints(tx, models.LoyaltyEntry{
+		UserID:        reservation.UserID,
+		Kind:          models.LoyaltyEarn,
+		Points:        int(points),
+		ReservationID: &reservation.ID,
+	})
+}
+
+// reverseReservationPoints takes back the points the reservation earned and
+// gives back the ones redeemed to pay it. Points already spent cannot be taken
+// back, so the balance never goes below zero.
+func reverseReservationPoints(tx *gorm.DB, reservation models.Reservation) error {
+	var entries []models.LoyaltyEntry
+	err := tx.Where("reservation_id = ? AND kind IN ?", reservation.ID, []string{models.LoyaltyEarn, models.LoyaltyRedeem}).
+		Find(&entries).Error
+	if err != nil {
+		return err
+	}
+
+	points := 0
+	for _, entry := range entries {
+		points -= entry.Points
+	}
+	if points < 0 {
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "fidelity_points").
+			Where("id = ?", reservation.UserID).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+		points = max(points, -int(user.FidelityPoints))
+	}
+
+	return addPoints(tx, models.LoyaltyEntry{
+		UserID:        reservation.UserID,
+		Kind:          models.LoyaltyReversal,
+		Points:        points,
+		ReservationID: &reservation.ID,
+	})
+}
+
+// getRedeemedPoints returns the points held for the payment intent, locking
+// them within transactions.
+func getRedeemedPoints(tx *gorm.DB, paymentIntentID string) (int64, error) {
+	var holds []models.LoyaltyEntry
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_intent_id = ? AND kind = ? AND reservation_id IS NULL AND released = ?", paymentIntentID, models.LoyaltyRedeem, false).
+		Find(&holds).Error
+
+	var points int64
+	for _, hold := range holds {
+		points -= int64(hold.Points)
+	}
+	return points, err
+}
+
+// releaseRedeemedPoints gives back the points held for the payment intent.
+// Each hold is marked released before its points are given back, so that
+// concurrent releases give them back once.
+func releaseRedeemedPoints(tx *gorm.DB, paymentIntentID string) error {
+	var holds []models.LoyaltyEntry
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_intent_id = ? AND kind = ? AND reservation_id IS NULL AND released = ?", paymentIntentID, models.LoyaltyRedeem, false).
+		Find(&holds).Error
+	if err != nil {
+		return err
+	}
+
+	for _, hold := range holds {
+		result := tx.Model(&models.LoyaltyEntry{}).
+			Where("id = ? AND reservation_id IS NULL AND released = ?", hold.ID, false).
+			Update("released", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		err := addPoints(tx, models.LoyaltyEntry{
+			UserID:          hold.UserID,
+			Kind:            models.LoyaltyRelease,
+			Points:          -hold.Points,
+			PaymentIntentID: hold.PaymentIntentID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
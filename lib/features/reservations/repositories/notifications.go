@@ -0,0 +1,303 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	notifications "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/notifications"
+	gorm "gorm.io/gorm"
+)
+
+const (
+	NotificationsDispatchInterval = time.Minute
+	notificationsDispatchBatch    = 100
+	// Failed notifications are retried after this delay times their attempts:
+	notificationRetryDelay = 5 * time.Minute
+	// Notifications still sending after this delay were left by a dispatch that
+	// stopped, and are sent again:
+	notificationSendingTimeout = 10 * time.Minute
+)
+
+// StartNotificationsDispatch sends the due notifications every interval,
+// until the context is done.
+func (reservationsRepo *ReservationsRepo) StartNotificationsDispatch(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reservationsRepo.DispatchNotifications()
+			}
+		}
+	}()
+}
+
+// DispatchNotifications sends the pending notifications that are due, and
+// records how each delivery went.
+func (reservationsRepo *ReservationsRepo) DispatchNotifications() {
+	database := reservationsRepo.database
+
+	// Reclaim the notifications a stopped dispatch was sending:
+	database.Model(&models.Notification{}).
+		Where("status = ? AND updated_at < ?", models.NotificationSending, time.Now().Add(-notificationSendingTimeout)).
+		Update("status", models.NotificationPending)
+
+	var dueNotifications []models.Notification
+	err := database.Where("status = ? AND send_at <= ?", models.NotificationPending, time.Now()).
+		Order("send_at ASC").
+		Limit(notificationsDispatchBatch).
+		Find(&dueNotifications).Error
+	if err != nil {
+		return
+	}
+
+	for _, notification := range dueNotifications {
+		// Claim the notification, another dispatch may be sending it:
+		result := database.Model(&models.Notification{}).
+			Where("id = ? AND status = ?", notification.ID, models.NotificationPending).
+			Update("status", models.NotificationSending)
+		if result.Error != nil || result.RowsAffected != 1 {
+			continue
+		}
+
+		// Confirmations and reminders are only sent while their reservation
+		// exists, it is gone when its diffusion or movie was deleted:
+		var reservation models.Reservation
+		var err error
+		if notification.Kind == models.NotificationConfirmation || notification.Kind == models.NotificationReminder {
+			reservation, err = getNotifiedReservation(database, notification.ReservationID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				database.Model(&notification).Update("status", models.NotificationCanceled)
+				continue
+			}
+		}
+
+		message := notifications.Message{
+			To:      notification.Email,
+			Subject: notification.Subject,
+			Body:    notification.Body,
+		}
+		if notification.Kind == models.NotificationConfirmation {
+			if err == nil {
+				if ticket, err := reservationTicket(reservation); err == nil {
+					message.Attachments = append(message.Attachments, ticket)
+				}
+				message.Attachments = append(message.Attachments, notifications.Attachment{
+					Name:        fmt.Sprintf("reservation-%v.ics", reservation.ID),
+					ContentType: "text/calendar",
+					Content:     reservationCalendar(reservation),
+				})
+			}
+		}
+
+		err = notifications.Instance.Send(message)
+
+		notification.Attempts++
+		updates := map[string]interface{}{
+			"attempts": notification.Attempts,
+		}
+		if err == nil {
+			updates["status"] = models.NotificationSent
+			updates["sent_at"] = time.Now()
+			updates["last_error"] = ""
+		} else {
+			log.Printf("sending notification %v failed: %v", notification.ID, err.Error())
+			updates["last_error"] = err.Error()
+			if notification.Attempts >= notifications.Instance.MaxAttempts {
+				updates["status"] = models.NotificationFailed
+			} else {
+				updates["status"] = models.NotificationPending
+				updates["send_at"] = time.Now().Add(time.Duration(notification.Attempts) * notificationRetryDelay)
+			}
+		}
+		database.Model(&notification).Updates(updates)
+	}
+}
+
+func (reservationsRepo *ReservationsRepo) GetReservationNotifications(reservationID uint, userID uint) (int, map[string]interface{}) {
+	if reservationID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_RESERVATION_ID",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var reservationNotifications []models.Notification
+	err := database.Where("reservation_id = ? AND user_id = ?", reservationID, userID).
+		Order("send_at ASC").
+		Find(&reservationNotifications).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_NOTIFICATIONS_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":         len(reservationNotifications),
+		"notifications": reservationNotifications,
+	}
+}
+
+// queueReservationAdded queues the confirmation of the reservation, and its
+// reminder when the show is far enough.
+func queueReservationAdded(tx *gorm.DB, reservation models.Reservation) error {
+	user, err := loadNotificationDetails(tx, &reservation)
+	if err != nil {
+		return err
+	}
+
+	summary := reservationSummary(reservation)
+	now := time.Now()
+	queued := []models.Notification{
+		{
+			Kind:    models.NotificationConfirmation,
+			Subject: fmt.Sprintf("Your reservation for %v is confirmed", reservation.Diffusion.Movie.Title),
+			Body:    fmt.Sprintf("Thank you for your reservation! Your ticket is attached, show it at the entrance.\n\n%v", summary),
+			SendAt:  now,
+		},
+	}
+
+	remindAt := reservation.Diffusion.ShowTime.Add(-notifications.Instance.ReminderBefore)
+	if remindAt.After(now) {
+		queued = append(queued, models.Notification{
+			Kind:    models.NotificationReminder,
+			Subject: fmt.Sprintf("%v is coming soon", reservation.Diffusion.Movie.Title),
+			Body:    fmt.Sprintf("Your show is coming soon, see you there!\n\n%v", summary),
+			SendAt:  remindAt,
+		})
+	}
+
+	for index := range queued {
+		queued[index].ReservationID = reservation.ID
+		queued[index].UserID = reservation.UserID
+		queued[index].Email = user.Email
+		queued[index].Status = models.NotificationPending
+	}
+	return tx.Create(&queued).Error
+}
+
+// dropReservationNotifications cancels the notifications of the reservation
+// that were not sent yet.
+func dropReservationNotifications(tx *gorm.DB, reservation models.Reservation) error {
+	return tx.Model(&models.Notification{}).
+		Where("reservation_id = ? AND status = ?", reservation.ID, models.NotificationPending).
+		Update("status", models.NotificationCanceled).Error
+}
+
+// queueReservationCanceled queues the receipt of the cancellation of the
+// reservation, its details being loaded, with the reference of its refund.
+func queueReservationCanceled(tx *gorm.DB, reservation models.Reservation, user models.User, refundID string) error {
+	var refunds []string
+	if reservation.Amount > reservation.GiftCardAmount {
+		refunds = append(refunds, fmt.Sprintf("%v to your %v", formatAmount(int64(reservation.Amount-reservation.GiftCardAmount), reservation.Currency), strings.Split(reservation.PaymentMethod, "+")[0]))
+	}
+	if reservation.GiftCardAmount > 0 {
+		refunds = append(refunds, fmt.Sprintf("%v to your gift cards", formatAmount(int64(reservation.GiftCardAmount), reservation.Currency)))
+	}
+	receipt := "Nothing was charged, so nothing is refunded."
+	if len(refunds) > 0 {
+		receipt = fmt.Sprintf("Refunded: %v.", strings.Join(refunds, ", "))
+	}
+	if refundID != "" {
+		receipt += fmt.Sprintf(" Refund reference: %v.", refundID)
+	}
+
+	receiptNotification := models.Notification{
+		ReservationID: reservation.ID,
+		UserID:        reservation.UserID,
+		Kind:          models.NotificationCancellation,
+		Email:         user.Email,
+		Subject:       fmt.Sprintf("Your reservation for %v is canceled", reservation.Diffusion.Movie.Title),
+		Body:          fmt.Sprintf("Your reservation was canceled. %v\n\n%v", receipt, reservationSummary(reservation)),
+		Status:        models.NotificationPending,
+		SendAt:        time.Now(),
+	}
+	return tx.Create(&receiptNotification).Error
+}
+
+// getNotifiedReservation fetches the reservation with what notifications tell
+// about it.
+func getNotifiedReservation(tx *gorm.DB, reservationID uint) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := tx.Preload("Seats").
+		Preload("Items").
+		Preload("Diffusion.Movie").
+		Preload("Diffusion.Hall.Cinema").
+		Where("id = ?", reservationID).
+		First(&reservation).Error
+	return reservation, err
+}
+
+// loadNotificationDetails loads the diffusion and the concessions of the
+// reservation, and returns its user.
+func loadNotificationDetails(tx *gorm.DB, reservation *models.Reservation) (models.User, error) {
+	var user models.User
+	err := tx.Select("id", "email").Where("id = ?", reservation.UserID).First(&user).Error
+	if err != nil {
+		return user, err
+	}
+
+	err = tx.Preload("Movie").Preload("Hall.Cinema").Where("id = ?", reservation.DiffusionID).First(&reservation.Diffusion).Error
+	if err != nil {
+		return user, err
+	}
+	if reservation.Diffusion.Hall == nil {
+		return user, errors.New("INVALID_DIFFUSION_ID")
+	}
+
+	return user, tx.Where("reservation_id = ?", reservation.ID).Find(&reservation.Items).Error
+}
+
+// reservationSummary describes the reservation, its diffusion being loaded.
+func reservationSummary(reservation models.Reservation) string {
+	diffusion := reservation.Diffusion
+	hall := diffusion.Hall
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Reservation: #%v\n", reservation.ID)
+	fmt.Fprintf(&summary, "Movie: %v\n", diffusion.Movie.Title)
+	if hall.Cinema != nil {
+		fmt.Fprintf(&summary, "Cinema: %v, %v\n", hall.Cinema.Name, hall.Name)
+	} else {
+		fmt.Fprintf(&summary, "Hall: %v\n", hall.Name)
+	}
+	fmt.Fprintf(&summary, "Show time: %v\n", diffusion.ShowTime.In(hall.Location()).Format("Monday 02 January 2006 15:04 MST"))
+
+	var seatLabels []string
+	for _, seat := range reservation.Seats {
+		seatLabels = append(seatLabels, models.SeatLabel(seat.SeatRow, seat.SeatColumn))
+	}
+	fmt.Fprintf(&summary, "Seats: %v\n", strings.Join(seatLabels, ", "))
+
+	for _, item := range reservation.Items {
+		fmt.Fprintf(&summary, "Concession: %v x %v\n", item.Quantity, item.Name)
+	}
+	fmt.Fprintf(&summary, "Paid: %v", formatAmount(int64(reservation.Amount), reservation.Currency))
+	return summary.String()
+}
+
+// reservationTicket is the ticket attached to the confirmation.
+func reservationTicket(reservation models.Reservation) (notifications.Attachment, error) {
+	ticket, err := renderTicket(reservation)
+	return notifications.Attachment{
+		Name:        fmt.Sprintf("ticket-%v.pdf", reservation.ID),
+		ContentType: "application/pdf",
+		Content:     ticket,
+	}, err
+}
+
+// formatAmount writes an amount in minor currency units.
+func formatAmount(amount int64, currency string) string {
+	return fmt.Sprintf("%.2f %v", float64(amount)/100, strings.ToUpper(currency))
+}
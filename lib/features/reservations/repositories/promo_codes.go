@@ -0,0 +1,270 @@
+package reservations
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+var promoCodesListing = tools.ListingOptions{
+	Model: models.PromoCode{},
+	SortFields: map[string]string{
+		"id":     "id",
+		"code":   "code",
+		"endsAt": "ends_at",
+	},
+	DefaultSort:  "-id",
+	DefaultLimit: tools.DefaultListingLimit,
+}
+
+func (reservationsRepo *ReservationsRepo) AddPromoCode(promoCode models.PromoCode) (int, map[string]interface{}) {
+	promoCode.ID = 0
+	if err := promoCode.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := reservationsRepo.database
+
+	err := database.Where("code = ?", promoCode.Code).First(&models.PromoCode{}).Error
+	if err == nil {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "PROMO_CODE_ALREADY_EXISTS",
+		}
+	}
+
+	err = database.Create(&promoCode).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "ADDING_PROMO_CODE_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":   "PROMO_CODE_ADDED",
+		"promoCode": promoCode,
+	}
+}
+
+func (reservationsRepo *ReservationsRepo) UpdatePromoCode(newPromoCode models.PromoCode) (int, map[string]interface{}) {
+	if newPromoCode.ID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+	if err := newPromoCode.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var promoCode models.PromoCode
+	err := database.Where("id = ?", newPromoCode.ID).First(&promoCode).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "PROMO_CODE_NOT_FOUND",
+		}
+	}
+
+	err = database.Where("code = ? AND id <> ?", newPromoCode.Code, promoCode.ID).First(&models.PromoCode{}).Error
+	if err == nil {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "PROMO_CODE_ALREADY_EXISTS",
+		}
+	}
+
+	newPromoCode.CreatedAt = promoCode.CreatedAt
+	err = database.Save(&newPromoCode).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "UPDATING_PROMO_CODE_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message":   "PROMO_CODE_UPDATED",
+		"promoCode": newPromoCode,
+	}
+}
+
+func (reservationsRepo *ReservationsRepo) GetPromoCodes(listing tools.ListingQuery) (int, map[string]interface{}) {
+	if err := listing.Validate(promoCodesListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var promoCodes []models.PromoCode
+	page, err := tools.FindPage(database.Model(&models.PromoCode{}), &promoCodes, listing)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "FETCHING_PROMO_CODES_FAILED",
+		}
+	}
+
+	if len(promoCodes) > 0 {
+		var promoCodeIDs []uint
+		for _, promoCode := range promoCodes {
+			promoCodeIDs = append(promoCodeIDs, promoCode.ID)
+		}
+		var usesRows []struct {
+			PromoCodeID uint
+			UsesCount   int64
+		}
+		database.Model(&models.PromoRedemption{}).
+			Select("promo_code_id, COUNT(*) AS uses_count").
+			Where("promo_code_id IN ?", promoCodeIDs).
+			Group("promo_code_id").
+			Scan(&usesRows)
+		usesCounts := make(map[uint]int64)
+		for _, row := range usesRows {
+			usesCounts[row.PromoCodeID] = row.UsesCount
+		}
+		for index := range promoCodes {
+			promoCodes[index].UsesCount = usesCounts[promoCodes[index].ID]
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"count":      len(promoCodes),
+		"total":      page.Total,
+		"nextCursor": page.NextCursor,
+		"promoCodes": tools.SelectFields(promoCodes, listing.Fields),
+	}
+}
+
+// DeletePromoCode deletes an unused promo code, and only deactivates a used
+// one so that the reservations keep their redemptions.
+func (reservationsRepo *ReservationsRepo) DeletePromoCode(id string) (int, map[string]interface{}) {
+	if id == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_ID",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var promoCode models.PromoCode
+	err := database.Where("id = ?", id).First(&promoCode).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "PROMO_CODE_NOT_FOUND",
+		}
+	}
+
+	var usesCount int64
+	database.Model(&models.PromoRedemption{}).Where("promo_code_id = ?", promoCode.ID).Count(&usesCount)
+	if usesCount > 0 {
+		err = database.Model(&promoCode).Update("active", false).Error
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "DEACTIVATING_PROMO_CODE_FAILED",
+			}
+		}
+		return http.StatusOK, map[string]interface{}{
+			"message": "PROMO_CODE_DEACTIVATED",
+		}
+	}
+
+	err = database.Delete(&promoCode).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "DELETING_PROMO_CODE_FAILED",
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "PROMO_CODE_DELETED",
+	}
+}
+
+// CheckPromoCode tells whether the user can use the code to book the
+// diffusion.
+func (reservationsRepo *ReservationsRepo) CheckPromoCode(code string, userID uint, diffusionID uint) (models.PromoCode, int, error) {
+	return getApplicablePromoCode(reservationsRepo.database, code, userID, diffusionID)
+}
+
+// getApplicablePromoCode fetches the promo code, locked within transactions,
+// and checks it against the diffusion and its limits.
+func getApplicablePromoCode(tx *gorm.DB, code string, userID uint, diffusionID uint) (models.PromoCode, int, error) {
+	var promoCode models.PromoCode
+	if diffusionID == 0 {
+		return promoCode, http.StatusBadRequest, errors.New("INVALID_DIFFUSION_ID")
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", models.NormalizePromoCode(code)).
+		First(&promoCode).Error
+	if err != nil {
+		return promoCode, http.StatusNotFound, errors.New("PROMO_CODE_NOT_FOUND")
+	}
+
+	var diffusion models.Diffusion
+	err = tx.Preload("Hall.Cinema").Where("id = ?", diffusionID).First(&diffusion).Error
+	if err != nil {
+		return promoCode, http.StatusBadRequest, errors.New("INVALID_DIFFUSION_ID")
+	}
+	if err := promoCode.CheckDiffusion(diffusion); err != nil {
+		return promoCode, http.StatusBadRequest, err
+	}
+
+	if promoCode.MaxUses > 0 {
+		var usesCount int64
+		tx.Model(&models.PromoRedemption{}).Where("promo_code_id = ?", promoCode.ID).Count(&usesCount)
+		if usesCount >= int64(promoCode.MaxUses) {
+			return promoCode, http.StatusBadRequest, errors.New("PROMO_CODE_USED_UP")
+		}
+	}
+	if promoCode.MaxUsesPerUser > 0 {
+		var userUsesCount int64
+		tx.Model(&models.PromoRedemption{}).Where("promo_code_id = ? AND user_id = ?", promoCode.ID, userID).Count(&userUsesCount)
+		if userUsesCount >= int64(promoCode.MaxUsesPerUser) {
+			return promoCode, http.StatusBadRequest, errors.New("PROMO_CODE_USER_LIMIT_REACHED")
+		}
+	}
+
+	return promoCode, http.StatusOK, nil
+}
+
+// settlePromoRedemption ties the promo code redeemed through the payment
+// intent to the reservation, which must be for the diffusion it was checked
+// against.
+func settlePromoRedemption(tx *gorm.DB, reservation models.Reservation) error {
+	var redemptions []models.PromoRedemption
+	err := tx.Where("payment_intent_id = ? AND reservation_id IS NULL", getPaymentIntentID(reservation.PaymentIntent)).
+		Find(&redemptions).Error
+	if err != nil {
+		return err
+	}
+
+	for _, redemption := range redemptions {
+		if redemption.DiffusionID != reservation.DiffusionID {
+			return errors.New("PROMO_CODE_DIFFUSION_MISMATCH")
+		}
+		err := tx.Model(&redemption).Update("reservation_id", reservation.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releasePromoRedemption frees the use of the promo code the reservation was
+// paid with.
+func releasePromoRedemption(tx *gorm.DB, reservation models.Reservation) error {
+	return tx.Where("reservation_id = ?", reservation.ID).Delete(&models.PromoRedemption{}).Error
+}
+
+func isPromoCodeError(err error) bool {
+	return strings.HasPrefix(err.Error(), "PROMO_CODE_") || err.Error() == "INVALID_DIFFUSION_ID"
+}
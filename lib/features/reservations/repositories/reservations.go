@@ -1,18 +1,44 @@
 package reservations
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	loyalty "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/loyalty"
 	mysql "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/mysql"
 	stripepayment "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/stripe_payment"
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/tools"
 	stripe "github.com/stripe/stripe-go"
 	paymentintent "github.com/stripe/stripe-go/paymentintent"
 	refund "github.com/stripe/stripe-go/refund"
 	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+// Tenders are held before the payment intent exists, under a reference
+// starting with this prefix. They are moved to the intent once it is created:
+const pendingPaymentPrefix = "pending_"
+
+var errPaymentAmountMismatch = errors.New("PAYMENT_AMOUNT_MISMATCH")
+
+var errReservationNotFound = errors.New("RESERVATION_NOT_FOUND")
+
+var errAlreadyCheckedIn = errors.New("ALREADY_CHECKED_IN")
+
+const (
+	RefundsRetryInterval = 10 * time.Minute
+	refundsRetryBatch    = 50
+	// Cancellations younger than this delay may still be refunding:
+	refundsRetryDelay = 5 * time.Minute
 )
 
 type ReservationsRepo struct {
@@ -41,6 +67,11 @@ func (reservationsRepo *ReservationsRepo) GetSeats(diffuionID uint) (map[string]
 		return nil, errors.New("FETCHING_DIFFUSION_FAILED")
 	}
 
+	err = setTicketPrice(database, &diffusion)
+	if err != nil {
+		return nil, errors.New("FETCHING_DIFFUSION_FAILED")
+	}
+
 	var seats []*models.Seat
 	for _, seat := range diffusion.SeatsStatus {
 		seats = append(seats, &seat)
@@ -49,10 +80,88 @@ func (reservationsRepo *ReservationsRepo) GetSeats(diffuionID uint) (map[string]
 	return map[string]interface{}{
 		"count":     len(seats),
 		"seats":     seats,
-		"seatPrice": diffusion.SeatPrice,
+		"seatPrice": diffusion.TicketPrice,
 	}, nil
 }
 
+// setTicketPrice fills the price of a seat of the diffusion, with the
+// surcharge of its format in the cinema of its hall.
+func setTicketPrice(tx *gorm.DB, diffusion *models.Diffusion) error {
+	var hall models.Hall
+	err := tx.Unscoped().Select("id", "cinema_id").Where("id = ?", diffusion.HallID).First(&hall).Error
+	if err != nil {
+		return err
+	}
+
+	var surcharges []models.FormatSurcharge
+	err = tx.Where("cinema_id = ?", hall.CinemaID).Find(&surcharges).Error
+	if err != nil {
+		return err
+	}
+
+	diffusion.SetTicketPrice(surcharges)
+	return nil
+}
+
+// getTicketAmount returns the price of a seat of the diffusion, in minor units
+// of the currency of its cinema, and that currency.
+func getTicketAmount(tx *gorm.DB, diffusionID uint) (int64, string, error) {
+	var diffusion models.Diffusion
+	err := tx.Preload("Hall", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Preload("Hall.Cinema").Where("id = ?", diffusionID).First(&diffusion).Error
+	if err != nil || diffusion.Hall == nil || diffusion.Hall.Cinema == nil {
+		return 0, "", errors.New("INVALID_DIFFUSION_ID")
+	}
+
+	if err := setTicketPrice(tx, &diffusion); err != nil {
+		return 0, "", err
+	}
+	return int64(math.Round(diffusion.TicketPrice * 100)), strings.ToLower(diffusion.Hall.Cinema.Currency), nil
+}
+
+// countHeldSeats checks that the seats of the diffusion are held by the user,
+// in the seat-choice sockets or through a waitlist offer, and counts them.
+func countHeldSeats(tx *gorm.DB, diffusionID uint, seatIDs []uint, userID uint) (int64, error) {
+	var ids []uint
+	isListed := make(map[uint]bool)
+	for _, seatID := range seatIDs {
+		if seatID == 0 {
+			return 0, errors.New("INVALID_SEATS")
+		}
+		if !isListed[seatID] {
+			ids = append(ids, seatID)
+			isListed[seatID] = true
+		}
+	}
+
+	var seats []models.Seat
+	err := tx.Where("id IN ? AND diffusion_id = ?", ids, diffusionID).Find(&seats).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(seats) != len(ids) {
+		return 0, errors.New("INVALID_SEATS")
+	}
+
+	heldSeats := getHeldSeats(diffusionID)
+	for _, seat := range seats {
+		if seat.Status == "reserved" {
+			return 0, errors.New("SEAT_ALREADY_RESERVED")
+		}
+		holderID, isHeld := heldSeats[seat.ID]
+		if !isHeld && seat.Status == "onhold" && seat.UserID != nil {
+			holderID, isHeld = *seat.UserID, true
+		}
+		if !isHeld || holderID != userID {
+			return 0, errors.New("SEATS_NOT_HELD")
+		}
+	}
+	return int64(len(seats)), nil
+}
+
+// ResetSeats frees the seats of the diffusion left on hold for the user, but
+// the ones a waitlist offer holds for them.
 func (reservationsRepo *ReservationsRepo) ResetSeats(uid uint, diffuionID uint) error {
 	if diffuionID <= 0 {
 		return errors.New("INVALID_ID")
@@ -60,7 +169,28 @@ func (reservationsRepo *ReservationsRepo) ResetSeats(uid uint, diffuionID uint)
 
 	database := reservationsRepo.database
 
-	err := database.Model(models.Seat{}).Where("diffusion_id = ? and status = ? and user_id = ?", diffuionID, "onhold", uid).Update("status", "availble").Error
+	err := database.Transaction(func(tx *gorm.DB) error {
+		var offers []models.WaitlistEntry
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("diffusion_id = ? AND user_id = ? AND status = ?", diffuionID, uid, models.WaitlistOffered).
+			Find(&offers).Error
+		if err != nil {
+			return err
+		}
+		var offeredSeatIDs []uint
+		for _, offer := range offers {
+			offeredSeatIDs = append(offeredSeatIDs, offer.HeldSeatIDs...)
+		}
+
+		query := tx.Model(models.Seat{}).Where("diffusion_id = ? and status = ? and user_id = ?", diffuionID, "onhold", uid)
+		if len(offeredSeatIDs) > 0 {
+			query = query.Where("id NOT IN ?", offeredSeatIDs)
+		}
+		return query.Updates(map[string]interface{}{
+			"status":  "availble",
+			"user_id": nil,
+		}).Error
+	})
 	if err != nil {
 		return errors.New("RESETING_SEATS_FAILED")
 	}
@@ -75,71 +205,227 @@ func (reservationsRepo *ReservationsRepo) AddReservation(reservation models.Rese
 		}
 	}
 
-	// Validate payment intent:
-	payment, err := paymentintent.Get(reservation.PaymentIntent, nil)
-	if err != nil {
+	// Validate the age of the user:
+	if status, err := reservationsRepo.CheckAgeRestriction(reservation.UserID, reservation.DiffusionID); err != nil {
+		return status, map[string]string{
+			"error": err.Error(),
+		}
+	}
+
+	database := reservationsRepo.database
+
+	// Concessions come from the payment intent only:
+	reservation.Items = nil
+
+	// Validate payment intent, unless gift cards paid all of it:
+	if !strings.Contains(reservation.PaymentIntent, "_") || isPendingPayment(reservation.PaymentIntent) {
 		return http.StatusBadRequest, map[string]string{
 			"error": "INVALID_PAYMENT_INTENT",
 		}
 	}
+	paymentIntentID := getPaymentIntentID(reservation.PaymentIntent)
 
-	if payment.Status != stripe.PaymentIntentStatusSucceeded {
+	ticketAmount, currency, err := getTicketAmount(database, reservation.DiffusionID)
+	if err != nil {
 		return http.StatusBadRequest, map[string]string{
-			"error": "PAYMENT_HAS_NOT_BEEN_EFFECTED",
+			"error": "INVALID_DIFFUSION_ID",
 		}
 	}
 
-	database := reservationsRepo.database
-
-	// Validate seats:
-	var seats []models.Seat
-	for _, seat := range reservation.Seats {
-		err := database.Where("id = ?", seat.ID).First(&seat).Error
-		if err != nil {
+	var paymentMethods []string
+	reservation.Amount = 0
+	reservation.GiftCardAmount = 0
+	if !isGiftCardPayment(paymentIntentID) {
+		payment, err := paymentintent.Get(reservation.PaymentIntent, nil)
+		if err != nil ||
+			payment.Metadata["giftCard"] != "" ||
+			payment.Metadata["userID"] != strconv.FormatUint(uint64(reservation.UserID), 10) ||
+			payment.Metadata["diffusionID"] != strconv.FormatUint(uint64(reservation.DiffusionID), 10) {
 			return http.StatusBadRequest, map[string]string{
-				"error": "SEAT_DOESNT_EXIST",
+				"error": "INVALID_PAYMENT_INTENT",
 			}
 		}
 
-		if seat.Status == "reserved" {
+		if payment.Status != stripe.PaymentIntentStatusSucceeded {
 			return http.StatusBadRequest, map[string]string{
-				"error": "SEAT_ALREADY_RESERVED",
+				"error": "PAYMENT_HAS_NOT_BEEN_EFFECTED",
 			}
 		}
 
-		seats = append(seats, seat)
+		paymentMethods = append(paymentMethods, string(payment.PaymentMethod.Type))
+		reservation.Amount = uint(payment.Amount)
+		reservation.Currency = payment.Currency
 	}
 
-	// Add seats:
-	for _, seat := range seats {
-		seat.UserID = &reservation.UserID
-		seat.DiffusionID = reservation.DiffusionID
-		seat.Status = "reserved"
-		err := database.Save(&seat).Error
-		if err != nil {
-			continue
+	// Validate gift card tenders:
+	tenders, err := getGiftCardTenders(database, paymentIntentID, reservation.UserID)
+	if err != nil {
+		return http.StatusInternalServerError, map[string]string{
+			"error": "FETCHING_GIFT_CARDS_FAILED",
+		}
+	}
+	if len(tenders) > 0 {
+		paymentMethods = append(paymentMethods, models.GiftCardPaymentMethod)
+	} else if len(paymentMethods) == 0 {
+		return http.StatusBadRequest, map[string]string{
+			"error": "INVALID_PAYMENT_INTENT",
+		}
+	}
+	for _, tender := range tenders {
+		if reservation.Currency != "" && !strings.EqualFold(tender.GiftCard.Currency, reservation.Currency) {
+			return http.StatusBadRequest, map[string]string{
+				"error": "PAYMENT_CURRENCY_MISMATCH",
+			}
+		}
+		reservation.GiftCardAmount += uint(-tender.Amount)
+		reservation.Currency = tender.GiftCard.Currency
+	}
+	if !strings.EqualFold(reservation.Currency, currency) {
+		return http.StatusBadRequest, map[string]string{
+			"error": "PAYMENT_CURRENCY_MISMATCH",
 		}
 	}
-	reservation.Seats = seats
 
 	// Add bill detail:
-	reservation.PaymentMethod = string(payment.PaymentMethod.Type)
-	reservation.Amount = uint(payment.Amount)
-	reservation.Currency = payment.Currency
+	reservation.PaymentMethod = strings.Join(paymentMethods, "+")
+	reservation.Amount += reservation.GiftCardAmount
+
+	ticketCode, err := models.NewTicketCode()
+	if err != nil {
+		return http.StatusInternalServerError, map[string]string{
+			"error": "CREATING_RESERVATION_FAILED",
+		}
+	}
+	reservation.TicketCode = &ticketCode
 
-	// Create reservation:
-	err = database.Create(&reservation).Error
+	// Reserve seats, create reservation, tie its tenders, concessions and promo code, close its waitlist entries, grant its points and queue its notifications:
+	err = database.Transaction(func(tx *gorm.DB) error {
+		seats, err := reserveSeats(tx, reservation)
+		if err != nil {
+			return err
+		}
+		reservation.Seats = seats
+
+		if err := checkPaymentAmount(tx, reservation, ticketAmount*int64(len(seats))); err != nil {
+			return err
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			return err
+		}
+		if err := settleGiftCardTenders(tx, reservation, tenders); err != nil {
+			return err
+		}
+		if err := settleReservationItems(tx, reservation); err != nil {
+			return err
+		}
+		if err := settlePromoRedemption(tx, reservation); err != nil {
+			return err
+		}
+		if err := settleWaitlistEntries(tx, reservation); err != nil {
+			return err
+		}
+		if err := settleReservationPoints(tx, reservation); err != nil {
+			return err
+		}
+		return queueReservationAdded(tx, reservation)
+	})
+	if err != nil && (isSeatError(err) || isPromoCodeError(err) || isGiftCardError(err) || err == errPaymentAmountMismatch) {
+		return http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		}
+	}
 	if err != nil {
 		return http.StatusInternalServerError, map[string]string{
 			"error": "CREATING_RESERVATION_FAILED",
 		}
 	}
 
+	// Send the confirmation right away:
+	go reservationsRepo.DispatchNotifications()
+
 	return http.StatusOK, map[string]string{
 		"error": "RESERVATION_ADDED",
 	}
 }
 
+// reserveSeats locks the seats of the reservation and marks them reserved by
+// its user. Seats held by other users, in the sockets or through a waitlist
+// offer, are theirs only.
+func reserveSeats(tx *gorm.DB, reservation models.Reservation) ([]models.Seat, error) {
+	heldSeats := getHeldSeats(reservation.DiffusionID)
+
+	var seats []models.Seat
+	isListed := make(map[uint]bool)
+	for _, seat := range reservation.Seats {
+		if isListed[seat.ID] {
+			continue
+		}
+		isListed[seat.ID] = true
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND diffusion_id = ?", seat.ID, reservation.DiffusionID).
+			First(&seat).Error
+		if err != nil {
+			return nil, errors.New("SEAT_DOESNT_EXIST")
+		}
+
+		if seat.Status == "reserved" {
+			return nil, errors.New("SEAT_ALREADY_RESERVED")
+		}
+		if holderID, isHeld := heldSeats[seat.ID]; isHeld && holderID != reservation.UserID {
+			return nil, errors.New("SEAT_ALREADY_ONHOLD")
+		}
+		if seat.Status == "onhold" && seat.UserID != nil && *seat.UserID != reservation.UserID {
+			return nil, errors.New("SEAT_ALREADY_ONHOLD")
+		}
+
+		seat.UserID = &reservation.UserID
+		seat.Status = "reserved"
+		if err := tx.Save(&seat).Error; err != nil {
+			return nil, err
+		}
+		seats = append(seats, seat)
+	}
+	return seats, nil
+}
+
+// checkPaymentAmount checks that the payment covers the seats, with the
+// concessions, promo code and points held for its payment intent.
+func checkPaymentAmount(tx *gorm.DB, reservation models.Reservation, seatsAmount int64) error {
+	paymentIntentID := getPaymentIntentID(reservation.PaymentIntent)
+	amount := seatsAmount
+
+	var items []models.ReservationItem
+	err := tx.Where("payment_intent_id = ? AND user_id = ? AND reservation_id IS NULL", paymentIntentID, reservation.UserID).
+		Find(&items).Error
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		amount += item.Amount()
+	}
+
+	var redemptions []models.PromoRedemption
+	err = tx.Where("payment_intent_id = ? AND reservation_id IS NULL", paymentIntentID).Find(&redemptions).Error
+	if err != nil {
+		return err
+	}
+	for _, redemption := range redemptions {
+		amount -= redemption.Discount
+	}
+
+	points, err := getRedeemedPoints(tx, paymentIntentID)
+	if err != nil {
+		return err
+	}
+	amount -= points * loyalty.Instance.PointValue(reservation.Currency)
+
+	if amount != int64(reservation.Amount) {
+		return errPaymentAmountMismatch
+	}
+	return nil
+}
+
 func (reservationsRepo *ReservationsRepo) CancelReservation(reservation models.Reservation) (int, map[string]string) {
 	if err := reservation.ValidateCancel(); err != nil {
 		return http.StatusBadRequest, map[string]string{
@@ -149,44 +435,172 @@ func (reservationsRepo *ReservationsRepo) CancelReservation(reservation models.R
 
 	database := reservationsRepo.database
 
-	// Fetching reservation:
-	err := database.Where("id = ? and user_id = ?", reservation.ID, reservation.UserID).Preload("Seats").First(&reservation).Error
+	// Cancel the reservation before refunding it: free seats, refund its gift
+	// cards, restock its concessions, release its promo code, reverse its
+	// points and drop its notifications. It is locked, so that concurrent
+	// cancellations credit it once, and soft deleted until its refund is done:
+	var user models.User
+	err := database.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? and user_id = ?", reservation.ID, reservation.UserID).
+			First(&reservation).Error
+		if err != nil {
+			return errReservationNotFound
+		}
+		if err := tx.Where("reservation_id = ?", reservation.ID).Find(&reservation.Seats).Error; err != nil {
+			return err
+		}
+		user, err = loadNotificationDetails(tx, &reservation)
+		if err != nil {
+			return err
+		}
+
+		for _, seat := range reservation.Seats {
+			seat.Status = "availble"
+			seat.ReservationID = nil
+			if err := tx.Save(&seat).Error; err != nil {
+				return err
+			}
+		}
+		if err := dropReservationNotifications(tx, reservation); err != nil {
+			return err
+		}
+		if err := reverseReservationPoints(tx, reservation); err != nil {
+			return err
+		}
+		if err := releasePromoRedemption(tx, reservation); err != nil {
+			return err
+		}
+		if err := refundGiftCardTenders(tx, reservation); err != nil {
+			return err
+		}
+		if err := releaseReservationItems(tx, reservation); err != nil {
+			return err
+		}
+		return tx.Delete(&reservation).Error
+	})
+
+	// A cancellation whose refund failed is retried:
+	if err == errReservationNotFound {
+		err = database.Unscoped().
+			Where("id = ? and user_id = ? and deleted_at IS NOT NULL", reservation.ID, reservation.UserID).
+			First(&reservation).Error
+		if err != nil {
+			return http.StatusBadRequest, map[string]string{
+				"error": "FETCHING_RESERVATION_FAILED",
+			}
+		}
+		user, err = loadNotificationDetails(database, &reservation)
+	}
 	if err != nil {
 		return http.StatusBadRequest, map[string]string{
-			"error": "FETCHING_RESERVATION_FAILED",
+			"error": "DELETING_RESERVATION_FAILED",
 		}
 	}
 
-	// Reteive money:
-	paymentIntentID := getPaymentIntentID(reservation.PaymentIntent)
-	_, err = refund.New(&stripe.RefundParams{
-		PaymentIntent: &paymentIntentID,
-	})
+	// Offer the freed seats to the waitlist:
+	reservationsRepo.ProcessWaitlist(reservation.DiffusionID, isHeldInSockets(reservation.DiffusionID))
 
+	// Reteive money, and send the receipt right away:
+	err = reservationsRepo.refundCanceledReservation(reservation, user)
 	if err != nil {
 		return http.StatusBadRequest, map[string]string{
 			"error":   "REFUND_FAILED",
 			"message": err.Error(),
 		}
 	}
+	go reservationsRepo.DispatchNotifications()
 
-	for _, seat := range reservation.Seats {
-		seat.Status = "availble"
-		seat.ReservationID = nil
-		database.Save(&seat)
+	return http.StatusOK, map[string]string{
+		"error": "RESERVATION_CANCELED",
 	}
+}
+
+// refundCanceledReservation refunds the payment of the canceled reservation,
+// then deletes it for good and queues the receipt of its cancellation. The
+// refund is keyed on the payment intent, so that retrying it does not refund
+// twice.
+func (reservationsRepo *ReservationsRepo) refundCanceledReservation(reservation models.Reservation, user models.User) error {
+	var refundID string
+	if reservation.Amount > reservation.GiftCardAmount {
+		var err error
+		refundID, err = refundPayment(getPaymentIntentID(reservation.PaymentIntent))
+		if err != nil {
+			return err
+		}
+	}
+
+	return reservationsRepo.database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().
+			Where("id = ? AND deleted_at IS NOT NULL", reservation.ID).
+			Delete(&models.Reservation{})
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		return queueReservationCanceled(tx, reservation, user, refundID)
+	})
+}
+
+// StartRefundsRetry retries the refunds of the canceled reservations every
+// interval, until the context is done.
+func (reservationsRepo *ReservationsRepo) StartRefundsRetry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reservationsRepo.RetryRefunds()
+			}
+		}
+	}()
+}
 
-	//Delete reservation:
-	err = database.Where("id = ?", reservation.ID).Unscoped().Delete(&reservation).Error
+// RetryRefunds refunds the reservations canceled whose refund failed. Their
+// seats and concessions were released, so their receipts do not list them.
+func (reservationsRepo *ReservationsRepo) RetryRefunds() {
+	database := reservationsRepo.database
+
+	var reservations []models.Reservation
+	err := database.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-refundsRetryDelay)).
+		Limit(refundsRetryBatch).
+		Find(&reservations).Error
 	if err != nil {
-		return http.StatusBadRequest, map[string]string{
-			"error": "DELETING_RESERVATION_FAILED",
+		return
+	}
+
+	for _, reservation := range reservations {
+		user, err := loadNotificationDetails(database, &reservation)
+		if err != nil {
+			continue
+		}
+		if err := reservationsRepo.refundCanceledReservation(reservation, user); err != nil {
+			log.Printf("refunding reservation %v failed: %v", reservation.ID, err)
 		}
 	}
+	go reservationsRepo.DispatchNotifications()
+}
 
-	return http.StatusOK, map[string]string{
-		"error": "RESERVATION_CANCELED",
+// refundPayment refunds the payment intent once, and returns the reference of
+// the refund.
+func refundPayment(paymentIntentID string) (string, error) {
+	params := &stripe.RefundParams{
+		PaymentIntent: &paymentIntentID,
 	}
+	params.SetIdempotencyKey("refund_" + paymentIntentID)
+
+	paymentRefund, err := refund.New(params)
+	if stripeErr, ok := err.(*stripe.Error); ok && stripeErr.Code == stripe.ErrorCodeChargeAlreadyRefunded {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return paymentRefund.ID, nil
 }
 
 func getPaymentIntentID(paymentIntent string) string {
@@ -195,29 +609,247 @@ func getPaymentIntentID(paymentIntent string) string {
 	return paymentIntentID
 }
 
-func (reservationsRepo *ReservationsRepo) CreatePaymentIntent(amount int64, currency string) (int, map[string]string) {
-	if amount <= 0 || currency == "" {
-		return http.StatusBadRequest, map[string]string{
+// newPaymentReference returns the reference and the client secret of a
+// payment that is not a Stripe payment intent, shaped like one.
+func newPaymentReference(prefix string) (string, string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", err
+	}
+	paymentID := prefix + hex.EncodeToString(bytes[:12])
+	return paymentID, paymentID + "_secret_" + hex.EncodeToString(bytes[12:]), nil
+}
+
+func isPendingPayment(paymentIntentID string) bool {
+	return strings.HasPrefix(paymentIntentID, pendingPaymentPrefix)
+}
+
+// movePaymentHolds ties the tenders held under the pending reference to the
+// payment intent.
+func movePaymentHolds(tx *gorm.DB, reference string, paymentIntentID string) error {
+	for _, model := range []interface{}{&models.LoyaltyEntry{}, &models.PromoRedemption{}, &models.ReservationItem{}, &models.GiftCardTransaction{}} {
+		err := tx.Model(model).
+			Where("payment_intent_id = ?", reference).
+			Update("payment_intent_id", paymentIntentID).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releasePaymentHolds gives back the tenders held for the payment that was
+// never made.
+func releasePaymentHolds(tx *gorm.DB, paymentIntentID string) error {
+	if err := releaseRedeemedPoints(tx, paymentIntentID); err != nil {
+		return err
+	}
+
+	err := tx.Where("payment_intent_id = ? AND reservation_id IS NULL", paymentIntentID).Delete(&models.PromoRedemption{}).Error
+	if err != nil {
+		return err
+	}
+
+	var items []models.ReservationItem
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_intent_id = ? AND reservation_id IS NULL", paymentIntentID).
+		Find(&items).Error
+	if err != nil {
+		return err
+	}
+	if err := restockConcessions(tx, items); err != nil {
+		return err
+	}
+
+	return releaseGiftCardRedemptions(tx, paymentIntentID)
+}
+
+func (reservationsRepo *ReservationsRepo) CreatePaymentIntent(seatIDs []uint, concessions []ConcessionOrder, points uint, promoCode string, giftCardCode string, diffusionID uint, userID uint) (int, map[string]interface{}) {
+	if len(seatIDs) == 0 || diffusionID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
 			"error": "INVALID_ARGS",
 		}
 	}
 
-	payment, err := paymentintent.New(&stripe.PaymentIntentParams{
-		Amount:   stripe.Int64(7000),
-		Currency: stripe.String("usd"),
-	})
+	database := reservationsRepo.database
 
+	var paymentID, clientSecret, currency string
+	var amount, concessionsAmount, promoDiscount, pointsDiscount, giftCardAmount, charge int64
+	var promo models.PromoCode
+	tenderStatus := http.StatusBadRequest
+	err := database.Transaction(func(tx *gorm.DB) error {
+		// Price the seats the user holds, in the currency of the cinema:
+		ticketAmount, cinemaCurrency, err := getTicketAmount(tx, diffusionID)
+		if err != nil {
+			return err
+		}
+		seatsCount, err := countHeldSeats(tx, diffusionID, seatIDs, userID)
+		if err != nil {
+			return err
+		}
+		amount, currency = ticketAmount*seatsCount, cinemaCurrency
+		if amount <= 0 {
+			return errors.New("INVALID_SEATS")
+		}
+
+		// Apply the promo code, leaving at least the minimum charge to pay:
+		if promoCode != "" {
+			var status int
+			promo, status, err = getApplicablePromoCode(tx, promoCode, userID, diffusionID)
+			if err != nil {
+				tenderStatus = status
+				return err
+			}
+			promoDiscount, err = promo.Discount(amount, currency)
+			if err != nil {
+				return err
+			}
+			promoDiscount = min(promoDiscount, max(amount-loyalty.Instance.MinimumCharge, 0))
+		}
+
+		// Add the concessions, priced by the cinema:
+		items, itemsAmount, err := priceConcessions(tx, concessions, diffusionID, userID)
+		if err != nil {
+			return err
+		}
+		concessionsAmount = itemsAmount
+
+		// Redeem points on what is left:
+		pointValue := loyalty.Instance.PointValue(currency)
+		if pointValue > 0 {
+			redeemablePoints := (amount + concessionsAmount - promoDiscount - loyalty.Instance.MinimumCharge) / pointValue
+			points = uint(min(int64(points), max(redeemablePoints, 0)))
+		} else {
+			points = 0
+		}
+		pointsDiscount = int64(points) * pointValue
+		charge = amount + concessionsAmount - promoDiscount - pointsDiscount
+
+		// Pay what is left with the gift card. Stripe cannot charge less than
+		// the minimum, so the card pays all of it or leaves at least that:
+		var giftCard models.GiftCard
+		if giftCardCode != "" {
+			var status int
+			giftCard, status, err = getSpendableGiftCard(tx, giftCardCode, currency)
+			if err != nil {
+				tenderStatus = status
+				return err
+			}
+			giftCardAmount = min(giftCard.Balance, charge)
+			if giftCardAmount < charge && charge-giftCardAmount < loyalty.Instance.MinimumCharge {
+				giftCardAmount = max(charge-loyalty.Instance.MinimumCharge, 0)
+			}
+			charge -= giftCardAmount
+		}
+
+		// Hold the tenders under a reference of their own until the payment
+		// intent is created:
+		if charge > 0 {
+			paymentID, clientSecret, err = newPaymentReference(pendingPaymentPrefix)
+		} else {
+			paymentID, clientSecret, err = newPaymentReference(giftCardPaymentPrefix)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = addPoints(tx, models.LoyaltyEntry{
+			UserID:          userID,
+			Kind:            models.LoyaltyRedeem,
+			Points:          -int(points),
+			PaymentIntentID: paymentID,
+		})
+		if err == nil && promo.ID != 0 {
+			err = tx.Create(&models.PromoRedemption{
+				PromoCodeID:     promo.ID,
+				UserID:          userID,
+				DiffusionID:     diffusionID,
+				PaymentIntentID: paymentID,
+				Discount:        promoDiscount,
+			}).Error
+		}
+		if err == nil {
+			err = holdConcessions(tx, items, paymentID)
+		}
+		if err == nil && giftCardAmount > 0 {
+			err = addGiftCardAmount(tx, models.GiftCardTransaction{
+				GiftCardID:      giftCard.ID,
+				UserID:          userID,
+				Kind:            models.GiftCardRedeem,
+				Amount:          -giftCardAmount,
+				PaymentIntentID: paymentID,
+			})
+		}
+		return err
+	})
+	if err == errInsufficientPoints || (err != nil && (isSeatError(err) || isPromoCodeError(err) || isGiftCardError(err) || isConcessionError(err))) {
+		return tenderStatus, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
 	if err != nil {
-		return http.StatusInternalServerError, map[string]string{
+		return http.StatusInternalServerError, map[string]interface{}{
 			"error": "CREATING_PAYMENT_INTENT_FAILED",
 		}
 	}
 
-	return http.StatusOK, map[string]string{
-		"paymentIntent": payment.ClientSecret,
+	// Create the payment intent once the tenders are held, and move them to it:
+	if charge > 0 {
+		reference := paymentID
+
+		params := &stripe.PaymentIntentParams{
+			Amount:   stripe.Int64(charge),
+			Currency: stripe.String(currency),
+		}
+		params.AddMetadata("userID", strconv.FormatUint(uint64(userID), 10))
+		params.AddMetadata("diffusionID", strconv.FormatUint(uint64(diffusionID), 10))
+		params.AddMetadata("redeemedPoints", strconv.FormatUint(uint64(points), 10))
+		if promo.ID != 0 {
+			params.AddMetadata("promoCode", promo.Code)
+		}
+		if giftCardAmount > 0 {
+			params.AddMetadata("giftCardAmount", strconv.FormatInt(giftCardAmount, 10))
+		}
+
+		payment, err := paymentintent.New(params)
+		if err == nil {
+			paymentID, clientSecret = payment.ID, payment.ClientSecret
+			err = database.Transaction(func(tx *gorm.DB) error {
+				return movePaymentHolds(tx, reference, paymentID)
+			})
+			if err != nil {
+				cancelPayment(paymentID)
+			}
+		}
+		if err != nil {
+			database.Transaction(func(tx *gorm.DB) error {
+				return releasePaymentHolds(tx, reference)
+			})
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "CREATING_PAYMENT_INTENT_FAILED",
+			}
+		}
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"paymentIntent":   clientSecret,
+		"paymentRequired": charge > 0,
+		"amount":          charge,
+		"currency":        currency,
+		"seats":           amount,
+		"redeemedPoints":  points,
+		"concessions":     concessionsAmount,
+		"discount":        promoDiscount + pointsDiscount,
+		"promoDiscount":   promoDiscount,
+		"pointsDiscount":  pointsDiscount,
+		"giftCardAmount":  giftCardAmount,
 	}
 }
 
+func isSeatError(err error) bool {
+	return strings.HasPrefix(err.Error(), "SEAT") || err.Error() == "INVALID_SEATS"
+}
+
 func (reservationsRepo *ReservationsRepo) GetPaymentKeys() (int, map[string]string) {
 	payment := reservationsRepo.payment
 	return http.StatusOK, map[string]string{
@@ -226,7 +858,7 @@ func (reservationsRepo *ReservationsRepo) GetPaymentKeys() (int, map[string]stri
 	}
 }
 
-func (reservationsRepo *ReservationsRepo) UpdateReservation(newReservation models.Reservation) (int, map[string]interface{}) {
+func (reservationsRepo *ReservationsRepo) UpdateReservation(newReservation models.Reservation, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
 	// Validating id:
 	if newReservation.ID == 0 {
 		return http.StatusBadRequest, map[string]interface{}{
@@ -237,12 +869,17 @@ func (reservationsRepo *ReservationsRepo) UpdateReservation(newReservation model
 	var reservation models.Reservation
 	database := reservationsRepo.database
 
-	err := database.Where("id = ?", newReservation.ID).First(&reservation).Error
+	err := database.Where("id = ?", newReservation.ID).Scopes(withAgeRestriction("Diffusion.")).First(&reservation).Error
 	if err != nil {
 		return http.StatusBadRequest, map[string]interface{}{
 			"error": "FOUNDING_RESERVATION_FAILED",
 		}
 	}
+	if reservation.Diffusion.Hall == nil || !cinemaScope.Includes(reservation.Diffusion.Hall.CinemaID) {
+		return http.StatusForbidden, map[string]interface{}{
+			"error": "CINEMA_ACCESS_DENIED",
+		}
+	}
 
 	// Updating movie
 	if newReservation.UserID != 0 {
@@ -255,35 +892,141 @@ func (reservationsRepo *ReservationsRepo) UpdateReservation(newReservation model
 		reservation.UserID = newReservation.UserID
 	}
 	if newReservation.DiffusionID != 0 {
-		err := database.Where("id = ?", newReservation.DiffusionID).First(&models.Diffusion{}).Error
+		var diffusion models.Diffusion
+		err := database.Where("id = ?", newReservation.DiffusionID).Preload("Hall").First(&diffusion).Error
 		if err != nil {
 			return http.StatusBadRequest, map[string]interface{}{
 				"error": "INVALID_DIFFUSION_ID",
 			}
 		}
+		if diffusion.Hall == nil || !cinemaScope.Includes(diffusion.Hall.CinemaID) {
+			return http.StatusForbidden, map[string]interface{}{
+				"error": "CINEMA_ACCESS_DENIED",
+			}
+		}
 		reservation.DiffusionID = newReservation.DiffusionID
 	}
+	isCheckIn := newReservation.HasCome && !reservation.HasCome
 	reservation.HasCome = newReservation.HasCome
 
-	err = database.Save(&reservation).Error
+	err = database.Omit("Diffusion").Save(&reservation).Error
 	if err != nil {
 		return http.StatusInternalServerError, map[string]interface{}{
 			"error": "UPDATING_RESERVATION_FAILED",
 		}
 	}
 
-	return http.StatusOK, map[string]interface{}{
+	result := map[string]interface{}{
 		"message": "RESERVATION_UPDATED",
 	}
+
+	// The counter hands the pre-ordered concessions over at check-in:
+	if isCheckIn {
+		items, err := fulfilReservationItems(database, reservation.ID)
+		if err != nil {
+			return http.StatusInternalServerError, map[string]interface{}{
+				"error": "FULFILLING_CONCESSIONS_FAILED",
+			}
+		}
+		if len(items) > 0 {
+			result["items"] = items
+		}
+	}
+
+	// Staff checks the age of the audience of restricted shows at check-in:
+	if minimumAge := diffusionMinimumAge(reservation.Diffusion); reservation.HasCome && minimumAge > 0 {
+		result["warning"] = "CHECK_AUDIENCE_AGE"
+		result["minimumAge"] = minimumAge
+	}
+
+	return http.StatusOK, result
+}
+
+// CheckInReservation checks in the reservation whose ticket code was scanned
+// at the entrance of a cinema of the scope. A ticket is let in once.
+func (reservationsRepo *ReservationsRepo) CheckInReservation(ticketCode string, cinemaScope tools.CinemaScope) (int, map[string]interface{}) {
+	if ticketCode == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_TICKET_CODE",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var reservation models.Reservation
+	err := database.Where("ticket_code = ?", strings.ToUpper(strings.TrimSpace(ticketCode))).
+		Scopes(withAgeRestriction("Diffusion.")).
+		Preload("Seats").
+		First(&reservation).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "RESERVATION_NOT_FOUND",
+		}
+	}
+	if reservation.Diffusion.Hall == nil || !cinemaScope.Includes(reservation.Diffusion.Hall.CinemaID) {
+		return http.StatusForbidden, map[string]interface{}{
+			"error": "CINEMA_ACCESS_DENIED",
+		}
+	}
+
+	// The counter hands the pre-ordered concessions over at check-in:
+	var items []models.ReservationItem
+	err = database.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Reservation{}).
+			Where("id = ? AND has_come = ?", reservation.ID, false).
+			Update("has_come", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errAlreadyCheckedIn
+		}
+		items, err = fulfilReservationItems(tx, reservation.ID)
+		return err
+	})
+	if err == errAlreadyCheckedIn {
+		return http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "CHECKING_IN_FAILED",
+		}
+	}
+	reservation.HasCome = true
+
+	result := map[string]interface{}{
+		"message":     "RESERVATION_CHECKED_IN",
+		"reservation": reservation,
+	}
+	if len(items) > 0 {
+		result["items"] = items
+	}
+
+	// Staff checks the age of the audience of restricted shows at check-in:
+	if minimumAge := diffusionMinimumAge(reservation.Diffusion); minimumAge > 0 {
+		result["warning"] = "CHECK_AUDIENCE_AGE"
+		result["minimumAge"] = minimumAge
+	}
+
+	return http.StatusOK, result
 }
 
-func (reservationsRepo *ReservationsRepo) GetReservations(hallName string, movieTitle string, showTime time.Time, isExpired bool) (int, map[string]interface{}) {
+func (reservationsRepo *ReservationsRepo) GetReservations(cinemaID uint, hallName string, movieTitle string, showTime time.Time, isExpired bool, cinemaScope tools.CinemaScope, listing tools.ListingQuery) (int, map[string]interface{}) {
+	if err := listing.Validate(reservationsListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
 	database := reservationsRepo.database
 
 	result := make(map[string]interface{})
 
 	query := database.Model(&models.Reservation{}).
 		Preload("Seats").
+		Preload("Items").
 		Preload("Diffusion").
 		Preload("Diffusion.Movie", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id", "title")
@@ -294,6 +1037,12 @@ func (reservationsRepo *ReservationsRepo) GetReservations(hallName string, movie
 		Joins("JOIN diffusions ON reservations.diffusion_id = diffusions.id").
 		Joins("JOIN halls ON diffusions.hall_id = halls.id").
 		Joins("JOIN movies ON diffusions.movie_id = movies.id")
+	if !cinemaScope.IsSuperAdmin {
+		query = query.Where("halls.cinema_id in ?", cinemaScope.CinemaIDs)
+	}
+	if cinemaID != 0 {
+		query = query.Where("halls.cinema_id = ?", cinemaID)
+	}
 	if hallName != "" {
 		query = query.Where("halls.name = ?", hallName)
 	}
@@ -308,7 +1057,7 @@ func (reservationsRepo *ReservationsRepo) GetReservations(hallName string, movie
 	}
 
 	var reservations []models.Reservation
-	err := query.Find(&reservations).Error
+	page, err := tools.FindPage(query, &reservations, listing)
 	if err != nil {
 		return http.StatusInternalServerError, map[string]interface{}{
 			"error": "FETCHING_RESERVATIONS_FAILED",
@@ -326,12 +1075,20 @@ func (reservationsRepo *ReservationsRepo) GetReservations(hallName string, movie
 		reservation.PaymentIntent = ""
 	}
 
-	result["reservations"] = reservations
+	result["reservations"] = tools.SelectFields(reservations, listing.Fields)
 	result["count"] = len(reservations)
+	result["total"] = page.Total
+	result["nextCursor"] = page.NextCursor
 	return http.StatusOK, result
 }
 
-func (reservationsRepo *ReservationsRepo) GetUserReservations(userID uint) (int, map[string]interface{}) {
+func (reservationsRepo *ReservationsRepo) GetUserReservations(userID uint, listing tools.ListingQuery) (int, map[string]interface{}) {
+	if err := listing.Validate(reservationsListing); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
 	database := reservationsRepo.database
 
 	result := make(map[string]interface{})
@@ -341,10 +1098,11 @@ func (reservationsRepo *ReservationsRepo) GetUserReservations(userID uint) (int,
 		Preload("Diffusion.Movie", func(db *gorm.DB) *gorm.DB {
 			return db.Select("id", "title", "rate", "pic_url")
 		}).
-		Preload("Diffusion.Movie.Type")
+		Preload("Diffusion.Movie.Type").
+		Preload("Diffusion.Hall.Cinema")
 
 	var reservations []models.Reservation
-	err := query.Find(&reservations).Error
+	page, err := tools.FindPage(query, &reservations, listing)
 	if err != nil {
 		return http.StatusInternalServerError, map[string]interface{}{
 			"error": "FETCHING_RESERVATIONS_FAILED",
@@ -360,13 +1118,16 @@ func (reservationsRepo *ReservationsRepo) GetUserReservations(userID uint) (int,
 		reservation.UserID = 0
 		reservation.Diffusion.SeatPrice = 0
 		reservation.PaymentIntent = ""
+		reservation.Diffusion.SetLocalTime(reservation.Diffusion.Hall.Location())
 		reservation.Diffusion.Hall = nil
 		reservation.Amount = 0
 		reservation.Currency = ""
 	}
 
-	result["reservations"] = reservations
+	result["reservations"] = tools.SelectFields(reservations, listing.Fields)
 	result["count"] = len(reservations)
+	result["total"] = page.Total
+	result["nextCursor"] = page.NextCursor
 	return http.StatusOK, result
 }
 
@@ -383,8 +1144,10 @@ func (reservationsRepo *ReservationsRepo) GetReservation(userID uint, reservatio
 		}).
 		Preload("Diffusion.Movie.Type").
 		Preload("Diffusion.Hall", func(db *gorm.DB) *gorm.DB {
-			return db.Select("id", "name")
-		})
+			return db.Select("id", "name", "cinema_id", "timezone")
+		}).
+		Preload("Diffusion.Hall.Cinema").
+		Preload("Items")
 
 	var reservation models.Reservation
 	err := query.First(&reservation).Error
@@ -395,6 +1158,7 @@ func (reservationsRepo *ReservationsRepo) GetReservation(userID uint, reservatio
 	}
 
 	// Clean responce:
+	reservation.Diffusion.SetLocalTime(reservation.Diffusion.Hall.Location())
 	reservation.DiffusionID = 0
 	reservation.Diffusion.MovieID = 0
 	reservation.Diffusion.HallID = 0
@@ -0,0 +1,34 @@
+package reservations
+
+// SeatHolds reads the seats held in the seat-choice sockets, which only live
+// in the memory of the socket manager.
+type SeatHolds interface {
+	// HeldSeats returns the users holding seats of the diffusion, by seat.
+	HeldSeats(diffusionID uint) map[uint]uint
+}
+
+var seatHolds SeatHolds
+
+// SetSeatHolds lets the repositories see the seats held in the sockets.
+func SetSeatHolds(holds SeatHolds) {
+	seatHolds = holds
+}
+
+// getHeldSeats returns the users holding seats of the diffusion in the
+// sockets, by seat.
+func getHeldSeats(diffusionID uint) map[uint]uint {
+	if seatHolds == nil {
+		return map[uint]uint{}
+	}
+	return seatHolds.HeldSeats(diffusionID)
+}
+
+// isHeldInSockets reports whether the seats of the diffusion are held in the
+// sockets. It must not be called while the socket manager is locked.
+func isHeldInSockets(diffusionID uint) func(seatID uint) bool {
+	heldSeats := getHeldSeats(diffusionID)
+	return func(seatID uint) bool {
+		_, isHeld := heldSeats[seatID]
+		return isHeld
+	}
+}
@@ -0,0 +1,215 @@
+package reservations
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+// Largest party seats are suggested or held for at once:
+const MaxPartySize = 20
+
+const (
+	defaultSuggestionsCount = 3
+	maxSuggestionsCount     = 10
+
+	centerWeight          = 1.0
+	preferredCenterWeight = 3.0
+	aisleWeight           = 1.0
+)
+
+// SeatPreferences are what a party asks of its seats. Accessible parties only
+// get groups with a seat accessible to wheelchairs.
+type SeatPreferences struct {
+	Center     bool `json:"center"`
+	Aisle      bool `json:"aisle"`
+	Accessible bool `json:"accessible"`
+}
+
+type SeatSuggestion struct {
+	Seats      []*models.Seat `json:"seats"`
+	Score      float64        `json:"score"`
+	Aisle      bool           `json:"aisle"`
+	Accessible bool           `json:"accessible"`
+}
+
+// SuggestSeats returns the best groups of available seats side by side for
+// the party, from the seats of the diffusion.
+func (reservationsRepo *ReservationsRepo) SuggestSeats(diffusionID string, partySizeString string, countString string, preferences SeatPreferences) (int, map[string]interface{}) {
+	if diffusionID == "" {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_DIFFUSION_ID",
+		}
+	}
+	partySize, err := strconv.Atoi(partySizeString)
+	if err != nil || partySize <= 0 || partySize > MaxPartySize {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_PARTY_SIZE",
+		}
+	}
+	count := defaultSuggestionsCount
+	if countString != "" {
+		count, err = strconv.Atoi(countString)
+		if err != nil || count <= 0 || count > maxSuggestionsCount {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error": "INVALID_COUNT",
+			}
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var diffusion models.Diffusion
+	err = database.Where("id = ?", diffusionID).Preload("SeatsStatus").Preload("Hall").First(&diffusion).Error
+	if err != nil || diffusion.Hall == nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "DIFFUSION_NOT_FOUND",
+		}
+	}
+
+	// Seats held in the seat-choice sockets are not available either:
+	heldSeats := getHeldSeats(diffusion.ID)
+	seats := make([]*models.Seat, len(diffusion.SeatsStatus))
+	for index := range diffusion.SeatsStatus {
+		seat := &diffusion.SeatsStatus[index]
+		if _, isHeld := heldSeats[seat.ID]; isHeld && seat.Status == "availble" {
+			seat.Status = "onhold"
+		}
+		seats[index] = seat
+	}
+	suggestions := SuggestSeatGroups(seats, *diffusion.Hall, partySize, preferences, count)
+
+	return http.StatusOK, map[string]interface{}{
+		"count":       len(suggestions),
+		"suggestions": suggestions,
+	}
+}
+
+// GetDiffusionHall returns the hall of the diffusion, with its layout.
+func (reservationsRepo *ReservationsRepo) GetDiffusionHall(diffusionID uint) (models.Hall, error) {
+	var hall models.Hall
+	err := reservationsRepo.database.
+		Where("id = (?)", reservationsRepo.database.Model(&models.Diffusion{}).Select("hall_id").Where("id = ?", diffusionID)).
+		First(&hall).Error
+	return hall, err
+}
+
+// SuggestSeatGroups scores every group of partySize available seats side by
+// side in a row, without an aisle between them, and returns the best ones that
+// do not overlap. Groups closer to the center of the hall score higher, and
+// more so when the party prefers it; groups at an aisle score higher when the
+// party prefers it.
+func SuggestSeatGroups(seats []*models.Seat, hall models.Hall, partySize int, preferences SeatPreferences, count int) []SeatSuggestion {
+	suggestions := []SeatSuggestion{}
+	if partySize <= 0 || len(seats) == 0 {
+		return suggestions
+	}
+
+	rows := make(map[string][]*models.Seat)
+	var lastRowIndex, lastColumn int
+	for _, seat := range seats {
+		rows[seat.SeatRow] = append(rows[seat.SeatRow], seat)
+		if seat.SeatRow != "" {
+			lastRowIndex = max(lastRowIndex, int(seat.SeatRow[0]-'A'))
+		}
+		lastColumn = max(lastColumn, seat.SeatColumn)
+	}
+	centerRow := float64(lastRowIndex) / 2
+	centerColumn := float64(1+lastColumn) / 2
+
+	weight := centerWeight
+	if preferences.Center {
+		weight = preferredCenterWeight
+	}
+
+	var candidates []SeatSuggestion
+	for rowName, row := range rows {
+		if rowName == "" {
+			continue
+		}
+		rowIndex := int(rowName[0] - 'A')
+		sort.Slice(row, func(i, j int) bool {
+			return row[i].SeatColumn < row[j].SeatColumn
+		})
+
+		// Slide over the runs of available seats side by side:
+		runStart := 0
+		for index := range row {
+			if row[index].Status != "availble" {
+				runStart = index + 1
+				continue
+			}
+			if index > runStart {
+				previousColumn := row[index-1].SeatColumn
+				if row[index].SeatColumn != previousColumn+1 || hall.HasAisleAfter(previousColumn) {
+					runStart = index
+				}
+			}
+			if index-runStart+1 < partySize {
+				continue
+			}
+
+			group := row[index-partySize+1 : index+1]
+			firstColumn, lastGroupColumn := group[0].SeatColumn, group[partySize-1].SeatColumn
+			suggestion := SeatSuggestion{
+				Seats: append([]*models.Seat(nil), group...),
+				Aisle: hall.IsAisleSeat(firstColumn) || hall.IsAisleSeat(lastGroupColumn),
+			}
+			for _, seat := range group {
+				if hall.IsAccessibleSeat(seat) {
+					suggestion.Accessible = true
+				}
+			}
+			if preferences.Accessible && !suggestion.Accessible {
+				continue
+			}
+
+			groupCenter := float64(firstColumn+lastGroupColumn) / 2
+			rowDistance := math.Abs(float64(rowIndex)-centerRow) / math.Max(centerRow, 1)
+			columnDistance := math.Abs(groupCenter-centerColumn) / math.Max(centerColumn-1, 1)
+			score := -weight * (rowDistance + columnDistance)
+			if preferences.Aisle && suggestion.Aisle {
+				score += aisleWeight
+			}
+			suggestion.Score = math.Round(score*1000) / 1000
+			candidates = append(candidates, suggestion)
+		}
+	}
+
+	// Equal scores keep the seats order, so that suggestions are deterministic:
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		first, second := candidates[i].Seats[0], candidates[j].Seats[0]
+		if first.SeatRow != second.SeatRow {
+			return first.SeatRow < second.SeatRow
+		}
+		return first.SeatColumn < second.SeatColumn
+	})
+
+	taken := make(map[uint]bool)
+	for _, candidate := range candidates {
+		if len(suggestions) == count {
+			break
+		}
+		isOverlapping := false
+		for _, seat := range candidate.Seats {
+			if taken[seat.ID] {
+				isOverlapping = true
+			}
+		}
+		if isOverlapping {
+			continue
+		}
+		for _, seat := range candidate.Seats {
+			taken[seat.ID] = true
+		}
+		suggestions = append(suggestions, candidate)
+	}
+
+	return suggestions
+}
@@ -0,0 +1,140 @@
+package reservations
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+// newTestSeats returns the seats of a hall of the given rows and columns, all
+// available but the ones given with their status, such as "A3": "onhold".
+func newTestSeats(rows string, columnsCount int, statuses map[string]string) []*models.Seat {
+	var seats []*models.Seat
+	for rowIndex, row := range rows {
+		for column := 1; column <= columnsCount; column++ {
+			status, ok := statuses[models.SeatLabel(string(row), column)]
+			if !ok {
+				status = "availble"
+			}
+			seats = append(seats, &models.Seat{
+				ID:          uint(rowIndex*100 + column),
+				DiffusionID: 1,
+				Status:      status,
+				SeatRow:     string(row),
+				SeatColumn:  column,
+			})
+		}
+	}
+	return seats
+}
+
+func suggestionLabels(suggestions []SeatSuggestion) [][]string {
+	labels := [][]string{}
+	for _, suggestion := range suggestions {
+		var group []string
+		for _, seat := range suggestion.Seats {
+			group = append(group, models.SeatLabel(seat.SeatRow, seat.SeatColumn))
+		}
+		labels = append(labels, group)
+	}
+	return labels
+}
+
+func TestSuggestSeatGroups(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        string
+		columns     int
+		statuses    map[string]string
+		hall        models.Hall
+		partySize   int
+		preferences SeatPreferences
+		want        [][]string
+	}{
+		{
+			name:      "aisle breaks groups",
+			rows:      "A",
+			columns:   6,
+			hall:      models.Hall{ColumnsCount: 6, AisleAfterColumns: []int{3}},
+			partySize: 2,
+			want:      [][]string{{"A2", "A3"}, {"A4", "A5"}},
+		},
+		{
+			name:      "aisle too wide for the party",
+			rows:      "A",
+			columns:   6,
+			hall:      models.Hall{ColumnsCount: 6, AisleAfterColumns: []int{3}},
+			partySize: 4,
+			want:      [][]string{},
+		},
+		{
+			name:      "held seat leaves a gap",
+			rows:      "A",
+			columns:   5,
+			statuses:  map[string]string{"A3": "onhold"},
+			hall:      models.Hall{ColumnsCount: 5},
+			partySize: 2,
+			want:      [][]string{{"A1", "A2"}, {"A4", "A5"}},
+		},
+		{
+			name:      "reserved seat leaves a gap too small",
+			rows:      "A",
+			columns:   5,
+			statuses:  map[string]string{"A3": "reserved"},
+			hall:      models.Hall{ColumnsCount: 5},
+			partySize: 3,
+			want:      [][]string{},
+		},
+		{
+			name:        "accessible party",
+			rows:        "A",
+			columns:     6,
+			hall:        models.Hall{ColumnsCount: 6, AccessibleSeats: []string{"A6"}},
+			partySize:   2,
+			preferences: SeatPreferences{Accessible: true},
+			want:        [][]string{{"A5", "A6"}},
+		},
+		{
+			name:        "accessible seat held",
+			rows:        "A",
+			columns:     6,
+			statuses:    map[string]string{"A6": "onhold"},
+			hall:        models.Hall{ColumnsCount: 6, AccessibleSeats: []string{"A6"}},
+			partySize:   2,
+			preferences: SeatPreferences{Accessible: true},
+			want:        [][]string{},
+		},
+		{
+			name:      "equal scores sorted by row then column",
+			rows:      "AB",
+			columns:   4,
+			hall:      models.Hall{ColumnsCount: 4},
+			partySize: 2,
+			want:      [][]string{{"A2", "A3"}, {"B2", "B3"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seats := newTestSeats(test.rows, test.columns, test.statuses)
+			// The order of the seats and of the rows must not change the
+			// suggestions:
+			for attempt := 0; attempt < 10; attempt++ {
+				rand.Shuffle(len(seats), func(i, j int) {
+					seats[i], seats[j] = seats[j], seats[i]
+				})
+				suggestions := SuggestSeatGroups(seats, test.hall, test.partySize, test.preferences, maxSuggestionsCount)
+				if got := suggestionLabels(suggestions); !reflect.DeepEqual(got, test.want) {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+				for _, suggestion := range suggestions {
+					if test.preferences.Accessible && !suggestion.Accessible {
+						t.Errorf("suggestion %v is not accessible", suggestionLabels([]SeatSuggestion{suggestion}))
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package reservations
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+)
+
+// Freed seats are held for the next party of the waitlist during:
+const waitlistOfferDuration = 15 * time.Minute
+
+var errWaitlistHoldFailed = errors.New("WAITLIST_HOLD_FAILED")
+
+func (reservationsRepo *ReservationsRepo) JoinWaitlist(entry models.WaitlistEntry) (int, map[string]interface{}) {
+	if err := entry.Validate(); err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+	if entry.PartySize > MaxPartySize {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_PARTY_SIZE",
+		}
+	}
+
+	// Validate the age of the user:
+	if status, err := reservationsRepo.CheckAgeRestriction(entry.UserID, entry.DiffusionID); err != nil {
+		return status, map[string]interface{}{
+			"error": err.Error(),
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var diffusion models.Diffusion
+	err := database.Where("id = ? AND show_time > ?", entry.DiffusionID, time.Now()).First(&diffusion).Error
+	if err != nil {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_DIFFUSION_ID",
+		}
+	}
+
+	var activeCount int64
+	database.Model(&models.WaitlistEntry{}).
+		Where("diffusion_id = ? AND user_id = ? AND status IN ?", entry.DiffusionID, entry.UserID, []string{models.WaitlistWaiting, models.WaitlistOffered}).
+		Count(&activeCount)
+	if activeCount > 0 {
+		return http.StatusConflict, map[string]interface{}{
+			"error": "ALREADY_IN_WAITLIST",
+		}
+	}
+
+	// Parties that can still be seated do not wait:
+	var availableCount int64
+	database.Model(&models.Seat{}).Where("diffusion_id = ? AND status = ?", entry.DiffusionID, "availble").Count(&availableCount)
+	if availableCount >= int64(entry.PartySize) {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "SEATS_AVAILABLE",
+			"count": availableCount,
+		}
+	}
+
+	entry.ID = 0
+	entry.Status = models.WaitlistWaiting
+	entry.HeldSeatIDs = nil
+	entry.OfferExpiresAt = nil
+	err = database.Create(&entry).Error
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "JOINING_WAITLIST_FAILED",
+		}
+	}
+	entry.Position = reservationsRepo.getWaitlistPosition(entry)
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "WAITLIST_JOINED",
+		"entry":   entry,
+	}
+}
+
+// GetWaitlistEntry returns the entry of the user in the waitlist of the
+// diffusion, with its position while it waits.
+func (reservationsRepo *ReservationsRepo) GetWaitlistEntry(diffusionID uint, userID uint) (int, map[string]interface{}) {
+	if diffusionID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_DIFFUSION_ID",
+		}
+	}
+
+	// Expired offers go to the next parties first:
+	reservationsRepo.ProcessWaitlist(diffusionID, isHeldInSockets(diffusionID))
+
+	database := reservationsRepo.database
+
+	var entry models.WaitlistEntry
+	err := database.Where("diffusion_id = ? AND user_id = ?", diffusionID, userID).
+		Order("id DESC").
+		First(&entry).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "NOT_IN_WAITLIST",
+		}
+	}
+	entry.Position = reservationsRepo.getWaitlistPosition(entry)
+
+	return http.StatusOK, map[string]interface{}{
+		"entry": entry,
+	}
+}
+
+func (reservationsRepo *ReservationsRepo) LeaveWaitlist(diffusionID uint, userID uint) (int, map[string]interface{}) {
+	if diffusionID == 0 {
+		return http.StatusBadRequest, map[string]interface{}{
+			"error": "INVALID_DIFFUSION_ID",
+		}
+	}
+
+	database := reservationsRepo.database
+
+	var entry models.WaitlistEntry
+	err := database.Where("diffusion_id = ? AND user_id = ? AND status IN ?", diffusionID, userID, []string{models.WaitlistWaiting, models.WaitlistOffered}).
+		First(&entry).Error
+	if err != nil {
+		return http.StatusNotFound, map[string]interface{}{
+			"error": "NOT_IN_WAITLIST",
+		}
+	}
+
+	err = database.Transaction(func(tx *gorm.DB) error {
+		if entry.Status == models.WaitlistOffered {
+			if err := releaseWaitlistSeats(tx, entry); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&entry).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, map[string]interface{}{
+			"error": "LEAVING_WAITLIST_FAILED",
+		}
+	}
+
+	// The seats held for the user go to the next parties:
+	if entry.Status == models.WaitlistOffered {
+		reservationsRepo.ProcessWaitlist(diffusionID, isHeldInSockets(diffusionID))
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"message": "WAITLIST_LEFT",
+	}
+}
+
+// processExpiredOffers processes the waitlists of the diffusions with
+// expired offers, so that their seats go to the next waiting parties without
+// waiting for traffic on the diffusion.
+func (reservationsRepo *ReservationsRepo) processExpiredOffers() {
+	var diffusionIDs []uint
+	err := reservationsRepo.database.Model(&models.WaitlistEntry{}).
+		Where("status = ? AND offer_expires_at < ?", models.WaitlistOffered, time.Now()).
+		Distinct().
+		Pluck("diffusion_id", &diffusionIDs).Error
+	if err != nil {
+		log.Printf("getting expired waitlist offers failed: %v", err.Error())
+		return
+	}
+
+	for _, diffusionID := range diffusionIDs {
+		_, _, err := reservationsRepo.ProcessWaitlist(diffusionID, isHeldInSockets(diffusionID))
+		if err != nil {
+			log.Printf("processing diffusion %v waitlist failed: %v", diffusionID, err.Error())
+		}
+	}
+}
+
+// getWaitlistPosition returns the position of a waiting entry, starting at 1.
+func (reservationsRepo *ReservationsRepo) getWaitlistPosition(entry models.WaitlistEntry) int64 {
+	if entry.Status != models.WaitlistWaiting {
+		return 0
+	}
+
+	var aheadCount int64
+	reservationsRepo.database.Model(&models.WaitlistEntry{}).
+		Where("diffusion_id = ? AND status = ? AND id < ?", entry.DiffusionID, models.WaitlistWaiting, entry.ID).
+		Count(&aheadCount)
+	return aheadCount + 1
+}
+
+// ProcessWaitlist frees the seats of the expired offers of the diffusion, then
+// holds the available seats for the next waiting parties that fit in them,
+// side by side. The seats isHeld reports are left out, as they are held in
+// the seat-choice socket. It returns the current offers and the seats freed.
+func (reservationsRepo *ReservationsRepo) ProcessWaitlist(diffusionID uint, isHeld func(seatID uint) bool) ([]models.WaitlistEntry, []uint, error) {
+	database := reservationsRepo.database
+
+	var newOffers []models.WaitlistEntry
+	var freedSeatIDs []uint
+	err := database.Transaction(func(tx *gorm.DB) error {
+		// Expire the offers that were not booked in time:
+		var expiredOffers []models.WaitlistEntry
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("diffusion_id = ? AND status = ? AND offer_expires_at < ?", diffusionID, models.WaitlistOffered, time.Now()).
+			Find(&expiredOffers).Error
+		if err != nil {
+			return err
+		}
+		for _, entry := range expiredOffers {
+			if err := releaseWaitlistSeats(tx, entry); err != nil {
+				return err
+			}
+			freedSeatIDs = append(freedSeatIDs, entry.HeldSeatIDs...)
+			if err := tx.Model(&entry).Update("status", models.WaitlistExpired).Error; err != nil {
+				return err
+			}
+		}
+
+		var waitingEntries []models.WaitlistEntry
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("diffusion_id = ? AND status = ?", diffusionID, models.WaitlistWaiting).
+			Order("id ASC").
+			Find(&waitingEntries).Error
+		if err != nil || len(waitingEntries) == 0 {
+			return err
+		}
+
+		var diffusion models.Diffusion
+		err = tx.Preload("Hall").Where("id = ? AND show_time > ?", diffusionID, time.Now()).First(&diffusion).Error
+		if err != nil || diffusion.Hall == nil {
+			return nil
+		}
+
+		var availableSeats []*models.Seat
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("diffusion_id = ? AND status = ?", diffusionID, "availble").
+			Find(&availableSeats).Error
+		if err != nil {
+			return err
+		}
+		seats := availableSeats[:0]
+		for _, seat := range availableSeats {
+			if isHeld == nil || !isHeld(seat.ID) {
+				seats = append(seats, seat)
+			}
+		}
+
+		// Offer seats in turn, to the parties that fit. An offer that fails is
+		// rolled back on its own, leaving the others and the expiries:
+		preferences := SeatPreferences{Center: true}
+		for _, entry := range waitingEntries {
+			suggestions := SuggestSeatGroups(seats, *diffusion.Hall, int(entry.PartySize), preferences, 1)
+			if len(suggestions) == 0 {
+				continue
+			}
+
+			var seatIDs []uint
+			for _, seat := range suggestions[0].Seats {
+				seatIDs = append(seatIDs, seat.ID)
+			}
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				result := tx.Model(&models.Seat{}).
+					Where("id IN ? AND status = ?", seatIDs, "availble").
+					Updates(map[string]interface{}{
+						"status":  "onhold",
+						"user_id": entry.UserID,
+					})
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected != int64(len(seatIDs)) {
+					return errWaitlistHoldFailed
+				}
+
+				offerExpiresAt := time.Now().Add(waitlistOfferDuration)
+				entry.Status = models.WaitlistOffered
+				entry.HeldSeatIDs = seatIDs
+				entry.OfferExpiresAt = &offerExpiresAt
+				err := tx.Model(&entry).Select("status", "held_seat_ids", "offer_expires_at").Updates(&entry).Error
+				if err != nil {
+					return err
+				}
+				return queueWaitlistOffer(tx, entry)
+			})
+			if err == errWaitlistHoldFailed {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			for _, seat := range suggestions[0].Seats {
+				seat.Status = "onhold"
+			}
+			newOffers = append(newOffers, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Send the offers right away:
+	if len(newOffers) > 0 {
+		go reservationsRepo.DispatchNotifications()
+	}
+
+	var offers []models.WaitlistEntry
+	err = database.Where("diffusion_id = ? AND status = ?", diffusionID, models.WaitlistOffered).Find(&offers).Error
+	return offers, freedSeatIDs, err
+}
+
+// releaseWaitlistSeats frees the seats held for the entry.
+func releaseWaitlistSeats(tx *gorm.DB, entry models.WaitlistEntry) error {
+	if len(entry.HeldSeatIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&models.Seat{}).
+		Where("id IN ? AND status = ? AND user_id = ?", entry.HeldSeatIDs, "onhold", entry.UserID).
+		Updates(map[string]interface{}{
+			"status":  "availble",
+			"user_id": nil,
+		}).Error
+}
+
+// settleWaitlistEntries closes the entries of the user once the diffusion is
+// booked.
+func settleWaitlistEntries(tx *gorm.DB, reservation models.Reservation) error {
+	return tx.Model(&models.WaitlistEntry{}).
+		Where("diffusion_id = ? AND user_id = ? AND status IN ?", reservation.DiffusionID, reservation.UserID, []string{models.WaitlistWaiting, models.WaitlistOffered}).
+		Update("status", models.WaitlistBooked).Error
+}
+
+// queueWaitlistOffer queues the email telling the user that seats are held
+// for them.
+func queueWaitlistOffer(tx *gorm.DB, entry models.WaitlistEntry) error {
+	var user models.User
+	if err := tx.Select("id", "email").Where("id = ?", entry.UserID).First(&user).Error; err != nil {
+		return err
+	}
+	var diffusion models.Diffusion
+	err := tx.Preload("Movie").Preload("Hall.Cinema").Where("id = ?", entry.DiffusionID).First(&diffusion).Error
+	if err != nil {
+		return err
+	}
+
+	location := diffusion.Hall.Location()
+	return tx.Create(&models.Notification{
+		UserID:  entry.UserID,
+		Kind:    models.NotificationWaitlistOffer,
+		Email:   user.Email,
+		Subject: "Seats are waiting for you!",
+		Body: fmt.Sprintf(
+			"Seats were freed for %v on %v.\nThey are held for you until %v, book them before someone else does!",
+			diffusion.Movie.Title,
+			diffusion.ShowTime.In(location).Format("Monday 02 January 15:04"),
+			entry.OfferExpiresAt.In(location).Format("15:04"),
+		),
+		Status: models.NotificationPending,
+		SendAt: time.Now(),
+	}).Error
+}
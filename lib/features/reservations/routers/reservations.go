@@ -42,13 +42,41 @@ func (reservationsRouter *ReservationsRouter) RegisterRouts() {
 		middlewares.AuthorizationWithAdminCheck,
 	)
 
+	authorizationWithCinemaAdminCheck := tools.MiddlewareChain(
+		middlewares.Authorization,
+		middlewares.AuthorizationWithEmailVerification,
+		middlewares.AuthorizationWithCinemaAdminCheck,
+	)
+
 	router.HandleFunc("/seatChoice", authorizationWithEmailVerification(http.HandlerFunc(seatChoiceSocketManager.ServeWS)))
+	router.HandleFunc("GET /suggestSeats", authorizationWithEmailVerification(http.HandlerFunc(reservationController.SuggestSeats)))
 	router.HandleFunc("GET /getPaymentKeys", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetPaymentKeys)))
 	router.HandleFunc("POST /createPaymentIntent", authorizationWithEmailVerification(http.HandlerFunc(reservationController.CreatePaymentIntent)))
 	router.HandleFunc("POST /addReservation", authorizationWithEmailVerification(http.HandlerFunc(reservationController.AddReservation)))
 	router.HandleFunc("DELETE /cancelReservation", authorizationWithEmailVerification(http.HandlerFunc(reservationController.CancelReservation)))
-	router.HandleFunc("PUT /updateReservation", authorizationWithAdminCheck(http.HandlerFunc(reservationController.UpdateReservation)))
-	router.HandleFunc("POST /getReservations", authorizationWithAdminCheck(http.HandlerFunc(reservationController.GetReservations)))
+	router.HandleFunc("PUT /updateReservation", authorizationWithCinemaAdminCheck(http.HandlerFunc(reservationController.UpdateReservation)))
+	router.HandleFunc("POST /checkInReservation", authorizationWithCinemaAdminCheck(http.HandlerFunc(reservationController.CheckInReservation)))
+	router.HandleFunc("POST /getReservations", authorizationWithCinemaAdminCheck(http.HandlerFunc(reservationController.GetReservations)))
 	router.HandleFunc("GET /getUserReservations", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetUserReservations)))
 	router.HandleFunc("POST /getReservation", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetReservation)))
+	router.HandleFunc("GET /{id}/ticket.pdf", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetTicketPDF)))
+	router.HandleFunc("GET /{id}/invoice.pdf", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetInvoicePDF)))
+	router.HandleFunc("POST /{id}/invoice", authorizationWithEmailVerification(http.HandlerFunc(reservationController.IssueInvoice)))
+	router.HandleFunc("GET /calendar.ics", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetCalendar)))
+	router.HandleFunc("GET /calendar/{token}/reservations.ics", reservationController.GetFeedCalendar)
+	router.HandleFunc("GET /getCalendarFeed", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetCalendarFeed)))
+	router.HandleFunc("POST /resetCalendarFeed", authorizationWithEmailVerification(http.HandlerFunc(reservationController.ResetCalendarFeed)))
+	router.HandleFunc("GET /getReservationNotifications", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetReservationNotifications)))
+	router.HandleFunc("POST /createGiftCardIntent", authorizationWithEmailVerification(http.HandlerFunc(reservationController.CreateGiftCardIntent)))
+	router.HandleFunc("POST /addGiftCard", authorizationWithEmailVerification(http.HandlerFunc(reservationController.AddGiftCard)))
+	router.HandleFunc("GET /getGiftCard", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetGiftCard)))
+	router.HandleFunc("GET /getUserGiftCards", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetUserGiftCards)))
+	router.HandleFunc("POST /joinWaitlist", authorizationWithEmailVerification(http.HandlerFunc(reservationController.JoinWaitlist)))
+	router.HandleFunc("GET /getWaitlist", authorizationWithEmailVerification(http.HandlerFunc(reservationController.GetWaitlist)))
+	router.HandleFunc("DELETE /leaveWaitlist/{diffusionID}", authorizationWithEmailVerification(http.HandlerFunc(reservationController.LeaveWaitlist)))
+	router.HandleFunc("GET /checkPromoCode", authorizationWithEmailVerification(http.HandlerFunc(reservationController.CheckPromoCode)))
+	router.HandleFunc("POST /addPromoCode", authorizationWithAdminCheck(http.HandlerFunc(reservationController.AddPromoCode)))
+	router.HandleFunc("PUT /updatePromoCode", authorizationWithAdminCheck(http.HandlerFunc(reservationController.UpdatePromoCode)))
+	router.HandleFunc("GET /getPromoCodes", authorizationWithAdminCheck(http.HandlerFunc(reservationController.GetPromoCodes)))
+	router.HandleFunc("DELETE /deletePromoCode", authorizationWithAdminCheck(http.HandlerFunc(reservationController.DeletePromoCode)))
 }
\ No newline at end of file
@@ -0,0 +1,60 @@
+package reservations
+
+import (
+	"errors"
+	"sort"
+
+	reservationsRepo "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/reservations/repositories"
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+// getRequestedBlock returns the seats of the block, which must lie side by
+// side in a row of the hall, with no aisle between them.
+func getRequestedBlock(seats []*models.Seat, hall models.Hall, seatIDs []uint) ([]*models.Seat, error) {
+	if len(seatIDs) == 0 || len(seatIDs) > reservationsRepo.MaxPartySize {
+		return nil, errors.New("INVALID_BLOCK_SIZE")
+	}
+
+	seatsByID := make(map[uint]*models.Seat)
+	for _, seat := range seats {
+		seatsByID[seat.ID] = seat
+	}
+
+	var block []*models.Seat
+	requested := make(map[uint]bool)
+	for _, seatID := range seatIDs {
+		seat, ok := seatsByID[seatID]
+		if !ok || requested[seatID] {
+			return nil, errors.New("INVALID_SEAT_ID")
+		}
+		requested[seatID] = true
+		block = append(block, seat)
+	}
+
+	sort.Slice(block, func(i, j int) bool {
+		return block[i].SeatColumn < block[j].SeatColumn
+	})
+	for index := 1; index < len(block); index++ {
+		previousColumn := block[index-1].SeatColumn
+		sameRow := block[index].SeatRow == block[0].SeatRow
+		if !sameRow || block[index].SeatColumn != previousColumn+1 || hall.HasAisleAfter(previousColumn) {
+			return nil, errors.New("SEATS_NOT_CONTIGUOUS")
+		}
+	}
+
+	return block, nil
+}
+
+// HoldBlock holds all the seats of the block or none of them. The manager
+// must be locked, so that no seat of the block is held meanwhile.
+func (client *Client) HoldBlock(block []*models.Seat, seatPrice float64) error {
+	for _, seat := range block {
+		if seat.Status != "availble" {
+			return errors.New("SEATS_NOT_AVAILABLE")
+		}
+	}
+	for _, seat := range block {
+		client.HoldSeat(seat, seatPrice)
+	}
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"math"
 
+	reservationsRepository "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/features/reservations/repositories"
 	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
 	websocket "github.com/gorilla/websocket"
 )
@@ -19,6 +21,16 @@ type Client struct {
 	totalPrice  float64
 	holdedSeats map[uint]*models.Seat
 	egress      chan []byte
+
+	// Seats held for the user by a waitlist offer, which outlive the socket:
+	offeredSeats map[uint]bool
+
+	// Signals that the seats of the diffusion changed for another client:
+	refresh chan bool
+
+	// Promo code applied to the held seats, with the currency they are paid in:
+	promoCode     *models.PromoCode
+	promoCurrency string
 }
 
 func NewClient(connection *websocket.Conn, manager *SeatChoiceSocketManager, uid uint, diffusionID uint) *Client {
@@ -29,6 +41,9 @@ func NewClient(connection *websocket.Conn, manager *SeatChoiceSocketManager, uid
 		diffusionID: diffusionID,
 		holdedSeats: make(map[uint]*models.Seat),
 		egress:      make(chan []byte),
+
+		offeredSeats: make(map[uint]bool),
+		refresh:      make(chan bool, 1),
 	}
 }
 
@@ -44,9 +59,8 @@ func (client *Client) readMessages() {
 			break
 		}
 
-		for wsClient := range client.manager.clients {
-			wsClient.egress <- payload
-		}
+		// Events are handled for the client that sent them only:
+		client.egress <- payload
 	}
 }
 
@@ -60,28 +74,25 @@ func (client *Client) WriteMessages() {
 	//Remove onhold seats at the end:
 	defer client.cleanSocket()
 
-	// Get seats list:
+	// Get seats list, shared by the clients of the diffusion:
 	reservationsRepo := client.manager.reservationRepo
-	var result map[string]interface{}
-	var err1 error
-
-	if len(client.manager.diffusions[client.diffusionID]) == 0 {
-		result, err1 = reservationsRepo.GetSeats(uint(client.diffusionID))
-		if err1 != nil {
-			if err2 := client.connection.WriteMessage(websocket.CloseMessage, []byte(err1.Error())); err2 != nil {
+	seats, seatPrice, ok := client.manager.getSeats(client.diffusionID)
+	if !ok {
+		loaded, err := reservationsRepo.GetSeats(uint(client.diffusionID))
+		if err != nil {
+			if err2 := client.connection.WriteMessage(websocket.CloseMessage, []byte(err.Error())); err2 != nil {
 				log.Println("connection closed: ", err2.Error())
 			}
 			return
 		}
-		client.manager.diffusions[client.diffusionID] = result["seats"].([]*models.Seat)
-	} else {
-		seatsList := client.manager.diffusions[client.diffusionID]
-		result = map[string]interface{}{
-			"count":       len(seatsList),
-			"seats":       seatsList,
-			"totalPrice":  client.totalPrice,
-			"holdedSeats": client.holdedSeats,
-		}
+		seats, seatPrice = client.manager.setSeats(client.diffusionID, loaded["seats"].([]*models.Seat), loaded["seatPrice"].(float64))
+	}
+	result := map[string]interface{}{
+		"count":       len(seats),
+		"seats":       seats,
+		"seatPrice":   seatPrice,
+		"totalPrice":  client.totalPrice,
+		"holdedSeats": client.holdedSeats,
 	}
 
 	var response Event
@@ -89,14 +100,26 @@ func (client *Client) WriteMessages() {
 	// Send initial result:
 	response.Event = "data"
 	response.Result = result
-	initialResponse, _ := json.MarshalIndent(response, "", "\t")
-	if err := client.connection.WriteMessage(websocket.TextMessage, initialResponse); err != nil {
-		log.Printf("failed to send message %v", err.Error())
+	client.writeEvent(response)
+
+	// Seats the waitlist offered to the user are theirs to book:
+	client.manager.offerFreedSeats(client.diffusionID)
+	client.manager.Lock()
+	client.adoptOfferedSeats(seats, seatPrice)
+	client.manager.Unlock()
+
+	// Underage users can look at the seats but not hold them:
+	_, ageRestriction := reservationsRepo.CheckAgeRestriction(client.uid, client.diffusionID)
+
+	// The layout of the hall is used to suggest seats:
+	hall, err := reservationsRepo.GetDiffusionHall(client.diffusionID)
+	if err != nil {
+		if err := client.connection.WriteMessage(websocket.CloseMessage, []byte("FETCHING_HALL_FAILED")); err != nil {
+			log.Println("connection closed: ", err.Error())
+		}
+		return
 	}
 
-	seats := result["seats"].([]*models.Seat)
-	seatPrice := result["seatPrice"].(float64)
-
 	for {
 		select {
 		case message, ok := <-client.egress:
@@ -112,6 +135,7 @@ func (client *Client) WriteMessages() {
 
 			// Handle events:
 			var err1 error
+			var suggestions []reservationsRepository.SeatSuggestion
 			switch request.Event {
 			case "reserve":
 				body := request.Body
@@ -121,7 +145,9 @@ func (client *Client) WriteMessages() {
 					break
 				}
 				reservationID := uint(reservationIDFloat)
+				client.manager.Lock()
 				err1 = client.ReserveSeats(reservationID)
+				client.manager.Unlock()
 			case "unreserve":
 				body := request.Body
 				reservationIDFloat, ok := body["reservationID"].(float64)
@@ -130,7 +156,10 @@ func (client *Client) WriteMessages() {
 					break
 				}
 				reservationID := uint(reservationIDFloat)
+				client.manager.Lock()
 				err1 = client.UnreserveSeats(seats, reservationID)
+				client.manager.Unlock()
+				client.manager.offerFreedSeats(client.diffusionID)
 			case "hold":
 				body := request.Body
 				seatIDFloat, ok := body["seatID"].(float64)
@@ -139,6 +168,10 @@ func (client *Client) WriteMessages() {
 					break
 				}
 				seatID := uint(seatIDFloat)
+				if ageRestriction != nil {
+					err1 = ageRestriction
+					break
+				}
 
 				var requestedSeat *models.Seat
 				for index, seat := range seats {
@@ -151,7 +184,44 @@ func (client *Client) WriteMessages() {
 					break
 				}
 
+				client.manager.Lock()
 				err1 = client.HoldSeat(requestedSeat, seatPrice)
+				client.manager.Unlock()
+			case "holdBlock":
+				body := request.Body
+				if ageRestriction != nil {
+					err1 = ageRestriction
+					break
+				}
+
+				// Hold the given seats, or the best ones the server finds:
+				client.manager.Lock()
+				var block []*models.Seat
+				var err error
+				if seatIDsList, ok := body["seatIDs"].([]any); ok {
+					var seatIDs []uint
+					for _, seatID := range seatIDsList {
+						seatIDFloat, _ := seatID.(float64)
+						seatIDs = append(seatIDs, uint(seatIDFloat))
+					}
+					block, err = getRequestedBlock(seats, hall, seatIDs)
+				} else {
+					count, _ := body["count"].(float64)
+					preferences := reservationsRepository.SeatPreferences{Center: true}
+					suggestions := reservationsRepository.SuggestSeatGroups(seats, hall, int(count), preferences, 1)
+					if count <= 0 || count > reservationsRepository.MaxPartySize {
+						err = errors.New("INVALID_PARTY_SIZE")
+					} else if len(suggestions) == 0 {
+						err = errors.New("NO_BLOCK_AVAILABLE")
+					} else {
+						block = suggestions[0].Seats
+					}
+				}
+				if err == nil {
+					err = client.HoldBlock(block, seatPrice)
+				}
+				client.manager.Unlock()
+				err1 = err
 			case "unhold":
 				body := request.Body
 				seatIDFloat, ok := body["seatID"].(float64)
@@ -172,13 +242,71 @@ func (client *Client) WriteMessages() {
 					break
 				}
 
+				client.manager.Lock()
 				err1 = client.Unhold(requestedSeat, seatPrice)
+				client.manager.Unlock()
+				if err1 == nil {
+					client.manager.offerFreedSeats(client.diffusionID)
+				}
+			case "suggest":
+				body := request.Body
+				partySize, _ := body["partySize"].(float64)
+				count, _ := body["count"].(float64)
+				if partySize <= 0 || partySize > reservationsRepository.MaxPartySize {
+					err1 = errors.New("INVALID_PARTY_SIZE")
+					break
+				}
+				if count <= 0 {
+					count = 3
+				}
+				preferences := reservationsRepository.SeatPreferences{}
+				preferences.Center, _ = body["center"].(bool)
+				preferences.Aisle, _ = body["aisle"].(bool)
+				preferences.Accessible, _ = body["accessible"].(bool)
+
+				client.manager.RLock()
+				suggestions = reservationsRepository.SuggestSeatGroups(seats, hall, int(partySize), preferences, min(int(count), 10))
+				client.manager.RUnlock()
+			case "applyPromo":
+				body := request.Body
+				code, _ := body["code"].(string)
+				currency, _ := body["currency"].(string)
+				if code == "" {
+					err1 = errors.New("INVALID_CODE")
+					break
+				}
+
+				promoCode, _, err := reservationsRepo.CheckPromoCode(code, client.uid, client.diffusionID)
+				if err != nil {
+					err1 = err
+					break
+				}
+				if _, err := promoCode.Discount(0, currency); err != nil {
+					err1 = err
+					break
+				}
+				client.promoCode = &promoCode
+				client.promoCurrency = currency
+			case "removePromo":
+				client.promoCode = nil
+				client.promoCurrency = ""
 			default:
 				err1 = errors.New("INVALID_EVENT")
 			}
 
-			result["totalPrice"] = client.totalPrice
-			result["holdedSeats"] = client.holdedSeats
+			client.manager.Lock()
+			client.adoptOfferedSeats(seats, seatPrice)
+
+			// The other clients of the diffusion see the seats change right away:
+			switch request.Event {
+			case "reserve", "unreserve", "hold", "holdBlock", "unhold":
+				if err1 == nil {
+					client.manager.refreshClients(client.diffusionID, client)
+				}
+			}
+			client.manager.Unlock()
+
+			client.setHoldings(result)
 
 			// Send new result:
 			if err1 != nil {
@@ -186,38 +314,74 @@ func (client *Client) WriteMessages() {
 				response.Result = map[string]interface{}{
 					"error": err1.Error(),
 				}
+			} else if suggestions != nil {
+				response.Event = "suggestions"
+				response.Result = map[string]interface{}{
+					"count":       len(suggestions),
+					"suggestions": suggestions,
+				}
 			} else {
 				response.Event = "data"
 				response.Result = result
 			}
 
-			message, _ = json.MarshalIndent(response, "", "\t")
-			if err := client.connection.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("failed to send message %v", err.Error())
-			}
+			client.writeEvent(response)
+		case <-client.refresh:
+			client.manager.Lock()
+			client.adoptOfferedSeats(seats, seatPrice)
+			client.manager.Unlock()
 
+			client.setHoldings(result)
+			response.Event = "data"
+			response.Result = result
+			client.writeEvent(response)
 		}
 	}
 }
 
+// setHoldings fills the result with the seats the client holds and what they
+// cost.
+func (client *Client) setHoldings(result map[string]interface{}) {
+	result["totalPrice"] = client.totalPrice
+	result["holdedSeats"] = client.holdedSeats
+	if client.promoCode != nil {
+		result["promoCode"] = client.promoCode.Code
+		result["discount"] = client.PromoDiscount()
+	} else {
+		delete(result, "promoCode")
+		delete(result, "discount")
+	}
+}
+
+// writeEvent sends the event to the client. The seats it carries are shared
+// with the other clients of the diffusion, so it is encoded under the lock.
+func (client *Client) writeEvent(event Event) {
+	client.manager.RLock()
+	message, _ := json.MarshalIndent(event, "", "\t")
+	client.manager.RUnlock()
+
+	if err := client.connection.WriteMessage(websocket.TextMessage, message); err != nil {
+		log.Printf("failed to send message %v", err.Error())
+	}
+}
+
 func (client *Client) cleanSocket() {
 	reservationsRepo := client.manager.reservationRepo
 
-	reservationsRepo.ResetSeats(client.diffusionID, client.uid)
-	client.manager.removeClient(client)
+	reservationsRepo.ResetSeats(client.uid, client.diffusionID)
 
-	// Remove diffusion if all clients were deleted
-	isDiffusionAbendend := true
-	for managerClient := range client.manager.clients {
-		if client.diffusionID == managerClient.diffusionID {
-			isDiffusionAbendend = false
-			break
+	// Release the seats the client held, and offer them to the waitlist. The
+	// seats offered to the user stay held for them until the offer ends:
+	client.manager.Lock()
+	for _, seat := range client.holdedSeats {
+		if !client.offeredSeats[seat.ID] {
+			client.Unhold(seat, 0)
 		}
 	}
-
-	if isDiffusionAbendend {
-		client.manager.diffusions[client.diffusionID] = make([]*models.Seat, 0)
-	}
+	client.manager.refreshClients(client.diffusionID, client)
+	client.manager.Unlock()
+	client.manager.removeClient(client)
+	client.manager.offerFreedSeats(client.diffusionID)
 }
 
 func (client *Client) Unhold(seat *models.Seat, seatPrice float64) error {
@@ -226,6 +390,7 @@ func (client *Client) Unhold(seat *models.Seat, seatPrice float64) error {
 		seat.UserID = nil
 		client.totalPrice -= seatPrice
 		delete(client.holdedSeats, seat.ID)
+		delete(client.offeredSeats, seat.ID)
 		return nil
 	}
 
@@ -244,6 +409,17 @@ func (client *Client) HoldSeat(seat *models.Seat, seatPrice float64) error {
 	return errors.New("SEAT_ALREADY_ONHOLD")
 }
 
+// PromoDiscount previews what the applied promo code takes off the total
+// price, the payment intent computing the final one.
+func (client *Client) PromoDiscount() float64 {
+	amount := int64(math.Round(client.totalPrice * 100))
+	discount, err := client.promoCode.Discount(amount, client.promoCurrency)
+	if err != nil {
+		return 0
+	}
+	return float64(discount) / 100
+}
+
 func (client *Client) ReserveSeats(reservationID uint) error {
 	for _, seat := range client.holdedSeats {
 		seat.Status = "reserved"
@@ -252,6 +428,9 @@ func (client *Client) ReserveSeats(reservationID uint) error {
 	}
 	client.totalPrice = 0
 	client.holdedSeats = make(map[uint]*models.Seat)
+	client.offeredSeats = make(map[uint]bool)
+	client.promoCode = nil
+	client.promoCurrency = ""
 	return nil
 }
 
@@ -22,15 +22,19 @@ type SeatChoiceSocketManager struct {
 	clients ClientList
 	reservationRepo reservationsRepo.ReservationsRepo
 	diffusions map[uint][]*models.Seat
+	seatPrices map[uint]float64
 	sync.RWMutex
 }
 
 func NewSeatChoiceSocketManager() *SeatChoiceSocketManager {
-	return &SeatChoiceSocketManager{
+	manager := &SeatChoiceSocketManager{
 		clients: make(ClientList, 0),
 		diffusions: make(map[uint][]*models.Seat, 0),
+		seatPrices: make(map[uint]float64, 0),
 		reservationRepo: *reservationsRepo.NewReservationsRepo(),
 	}
+	reservationsRepo.SetSeatHolds(manager)
+	return manager
 }
 
 func (manager *SeatChoiceSocketManager) ServeWS(w http.ResponseWriter, r *http.Request) {
@@ -75,4 +79,63 @@ func (manager *SeatChoiceSocketManager) removeClient(client *Client) {
 		client.connection.Close()
 		delete(manager.clients, client)
 	}
+
+	// Remove diffusion if all clients were deleted
+	for managerClient := range manager.clients {
+		if client.diffusionID == managerClient.diffusionID {
+			return
+		}
+	}
+	delete(manager.diffusions, client.diffusionID)
+	delete(manager.seatPrices, client.diffusionID)
+}
+
+// getSeats returns the seats of the diffusion shared by its clients, and
+// whether they were loaded.
+func (manager *SeatChoiceSocketManager) getSeats(diffusionID uint) ([]*models.Seat, float64, bool) {
+	manager.RLock()
+	defer manager.RUnlock()
+
+	seats := manager.diffusions[diffusionID]
+	return seats, manager.seatPrices[diffusionID], len(seats) > 0
+}
+
+// setSeats shares the seats loaded for the diffusion with its clients, unless
+// another client loaded them meanwhile, and returns the shared ones.
+func (manager *SeatChoiceSocketManager) setSeats(diffusionID uint, seats []*models.Seat, seatPrice float64) ([]*models.Seat, float64) {
+	manager.Lock()
+	defer manager.Unlock()
+
+	if len(manager.diffusions[diffusionID]) == 0 {
+		manager.diffusions[diffusionID] = seats
+		manager.seatPrices[diffusionID] = seatPrice
+	}
+	return manager.diffusions[diffusionID], manager.seatPrices[diffusionID]
+}
+
+// HeldSeats returns the users holding seats of the diffusion, by seat.
+func (manager *SeatChoiceSocketManager) HeldSeats(diffusionID uint) map[uint]uint {
+	manager.RLock()
+	defer manager.RUnlock()
+
+	heldSeats := make(map[uint]uint)
+	for _, seat := range manager.diffusions[diffusionID] {
+		if seat.Status == "onhold" && seat.UserID != nil {
+			heldSeats[seat.ID] = *seat.UserID
+		}
+	}
+	return heldSeats
+}
+
+// refreshClients tells the clients of the diffusion, but the sender, to send
+// their seats again. The manager lock must be held.
+func (manager *SeatChoiceSocketManager) refreshClients(diffusionID uint, sender *Client) {
+	for client := range manager.clients {
+		if client != sender && client.diffusionID == diffusionID {
+			select {
+			case client.refresh <- true:
+			default:
+			}
+		}
+	}
 }
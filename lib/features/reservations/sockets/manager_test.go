@@ -0,0 +1,260 @@
+package reservations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	websocket "github.com/gorilla/websocket"
+)
+
+func newTestManager() *SeatChoiceSocketManager {
+	return &SeatChoiceSocketManager{
+		clients:    make(ClientList, 0),
+		diffusions: make(map[uint][]*models.Seat, 0),
+		seatPrices: make(map[uint]float64, 0),
+	}
+}
+
+// newTestClient connects a client of the diffusion to a server that reads
+// what it is sent.
+func newTestClient(t *testing.T, manager *SeatChoiceSocketManager, uid uint, diffusionID uint) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := webSocketUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return NewClient(conn, manager, uid, diffusionID)
+}
+
+func newTestSeats(count int) []*models.Seat {
+	seats := make([]*models.Seat, count)
+	for index := range seats {
+		seats[index] = &models.Seat{
+			ID:          uint(index + 1),
+			DiffusionID: 1,
+			SeatRow:     "A",
+			SeatColumn:  index + 1,
+			Status:      "availble",
+		}
+	}
+	return seats
+}
+
+// TestSeatChoiceSocketConcurrency runs the socket flow of several clients
+// while the seats held are read as the HTTP handlers do. Run it with -race.
+func TestSeatChoiceSocketConcurrency(t *testing.T) {
+	manager := newTestManager()
+	const clientsCount = 8
+
+	clients := make([]*Client, clientsCount)
+	for index := range clients {
+		clients[index] = newTestClient(t, manager, uint(index+1), 1)
+	}
+
+	done := make(chan bool)
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				for seatID, userID := range manager.HeldSeats(1) {
+					if seatID == 0 || userID == 0 {
+						t.Errorf("seat %d held by user %d", seatID, userID)
+					}
+				}
+			}
+		}
+	}()
+
+	var writers sync.WaitGroup
+	for _, client := range clients {
+		writers.Add(1)
+		go func(client *Client) {
+			defer writers.Done()
+			manager.addClient(client)
+
+			seats, seatPrice, ok := manager.getSeats(1)
+			if !ok {
+				seats, seatPrice = manager.setSeats(1, newTestSeats(16), 500)
+			}
+			client.writeEvent(Event{Event: "data", Result: map[string]interface{}{"seats": seats}})
+
+			for _, seat := range seats {
+				manager.Lock()
+				client.HoldSeat(seat, seatPrice)
+				manager.Unlock()
+			}
+			client.writeEvent(Event{Event: "data", Result: map[string]interface{}{"seats": seats}})
+
+			manager.Lock()
+			client.ReserveSeats(client.uid)
+			manager.Unlock()
+
+			manager.Lock()
+			client.UnreserveSeats(seats, client.uid)
+			manager.Unlock()
+
+			manager.removeClient(client)
+		}(client)
+	}
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	if _, _, ok := manager.getSeats(1); ok {
+		t.Errorf("seats of the diffusion kept after its last client left")
+	}
+	if len(manager.clients) != 0 {
+		t.Errorf("got %d clients, want none", len(manager.clients))
+	}
+}
+
+func TestSetSeatsKeepsSharedSeats(t *testing.T) {
+	manager := newTestManager()
+	shared := newTestSeats(4)
+
+	seats, seatPrice := manager.setSeats(1, shared, 500)
+	if &seats[0] != &shared[0] || seatPrice != 500 {
+		t.Fatalf("setSeats did not share the first seats loaded")
+	}
+
+	seats, seatPrice = manager.setSeats(1, newTestSeats(4), 700)
+	if &seats[0] != &shared[0] || seatPrice != 500 {
+		t.Errorf("setSeats replaced the seats shared by the clients")
+	}
+}
+
+func TestRefreshClients(t *testing.T) {
+	manager := newTestManager()
+	sender := newTestClient(t, manager, 1, 1)
+	neighbour := newTestClient(t, manager, 2, 1)
+	stranger := newTestClient(t, manager, 3, 2)
+	for _, client := range []*Client{sender, neighbour, stranger} {
+		manager.addClient(client)
+	}
+
+	// Pending refreshes do not block the sender:
+	manager.Lock()
+	manager.refreshClients(1, sender)
+	manager.refreshClients(1, sender)
+	manager.Unlock()
+
+	if len(neighbour.refresh) != 1 {
+		t.Errorf("client of the diffusion got %d refreshes, want 1", len(neighbour.refresh))
+	}
+	if len(sender.refresh) != 0 {
+		t.Errorf("sender got %d refreshes, want none", len(sender.refresh))
+	}
+	if len(stranger.refresh) != 0 {
+		t.Errorf("client of another diffusion got %d refreshes, want none", len(stranger.refresh))
+	}
+}
+
+func TestHoldBlock(t *testing.T) {
+	hall := models.Hall{ColumnsCount: 8, AisleAfterColumns: []int{4}}
+	otherUserID := uint(2)
+
+	tests := []struct {
+		name    string
+		heldIDs []uint
+		seatIDs []uint
+		wantErr string
+	}{
+		{
+			name:    "contiguous block",
+			seatIDs: []uint{3, 1, 2},
+		},
+		{
+			name:    "one seat already held",
+			heldIDs: []uint{2},
+			seatIDs: []uint{1, 2, 3},
+			wantErr: "SEATS_NOT_AVAILABLE",
+		},
+		{
+			name:    "non contiguous seats",
+			seatIDs: []uint{1, 2, 4},
+			wantErr: "SEATS_NOT_CONTIGUOUS",
+		},
+		{
+			name:    "aisle gap",
+			seatIDs: []uint{3, 4, 5},
+			wantErr: "SEATS_NOT_CONTIGUOUS",
+		},
+		{
+			name:    "duplicate seats",
+			seatIDs: []uint{1, 2, 2},
+			wantErr: "INVALID_SEAT_ID",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := newTestManager()
+			client := newTestClient(t, manager, 1, 1)
+			seats := newTestSeats(int(hall.ColumnsCount))
+			for _, seat := range seats {
+				if slices.Contains(test.heldIDs, seat.ID) {
+					seat.Status = "onhold"
+					seat.UserID = &otherUserID
+				}
+			}
+
+			// As the holdBlock event does:
+			manager.Lock()
+			block, err := getRequestedBlock(seats, hall, test.seatIDs)
+			if err == nil {
+				err = client.HoldBlock(block, 500)
+			}
+			manager.Unlock()
+
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got error %v, want the block held", err)
+				}
+				if len(client.holdedSeats) != len(test.seatIDs) || client.totalPrice != 500*float64(len(test.seatIDs)) {
+					t.Errorf("held %d seats for %v, want %d", len(client.holdedSeats), client.totalPrice, len(test.seatIDs))
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.wantErr {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if len(client.holdedSeats) != 0 || client.totalPrice != 0 {
+				t.Errorf("held %d seats for %v, want none", len(client.holdedSeats), client.totalPrice)
+			}
+			for _, seat := range seats {
+				if slices.Contains(test.heldIDs, seat.ID) {
+					if seat.Status != "onhold" || seat.UserID != &otherUserID {
+						t.Errorf("seat %d taken from its holder", seat.ID)
+					}
+				} else if seat.Status != "availble" || seat.UserID != nil {
+					t.Errorf("seat %d left %v by user %v", seat.ID, seat.Status, seat.UserID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package reservations
+
+import (
+	models "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+)
+
+// offerFreedSeats gives the seats freed in the diffusion to its waitlist, then
+// brings the seats in line with the offers. The waitlist is processed in the
+// database, so the manager lock must not be held.
+func (manager *SeatChoiceSocketManager) offerFreedSeats(diffusionID uint) {
+	heldSeats := manager.HeldSeats(diffusionID)
+	isHeld := func(seatID uint) bool {
+		_, ok := heldSeats[seatID]
+		return ok
+	}
+
+	offers, freedSeatIDs, err := manager.reservationRepo.ProcessWaitlist(diffusionID, isHeld)
+	if err != nil {
+		return
+	}
+
+	manager.Lock()
+	defer manager.Unlock()
+
+	seatsByID := make(map[uint]*models.Seat)
+	for _, seat := range manager.diffusions[diffusionID] {
+		seatsByID[seat.ID] = seat
+	}
+
+	// Clients may have held seats while the waitlist was processed:
+	clientSeats := make(map[uint]uint)
+	for client := range manager.clients {
+		if client.diffusionID == diffusionID {
+			for seatID := range client.holdedSeats {
+				clientSeats[seatID] = client.uid
+			}
+		}
+	}
+
+	// Seats of expired offers are freed, unless a client took them:
+	isChanged := false
+	for _, seatID := range freedSeatIDs {
+		_, isTaken := clientSeats[seatID]
+		seat, ok := seatsByID[seatID]
+		if ok && seat.Status == "onhold" && !isTaken {
+			seat.Status = "availble"
+			seat.UserID = nil
+			isChanged = true
+		}
+	}
+
+	// Seats offered stay held for their user until the offer ends:
+	for _, offer := range offers {
+		userID := offer.UserID
+		for _, seatID := range offer.HeldSeatIDs {
+			holderID, isTaken := clientSeats[seatID]
+			if isTaken && holderID != userID {
+				continue
+			}
+			seat, ok := seatsByID[seatID]
+			if !ok || seat.Status == "reserved" {
+				continue
+			}
+			if seat.Status != "onhold" || seat.UserID == nil || *seat.UserID != userID {
+				seat.Status = "onhold"
+				seat.UserID = &userID
+				isChanged = true
+			}
+		}
+	}
+
+	if isChanged {
+		manager.refreshClients(diffusionID, nil)
+	}
+}
+
+// adoptOfferedSeats adds the seats the waitlist offered to the user to the
+// seats they hold. The manager lock must be held.
+func (client *Client) adoptOfferedSeats(seats []*models.Seat, seatPrice float64) {
+	for _, seat := range seats {
+		_, isHolded := client.holdedSeats[seat.ID]
+		if seat.Status == "onhold" && seat.UserID != nil && *seat.UserID == client.uid && !isHolded {
+			client.holdedSeats[seat.ID] = seat
+			client.offeredSeats[seat.ID] = true
+			client.totalPrice += seatPrice
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	loyalty "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/loyalty"
 	mysql "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/mysql"
+	notifications "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/notifications"
 	stripepayment "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/stripe_payment"
 	tmdb "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/tmdb"
 	youtube "github.com/OucheneMohamedNourElIslem658/kinema_api/lib/services/youtube"
@@ -12,6 +14,8 @@ func init() {
 	tmdb.Init()
 	youtube.Init()
 	stripepayment.Init()
+	loyalty.Init()
+	notifications.Init()
 }
 
 func main() {
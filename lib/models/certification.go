@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultCertificationCountry is the country whose certification is shown on
+// the movie, and enforced in the cinemas without a country.
+const DefaultCertificationCountry = "US"
+
+type MovieCertification struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	MovieID       uint      `gorm:"not null;uniqueIndex:idx_movie_country;constraint:OnDelete:CASCADE" json:"movieID"`
+	Country       string    `gorm:"size:2;not null;uniqueIndex:idx_movie_country" json:"country"`
+	Certification string    `gorm:"size:10" json:"certification"`
+	MinimumAge    uint      `json:"minimumAge"`
+	AdminEdited   bool      `json:"adminEdited"`
+	CreatedAt     time.Time `json:"-"`
+	UpdatedAt     time.Time `json:"-"`
+}
+
+// certificationAges maps the certifications of each country to the minimum
+// age of the audience. The ratings allowing accompanied children (PG-13, 12A)
+// are enforced as strict limits.
+var certificationAges = map[string]map[string]uint{
+	"US": {"G": 0, "PG": 0, "PG-13": 13, "R": 17, "NC-17": 18},
+	"GB": {"U": 0, "PG": 0, "12A": 12, "12": 12, "15": 15, "18": 18, "R18": 18},
+	"FR": {"U": 0, "TP": 0, "10": 10, "12": 12, "16": 16, "18": 18},
+	"DE": {"0": 0, "6": 6, "12": 12, "16": 16, "18": 18},
+	"CA": {"G": 0, "PG": 0, "14A": 14, "18A": 18, "R": 18},
+	"AE": {"G": 0, "PG": 0, "PG13": 13, "PG15": 15, "15+": 15, "18+": 18, "21+": 21},
+	"SA": {"G": 0, "PG": 0, "PG12": 12, "PG15": 15, "R15": 15, "R18": 18},
+	"EG": {"G": 0, "PG": 0, "PG12": 12, "PG16": 16, "+12": 12, "+16": 16, "+18": 18},
+}
+
+// CertificationMinimumAge returns the minimum age required by the
+// certification in the country, if it is known.
+func CertificationMinimumAge(country string, certification string) (uint, bool) {
+	ages, ok := certificationAges[strings.ToUpper(country)]
+	if !ok {
+		return 0, false
+	}
+	age, ok := ages[strings.ToUpper(strings.TrimSpace(certification))]
+	return age, ok
+}
+
+func (certification *MovieCertification) Validate() error {
+	certification.Country = strings.ToUpper(strings.TrimSpace(certification.Country))
+	certification.Certification = strings.TrimSpace(certification.Certification)
+	if certification.MovieID == 0 {
+		return errors.New("INVALID_MOVIE_ID")
+	}
+	if len(certification.Country) != 2 {
+		return errors.New("INVALID_COUNTRY")
+	}
+	if certification.Certification == "" {
+		return errors.New("INVALID_CERTIFICATION")
+	}
+
+	// The age is deduced from the certification unless given:
+	if certification.MinimumAge == 0 {
+		age, ok := CertificationMinimumAge(certification.Country, certification.Certification)
+		if !ok {
+			return errors.New("UNKNOWN_CERTIFICATION")
+		}
+		certification.MinimumAge = age
+	}
+	return nil
+}
+
+// MinimumAgeIn returns the minimum age to watch the movie in the country, from
+// its certification there (which must be loaded), otherwise from its default
+// one.
+func (movie *Movie) MinimumAgeIn(country string) uint {
+	for _, certification := range movie.Certifications {
+		if certification.Country == country {
+			return certification.MinimumAge
+		}
+	}
+	return movie.MinimumAge
+}
+
+// AgeAt returns the age of the user at the given time, on the calendar of its
+// location. Birthdays are calendar dates stored at midnight UTC, so they are
+// not moved to the location, which would turn them into the previous day west
+// of UTC.
+func (user *User) AgeAt(at time.Time) int {
+	birthYear, birthMonth, birthDay := user.BirthDay.UTC().Date()
+	year, month, day := at.Date()
+	age := year - birthYear
+	if month < birthMonth || (month == birthMonth && day < birthDay) {
+		age--
+	}
+	return age
+}
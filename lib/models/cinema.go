@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Cinema struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"unique;not null" json:"name,omitempty"`
+	Address   string         `json:"address,omitempty"`
+	Timezone  string         `gorm:"not null" json:"timezone,omitempty"`
+	Currency  string         `gorm:"size:3;not null" json:"currency,omitempty"`
+	Country   string         `gorm:"size:2" json:"country,omitempty"`
+	Halls     []Hall         `gorm:"foreignKey:CinemaID" json:"halls,omitempty"`
+	CreatedAt time.Time      `json:"-"`
+	UpdatedAt time.Time      `json:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Rate of the VAT included in the prices, like 0.2 for 20%:
+	VATRate *float64 `gorm:"not null;default:0" json:"vatRate,omitempty"`
+}
+
+func (cinema *Cinema) Validate() error {
+	if cinema.Name == "" {
+		return errors.New("INVALID_CINEMA_NAME")
+	}
+	if cinema.Timezone == "" {
+		return errors.New("INVALID_TIMEZONE")
+	}
+	if _, err := time.LoadLocation(cinema.Timezone); err != nil {
+		return errors.New("INVALID_TIMEZONE")
+	}
+	if len(cinema.Currency) != 3 {
+		return errors.New("INVALID_CURRENCY")
+	}
+	cinema.Currency = strings.ToLower(cinema.Currency)
+	cinema.Country = strings.ToUpper(cinema.Country)
+	if cinema.Country != "" && len(cinema.Country) != 2 {
+		return errors.New("INVALID_COUNTRY")
+	}
+	if cinema.VATRate != nil && (*cinema.VATRate < 0 || *cinema.VATRate >= 1) {
+		return errors.New("INVALID_VAT_RATE")
+	}
+	return nil
+}
+
+// Location returns the time zone of the cinema, UTC if it is not set.
+func (cinema *Cinema) Location() *time.Location {
+	if cinema == nil || cinema.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(cinema.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Categories of concession items:
+var ConcessionCategories = []string{"snack", "drink", "combo"}
+
+// ConcessionItem is a snack or a drink of the catalog, sold in the variants
+// (sizes, flavours) it lists.
+type ConcessionItem struct {
+	ID          uint                `gorm:"primaryKey" json:"id"`
+	Name        string              `gorm:"size:100;unique;not null" json:"name"`
+	Description string              `json:"description,omitempty"`
+	Category    string              `gorm:"size:10;not null;index" json:"category"`
+	PicURL      string              `json:"picURL,omitempty"`
+	Variants    []ConcessionVariant `gorm:"foreignKey:ItemID" json:"variants,omitempty"`
+	CreatedAt   time.Time           `json:"-"`
+	UpdatedAt   time.Time           `json:"-"`
+}
+
+type ConcessionVariant struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	ItemID    uint            `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"itemID,omitempty"`
+	Item      *ConcessionItem `json:"item,omitempty"`
+	Name      string          `gorm:"size:50;not null" json:"name"`
+	Price     float64         `gorm:"-" json:"price,omitempty"`
+	Quantity  uint            `gorm:"-" json:"quantity,omitempty"`
+	CreatedAt time.Time       `json:"-"`
+	UpdatedAt time.Time       `json:"-"`
+}
+
+// ConcessionStock is the price, in the currency of the cinema, and the
+// quantity left of a variant in a cinema. Variants without stock are not sold
+// there.
+type ConcessionStock struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CinemaID  uint      `gorm:"not null;uniqueIndex:idx_cinema_variant;constraint:OnDelete:CASCADE" json:"cinemaID"`
+	VariantID uint      `gorm:"not null;uniqueIndex:idx_cinema_variant;constraint:OnDelete:CASCADE" json:"variantID"`
+	Price     float64   `gorm:"not null" json:"price"`
+	Quantity  uint      `gorm:"not null" json:"quantity"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// ReservationItem is a concession pre-ordered with a reservation. It is held
+// from the creation of the payment intent and tied to the reservation once
+// paid, then handed over at check-in.
+type ReservationItem struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ReservationID   *uint     `gorm:"index;constraint:OnDelete:CASCADE" json:"-"`
+	UserID          uint      `gorm:"not null;index" json:"-"`
+	CinemaID        uint      `gorm:"not null" json:"-"`
+	VariantID       uint      `gorm:"not null;index" json:"variantID"`
+	Name            string    `gorm:"not null" json:"name"`
+	Quantity        uint      `gorm:"not null" json:"quantity"`
+	UnitPrice       float64   `gorm:"not null" json:"unitPrice"`
+	PaymentIntentID string    `gorm:"size:191;index" json:"-"`
+	Fulfilled       bool      `gorm:"not null" json:"fulfilled"`
+	CreatedAt       time.Time `json:"-"`
+}
+
+func (item *ConcessionItem) Validate() error {
+	item.Name = strings.TrimSpace(item.Name)
+	item.Category = strings.ToLower(strings.TrimSpace(item.Category))
+	if item.Name == "" {
+		return errors.New("INVALID_NAME")
+	}
+	if !slices.Contains(ConcessionCategories, item.Category) {
+		return errors.New("INVALID_CATEGORY")
+	}
+	if len(item.Variants) == 0 {
+		return errors.New("INVALID_VARIANTS")
+	}
+	var names []string
+	for index := range item.Variants {
+		variant := &item.Variants[index]
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" || slices.Contains(names, variant.Name) {
+			return errors.New("INVALID_VARIANTS")
+		}
+		names = append(names, variant.Name)
+	}
+	return nil
+}
+
+func (stock *ConcessionStock) Validate() error {
+	if stock.CinemaID == 0 {
+		return errors.New("INVALID_CINEMA_ID")
+	}
+	if stock.VariantID == 0 {
+		return errors.New("INVALID_VARIANT_ID")
+	}
+	if stock.Price < 0 {
+		return errors.New("INVALID_PRICE")
+	}
+	return nil
+}
+
+// Amount returns the price of the line in minor currency units.
+func (item *ReservationItem) Amount() int64 {
+	return int64(math.Round(item.UnitPrice*100)) * int64(item.Quantity)
+}
@@ -2,33 +2,51 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Hall struct {
-	ID           uint        `gorm:"primaryKey" json:"id,omitempty"`
-	Diffusions   []Diffusion `gorm:"foreignKey:HallID" json:"diffussionID,omitempty"`
-	Name         string      `gorm:"unique;not null" json:"name,omitempty"`
-	RowsCount    uint        `json:"rowsCount,omitempty"`
-	ColumnsCount uint        `json:"columnsCount,omitempty"`
+	ID           uint              `gorm:"primaryKey" json:"id,omitempty"`
+	Diffusions   []Diffusion       `gorm:"foreignKey:HallID" json:"diffussionID,omitempty"`
+	CinemaID     uint              `gorm:"uniqueIndex:idx_cinema_hall_name" json:"cinemaID,omitempty"`
+	Cinema       *Cinema           `json:"cinema,omitempty"`
+	Name         string            `gorm:"uniqueIndex:idx_cinema_hall_name;size:191;not null" json:"name,omitempty"`
+	Timezone     string            `json:"timezone,omitempty"`
+	RowsCount    uint              `json:"rowsCount,omitempty"`
+	ColumnsCount uint              `json:"columnsCount,omitempty"`
+	Formats      []string          `gorm:"serializer:json" json:"formats,omitempty"`
+	Maintenances []HallMaintenance `gorm:"foreignKey:HallID" json:"maintenances,omitempty"`
+	DeletedAt    gorm.DeletedAt    `gorm:"index" json:"-"`
+
+	// Layout used to suggest seats: the columns followed by an aisle, and the
+	// seats accessible to wheelchairs, such as "A1":
+	AisleAfterColumns []int    `gorm:"serializer:json" json:"aisleAfterColumns,omitempty"`
+	AccessibleSeats   []string `gorm:"serializer:json" json:"accessibleSeats,omitempty"`
 }
 
 type Diffusion struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	MovieID      uint           `gorm:"not null;constraint:OnDelete:CASCADE" json:"movieID,omitempty"`
-	Movie        Movie          `gorm:"foreinKey:ID" json:"movie,omitempty"`
-	ShowTime     time.Time      `gorm:"not null" json:"showTime,omitempty"`
-	ShowDuration time.Duration  `gorm:"not null" json:"showDuration,omitempty"`
-	HallID       uint           `gorm:"not null;constraint:OnDelete:CASCADE" json:"hallID,omitempty"`
-	Hall         *Hall          `gorm:"foreinKey:ID" json:"hall,omitempty"`
-	SeatPrice    float64        `gorm:"not null" json:"seatPrice,omitempty"`
-	CreatedAt    time.Time      `json:"-"`
-	UpdatedAt    time.Time      `json:"-"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
-	SeatsStatus  []Seat         `gorm:"foreignKey:DiffusionID" json:"status,omitempty"`
-	Reservations []Reservation  `gorm:"foreignKey:DiffusionID" json:"reservations,omitempty"`
+	ID                  uint           `gorm:"primaryKey" json:"id"`
+	MovieID             uint           `gorm:"not null;constraint:OnDelete:CASCADE" json:"movieID,omitempty"`
+	Movie               Movie          `gorm:"foreinKey:ID" json:"movie,omitempty"`
+	ShowTime            time.Time      `gorm:"not null" json:"showTime,omitempty"`
+	LocalShowTime       *time.Time     `gorm:"-" json:"localShowTime,omitempty"`
+	Timezone            string         `gorm:"-" json:"timezone,omitempty"`
+	ShowDuration        time.Duration  `gorm:"not null" json:"showDuration,omitempty"`
+	HallID              uint           `gorm:"not null;constraint:OnDelete:CASCADE" json:"hallID,omitempty"`
+	Hall                *Hall          `gorm:"foreinKey:ID" json:"hall,omitempty"`
+	SeatPrice           float64        `gorm:"not null" json:"seatPrice,omitempty"`
+	TicketPrice         float64        `gorm:"-" json:"ticketPrice,omitempty"`
+	SeriesID            *uint          `gorm:"index" json:"seriesID,omitempty"`
+	MaintenanceConflict bool           `gorm:"not null;default:false" json:"maintenanceConflict,omitempty"`
+	CreatedAt           time.Time      `json:"-"`
+	UpdatedAt           time.Time      `json:"-"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+	SeatsStatus         []Seat         `gorm:"foreignKey:DiffusionID" json:"status,omitempty"`
+	Reservations        []Reservation  `gorm:"foreignKey:DiffusionID" json:"reservations,omitempty"`
+	DiffusionVersion
 }
 
 type Seat struct {
@@ -57,10 +75,13 @@ func (diffusion *Diffusion) Validate() error {
 	if diffusion.HallID == 0 {
 		return errors.New("INVALID_HALL_ID")
 	}
-	return nil
+	return diffusion.DiffusionVersion.Validate()
 }
 
 func (hall *Hall) ValidateHall() error {
+	if hall.CinemaID == 0 {
+		return errors.New("INVALID_CINEMA_ID")
+	}
 	if hall.Name == "" {
 		return errors.New("INVALID_HALL_NAME")
 	}
@@ -70,9 +91,56 @@ func (hall *Hall) ValidateHall() error {
 	if hall.ColumnsCount == 0 {
 		return errors.New("INVALID_COLUMNS_COUNT")
 	}
+	if hall.Timezone != "" {
+		if _, err := time.LoadLocation(hall.Timezone); err != nil {
+			return errors.New("INVALID_TIMEZONE")
+		}
+	}
+	if err := hall.ValidateLayout(); err != nil {
+		return err
+	}
+	return hall.ValidateFormats()
+}
+
+// Location returns the time zone the hall schedules in: its own if set,
+// otherwise the one of its cinema (which must be loaded), otherwise UTC.
+func (hall *Hall) Location() *time.Location {
+	if hall == nil {
+		return time.UTC
+	}
+	if hall.Timezone != "" {
+		if location, err := time.LoadLocation(hall.Timezone); err == nil {
+			return location
+		}
+	}
+	return hall.Cinema.Location()
+}
+
+// DeletedHallName renames a deleted hall, so that its name can be used again
+// in its cinema.
+func DeletedHallName(name string, id uint) string {
+	suffix := fmt.Sprintf(" #deleted-%v", id)
+	if len(name)+len(suffix) > 191 {
+		name = name[:191-len(suffix)]
+	}
+	return name + suffix
+}
+
+// Show times are always stored in UTC.
+func (diffusion *Diffusion) BeforeSave(tx *gorm.DB) error {
+	diffusion.ShowTime = diffusion.ShowTime.UTC()
 	return nil
 }
 
+// SetLocalTime fills the local show time of the diffusion in the given
+// location, next to the UTC one.
+func (diffusion *Diffusion) SetLocalTime(location *time.Location) {
+	diffusion.ShowTime = diffusion.ShowTime.UTC()
+	localShowTime := diffusion.ShowTime.In(location)
+	diffusion.LocalShowTime = &localShowTime
+	diffusion.Timezone = location.String()
+}
+
 func (seat *Seat) ValidateSeat() error {
 	if seat.ID == 0 {
 		return errors.New("INVALID_SEAT_ID")
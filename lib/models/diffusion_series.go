@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type DiffusionSeries struct {
+	ID              uint          `gorm:"primaryKey" json:"id"`
+	MovieID         uint          `gorm:"not null;constraint:OnDelete:CASCADE" json:"movieID,omitempty"`
+	HallID          uint          `gorm:"not null;constraint:OnDelete:CASCADE" json:"hallID,omitempty"`
+	FromDate        time.Time     `gorm:"not null" json:"fromDate"`
+	ToDate          time.Time     `gorm:"not null" json:"toDate"`
+	ShowTimes       []string      `gorm:"serializer:json;not null" json:"showTimes"`
+	SkippedWeekdays []string      `gorm:"serializer:json" json:"skippedWeekdays,omitempty"`
+	ShowDuration    time.Duration `gorm:"not null" json:"showDuration,omitempty"`
+	SeatPrice       float64       `gorm:"not null" json:"seatPrice,omitempty"`
+	Diffusions      []Diffusion   `gorm:"foreignKey:SeriesID;constraint:OnDelete:SET NULL" json:"diffusions,omitempty"`
+	CreatedAt       time.Time     `json:"-"`
+	UpdatedAt       time.Time     `json:"-"`
+	DiffusionVersion
+}
+
+const showTimeLayout = "15:04"
+
+func (series *DiffusionSeries) Validate() error {
+	if series.MovieID == 0 {
+		return errors.New("INVALID_MOVIE_ID")
+	}
+	if series.HallID == 0 {
+		return errors.New("INVALID_HALL_ID")
+	}
+	if series.FromDate.IsZero() || series.ToDate.IsZero() || series.ToDate.Before(series.FromDate) {
+		return errors.New("INVALID_DATE_RANGE")
+	}
+	if series.ToDate.After(series.FromDate.AddDate(1, 0, 0)) {
+		return errors.New("DATE_RANGE_TOO_LONG")
+	}
+	if len(series.ShowTimes) == 0 {
+		return errors.New("INVALID_SHOW_TIMES")
+	}
+	for _, showTime := range series.ShowTimes {
+		if _, err := time.Parse(showTimeLayout, showTime); err != nil {
+			return errors.New("INVALID_SHOW_TIMES")
+		}
+	}
+	for _, weekday := range series.SkippedWeekdays {
+		if _, ok := parseWeekday(weekday); !ok {
+			return errors.New("INVALID_SKIPPED_WEEKDAYS")
+		}
+	}
+	if series.ShowDuration <= 0 {
+		return errors.New("INVALID_SHOW_DURATION")
+	}
+	if series.SeatPrice <= 0 {
+		return errors.New("INVALID_SEAT_PRICE")
+	}
+	return series.DiffusionVersion.Validate()
+}
+
+// Occurrences expands the series into the show times it describes, skipping
+// the excluded weekdays and any show time that is already in the past.
+// The calendar dates and the hours are read in the location of the hall, so a
+// show stays at the same local hour across DST transitions.
+func (series *DiffusionSeries) Occurrences(location *time.Location) []time.Time {
+	skipped := make(map[time.Weekday]bool)
+	for _, weekday := range series.SkippedWeekdays {
+		if day, ok := parseWeekday(weekday); ok {
+			skipped[day] = true
+		}
+	}
+
+	now := time.Now()
+	fromDay := time.Date(series.FromDate.Year(), series.FromDate.Month(), series.FromDate.Day(), 0, 0, 0, 0, location)
+	toDay := time.Date(series.ToDate.Year(), series.ToDate.Month(), series.ToDate.Day(), 0, 0, 0, 0, location)
+
+	var occurrences []time.Time
+	for day := fromDay; !day.After(toDay); day = day.AddDate(0, 0, 1) {
+		if skipped[day.Weekday()] {
+			continue
+		}
+		for _, showTime := range series.ShowTimes {
+			hour, _ := time.Parse(showTimeLayout, showTime)
+			occurrence := time.Date(day.Year(), day.Month(), day.Day(), hour.Hour(), hour.Minute(), 0, 0, location)
+			if occurrence.Before(now) {
+				continue
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if day.String() == name {
+			return day, true
+		}
+	}
+	return 0, false
+}
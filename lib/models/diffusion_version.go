@@ -0,0 +1,129 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const DefaultFormat = "2D"
+
+// DiffusionFormats are the projection formats a hall can support. Every hall
+// supports the default one.
+var DiffusionFormats = []string{DefaultFormat, "3D", "IMAX", "IMAX_3D", "4DX"}
+
+// NoSubtitles filters the diffusions shown without subtitles.
+const NoSubtitles = "none"
+
+// DiffusionVersion tells in which version a movie is shown: its audio and
+// subtitle languages (ISO 639-1 codes) and its projection format.
+type DiffusionVersion struct {
+	AudioLanguage    string `gorm:"size:2;index" json:"audioLanguage,omitempty"`
+	SubtitleLanguage string `gorm:"size:2" json:"subtitleLanguage,omitempty"`
+	Format           string `gorm:"size:10;not null;default:2D;index" json:"format,omitempty"`
+}
+
+type FormatSurcharge struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CinemaID  uint      `gorm:"not null;uniqueIndex:idx_cinema_format;constraint:OnDelete:CASCADE" json:"cinemaID"`
+	Format    string    `gorm:"size:10;not null;uniqueIndex:idx_cinema_format" json:"format"`
+	Amount    float64   `gorm:"not null" json:"amount"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+func (version *DiffusionVersion) Normalize() {
+	version.AudioLanguage = strings.ToLower(strings.TrimSpace(version.AudioLanguage))
+	version.SubtitleLanguage = strings.ToLower(strings.TrimSpace(version.SubtitleLanguage))
+	version.Format = strings.ToUpper(strings.TrimSpace(version.Format))
+}
+
+// Validate normalizes the version and checks it, defaulting to the default
+// format.
+func (version *DiffusionVersion) Validate() error {
+	version.Normalize()
+	if version.Format == "" {
+		version.Format = DefaultFormat
+	}
+	if !isLanguageCode(version.AudioLanguage) {
+		return errors.New("INVALID_AUDIO_LANGUAGE")
+	}
+	if !isLanguageCode(version.SubtitleLanguage) {
+		return errors.New("INVALID_SUBTITLE_LANGUAGE")
+	}
+	if !slices.Contains(DiffusionFormats, version.Format) {
+		return errors.New("INVALID_FORMAT")
+	}
+	return nil
+}
+
+// ValidateFilter checks a version used to filter the diffusions, where every
+// attribute is optional.
+func (version *DiffusionVersion) ValidateFilter() error {
+	version.Normalize()
+	if !isLanguageCode(version.AudioLanguage) {
+		return errors.New("INVALID_AUDIO_LANGUAGE")
+	}
+	if version.SubtitleLanguage != NoSubtitles && !isLanguageCode(version.SubtitleLanguage) {
+		return errors.New("INVALID_SUBTITLE_LANGUAGE")
+	}
+	if version.Format != "" && !slices.Contains(DiffusionFormats, version.Format) {
+		return errors.New("INVALID_FORMAT")
+	}
+	return nil
+}
+
+func (hall *Hall) ValidateFormats() error {
+	for index, format := range hall.Formats {
+		hall.Formats[index] = strings.ToUpper(strings.TrimSpace(format))
+		if !slices.Contains(DiffusionFormats, hall.Formats[index]) {
+			return errors.New("INVALID_FORMATS")
+		}
+	}
+	return nil
+}
+
+func (hall *Hall) SupportsFormat(format string) bool {
+	return format == DefaultFormat || slices.Contains(hall.Formats, format)
+}
+
+func (surcharge *FormatSurcharge) Validate() error {
+	surcharge.Format = strings.ToUpper(strings.TrimSpace(surcharge.Format))
+	if surcharge.CinemaID == 0 {
+		return errors.New("INVALID_CINEMA_ID")
+	}
+	if !slices.Contains(DiffusionFormats, surcharge.Format) {
+		return errors.New("INVALID_FORMAT")
+	}
+	if surcharge.Amount < 0 {
+		return errors.New("INVALID_AMOUNT")
+	}
+	return nil
+}
+
+// SetTicketPrice fills the price of a seat of the diffusion, surcharged
+// according to its format.
+func (diffusion *Diffusion) SetTicketPrice(surcharges []FormatSurcharge) {
+	diffusion.TicketPrice = diffusion.SeatPrice
+	for _, surcharge := range surcharges {
+		if surcharge.Format == diffusion.Format {
+			diffusion.TicketPrice += surcharge.Amount
+		}
+	}
+}
+
+func isLanguageCode(language string) bool {
+	if language == "" {
+		return true
+	}
+	if len(language) != 2 {
+		return false
+	}
+	for _, letter := range language {
+		if letter < 'a' || letter > 'z' {
+			return false
+		}
+	}
+	return true
+}
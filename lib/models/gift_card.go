@@ -0,0 +1,86 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Kinds of gift card transactions:
+const (
+	GiftCardPurchase = "purchase"
+	GiftCardRedeem   = "redeem"
+	GiftCardRefund   = "refund"
+	GiftCardRelease  = "release"
+)
+
+// Payment method of the part of a reservation paid with gift cards:
+const GiftCardPaymentMethod = "gift_card"
+
+const giftCardCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GiftCard is a stored-value card bought through Stripe. Its balance is kept
+// in minor units of its currency.
+type GiftCard struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Code            string    `gorm:"size:32;unique;not null" json:"code"`
+	Currency        string    `gorm:"size:3;not null" json:"currency"`
+	InitialBalance  int64     `gorm:"not null" json:"initialBalance"`
+	Balance         int64     `gorm:"not null" json:"balance"`
+	PurchaserID     uint      `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"-"`
+	PaymentIntentID string    `gorm:"size:191;unique;not null" json:"-"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"-"`
+}
+
+// GiftCardTransaction records a change of the balance of a gift card, positive
+// for credits and negative for spendings. Redemptions are held from the
+// creation of the payment intent and tied to the reservation once paid.
+type GiftCardTransaction struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	GiftCardID      uint      `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"giftCardID"`
+	GiftCard        *GiftCard `json:"giftCard,omitempty"`
+	UserID          uint      `gorm:"not null;index" json:"-"`
+	Kind            string    `gorm:"size:10;not null" json:"kind"`
+	Amount          int64     `gorm:"not null" json:"amount"`
+	ReservationID   *uint     `gorm:"index" json:"reservationID,omitempty"`
+	PaymentIntentID string    `gorm:"size:191;index" json:"-"`
+	CreatedAt       time.Time `json:"createdAt"`
+
+	// Redemptions whose amount was given back, the intent not being paid:
+	Released bool `gorm:"not null;default:false" json:"-"`
+}
+
+// NewGiftCardCode returns a random code such as ABCD-EFGH-JKLM-NPQR, leaving
+// out the characters that are easily mistaken for one another.
+func NewGiftCardCode() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for index, value := range bytes {
+		if index > 0 && index%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(giftCardCodeAlphabet[int(value)%len(giftCardCodeAlphabet)])
+	}
+	return code.String(), nil
+}
+
+func NormalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Spendable tells whether the card can pay in the given currency.
+func (giftCard *GiftCard) Spendable(currency string) error {
+	if !strings.EqualFold(giftCard.Currency, currency) {
+		return errors.New("GIFT_CARD_CURRENCY_MISMATCH")
+	}
+	if giftCard.Balance <= 0 {
+		return errors.New("GIFT_CARD_EMPTY")
+	}
+	return nil
+}
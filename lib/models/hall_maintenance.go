@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type HallMaintenance struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	HallID    uint      `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"hallID,omitempty"`
+	FromDate  time.Time `gorm:"not null" json:"fromDate"`
+	ToDate    time.Time `gorm:"not null" json:"toDate"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+func (maintenance *HallMaintenance) Validate() error {
+	if maintenance.HallID == 0 {
+		return errors.New("INVALID_HALL_ID")
+	}
+	if maintenance.FromDate.IsZero() || maintenance.ToDate.IsZero() || !maintenance.ToDate.After(maintenance.FromDate) {
+		return errors.New("INVALID_DATE_RANGE")
+	}
+	if maintenance.ToDate.Before(time.Now()) {
+		return errors.New("MAINTENANCE_ALREADY_OVER")
+	}
+	return nil
+}
+
+func (maintenance *HallMaintenance) Overlaps(diffusion Diffusion) bool {
+	diffusionEnd := diffusion.ShowTime.Add(diffusion.ShowDuration)
+	return diffusion.ShowTime.Before(maintenance.ToDate) && maintenance.FromDate.Before(diffusionEnd)
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ImportSourceNowPlaying = "now_playing"
+	ImportSourceUpcoming   = "upcoming"
+	ImportSourceIDs        = "ids"
+)
+
+const (
+	ImportJobPending   = "pending"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+const (
+	ImportItemPending  = "pending"
+	ImportItemImported = "imported"
+	ImportItemSkipped  = "skipped"
+	ImportItemFailed   = "failed"
+)
+
+type ImportJob struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	Source      string          `gorm:"size:20;not null" json:"source"`
+	TMDBIDs     []uint          `gorm:"column:tmdb_ids;serializer:json" json:"tmdbIDs,omitempty"`
+	Pages       int             `json:"pages,omitempty"`
+	PO          string          `json:"p_o,omitempty"`
+	Status      string          `gorm:"size:20;not null" json:"status"`
+	Error       string          `json:"error,omitempty"`
+	CreatedByID uint            `json:"createdByID,omitempty"`
+	Items       []ImportJobItem `gorm:"foreignKey:ImportJobID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"-"`
+	FinishedAt  *time.Time      `json:"finishedAt,omitempty"`
+}
+
+type ImportJobItem struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ImportJobID    uint      `gorm:"not null;index" json:"-"`
+	TMDBID         uint      `gorm:"column:tmdb_id;not null" json:"tmdbID"`
+	Title          string    `json:"title,omitempty"`
+	TrailerVideoID string    `json:"trailerVideoID,omitempty"`
+	Status         string    `gorm:"size:20;not null" json:"status"`
+	Error          string    `json:"error,omitempty"`
+	MovieID        *uint     `json:"movieID,omitempty"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+const maxImportPages = 5
+
+func (job *ImportJob) Validate() error {
+	switch job.Source {
+	case ImportSourceNowPlaying, ImportSourceUpcoming:
+		if job.Pages == 0 {
+			job.Pages = 1
+		}
+		if job.Pages < 0 || job.Pages > maxImportPages {
+			return errors.New("INVALID_PAGES")
+		}
+	case ImportSourceIDs:
+		if len(job.TMDBIDs) == 0 {
+			return errors.New("INVALID_TMDB_IDS")
+		}
+		for _, tmdbID := range job.TMDBIDs {
+			if tmdbID == 0 {
+				return errors.New("INVALID_TMDB_IDS")
+			}
+		}
+	default:
+		return errors.New("INVALID_SOURCE")
+	}
+	if job.PO == "" {
+		return errors.New("INVALID_PO")
+	}
+	return nil
+}
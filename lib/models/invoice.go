@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Invoice is the VAT invoice of a reservation. It is issued once, with the
+// next number of its year, and keeps what it states even when the reservation
+// changes or is canceled.
+type Invoice struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Number        string         `gorm:"size:20;unique;not null" json:"number"`
+	Year          int            `gorm:"not null;uniqueIndex:idx_invoice_year_sequence" json:"-"`
+	Sequence      uint           `gorm:"not null;uniqueIndex:idx_invoice_year_sequence" json:"-"`
+	ReservationID uint           `gorm:"not null;unique" json:"reservationID"`
+	UserID        uint           `gorm:"not null;index" json:"-"`
+	CinemaID      uint           `gorm:"not null;index" json:"cinemaID"`
+	Seller        string         `gorm:"not null" json:"seller"`
+	SellerAddress string         `json:"sellerAddress,omitempty"`
+	Billing       InvoiceBilling `gorm:"embedded;embeddedPrefix:billing_" json:"billing"`
+	Lines         []InvoiceLine  `gorm:"serializer:json" json:"lines"`
+	PaymentMethod string         `gorm:"not null" json:"paymentMethod"`
+	Currency      string         `gorm:"size:3;not null" json:"currency"`
+	VATRate       float64        `gorm:"not null" json:"vatRate"`
+	NetAmount     uint           `gorm:"not null" json:"netAmount"`
+	TaxAmount     uint           `gorm:"not null" json:"taxAmount"`
+	Amount        uint           `gorm:"not null" json:"amount"`
+	IssuedAt      time.Time      `gorm:"not null" json:"issuedAt"`
+}
+
+// InvoiceBilling is who the invoice is addressed to, a company giving its VAT
+// number.
+type InvoiceBilling struct {
+	Name      string `json:"name"`
+	Address   string `json:"address,omitempty"`
+	VATNumber string `gorm:"size:30" json:"vatNumber,omitempty"`
+}
+
+// InvoiceLine is a line of an invoice, its amounts in minor currency units,
+// tax included.
+type InvoiceLine struct {
+	Description string `json:"description"`
+	Quantity    uint   `json:"quantity"`
+	Amount      uint   `json:"amount"`
+}
+
+// InvoiceSequence holds the last invoice number issued in a year.
+type InvoiceSequence struct {
+	Year     int  `gorm:"primaryKey;autoIncrement:false"`
+	Sequence uint `gorm:"not null"`
+}
+
+func (billing *InvoiceBilling) Validate() error {
+	billing.Name = strings.TrimSpace(billing.Name)
+	billing.Address = strings.TrimSpace(billing.Address)
+	billing.VATNumber = strings.ToUpper(strings.ReplaceAll(billing.VATNumber, " ", ""))
+	if len(billing.Name) > 100 || len(billing.Address) > 200 {
+		return errors.New("INVALID_BILLING")
+	}
+	if len(billing.VATNumber) > 30 {
+		return errors.New("INVALID_VAT_NUMBER")
+	}
+	return nil
+}
+
+// InvoiceNumber formats the number of the invoice of the year.
+func InvoiceNumber(year int, sequence uint) string {
+	return fmt.Sprintf("INV-%d-%06d", year, sequence)
+}
+
+// SetAmounts splits the amount of the lines into its net amount and its tax,
+// prices including the VAT.
+func (invoice *Invoice) SetAmounts() {
+	invoice.Amount = 0
+	for _, line := range invoice.Lines {
+		invoice.Amount += line.Amount
+	}
+	invoice.NetAmount = uint(math.Round(float64(invoice.Amount) / (1 + invoice.VATRate)))
+	invoice.TaxAmount = invoice.Amount - invoice.NetAmount
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Kinds of loyalty ledger entries:
+const (
+	LoyaltyEarn     = "earn"
+	LoyaltyRedeem   = "redeem"
+	LoyaltyReversal = "reversal"
+	LoyaltyRelease  = "release"
+)
+
+// LoyaltyEntry records a change of the fidelity points of a user, positive for
+// grants and negative for spendings. The balance is kept in
+// User.FidelityPoints.
+type LoyaltyEntry struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          uint      `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"userID,omitempty"`
+	Kind            string    `gorm:"size:10;not null" json:"kind"`
+	Points          int       `gorm:"not null" json:"points"`
+	ReservationID   *uint     `gorm:"index" json:"reservationID,omitempty"`
+	PaymentIntentID string    `gorm:"size:191;index" json:"-"`
+	CreatedAt       time.Time `json:"createdAt"`
+
+	// Redemptions whose points were given back, the intent not being paid:
+	Released bool `gorm:"not null;default:false" json:"-"`
+}
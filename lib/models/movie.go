@@ -7,34 +7,62 @@ import (
 )
 
 type Movie struct {
-	ID           uint           `gorm:"primaryKey" json:"id,omitempty"`
-	Title        string         `gorm:"not null" json:"title,omitempty"`
-	Description  string         `json:"description,omitempty"`
-	Type         []Type         `gorm:"many2many:movie_types;" json:"type,omitempty"`
-	Language     string         `json:"language,omitempty"`
-	Cast         []Actor        `gorm:"many2many:movie_actors;" json:"cast,omitempty"`
-	Rate         float64        `json:"rate,omitempty"`
-	TrailerURL   string         `json:"trailerURL,omitempty"`
-	Duration     time.Duration  `json:"duration,omitempty"`
-	VoteCount    uint           `json:"voteCount,omitempty"`
-	TrailerViews uint           `json:"trailerViews,omitempty"`
-	PicURL       string         `json:"picURL,omitempty"`
-	PO           string         `json:"p_o,omitempty"`
-	Diffusions   []Diffusion    `gorm:"foreignKey:MovieID" json:"diffusions,omitempty"`
-	CreatedAt    time.Time      `json:"-"`
-	UpdatedAt    time.Time      `json:"-"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint                 `gorm:"primaryKey" json:"id,omitempty"`
+	TMDBID         *uint                `gorm:"column:tmdb_id;uniqueIndex" json:"tmdbID,omitempty"`
+	Title          string               `gorm:"not null" json:"title,omitempty"`
+	Description    string               `json:"description,omitempty"`
+	Type           []Type               `gorm:"many2many:movie_types;" json:"type,omitempty"`
+	Language       string               `json:"language,omitempty"`
+	Cast           []Actor              `gorm:"many2many:movie_actors;" json:"cast,omitempty"`
+	Rate           float64              `json:"rate,omitempty"`
+	TrailerURL     string               `json:"trailerURL,omitempty"`
+	Duration       time.Duration        `json:"duration,omitempty"`
+	VoteCount      uint                 `json:"voteCount,omitempty"`
+	TrailerViews   uint                 `json:"trailerViews,omitempty"`
+	PicURL         string               `json:"picURL,omitempty"`
+	PO             string               `json:"p_o,omitempty"`
+	Certification  string               `gorm:"size:10" json:"certification,omitempty"`
+	MinimumAge     uint                 `json:"minimumAge,omitempty"`
+	Certifications []MovieCertification `gorm:"foreignKey:MovieID;constraint:OnDelete:CASCADE" json:"certifications,omitempty"`
+	Diffusions     []Diffusion          `gorm:"foreignKey:MovieID" json:"diffusions,omitempty"`
+	Translations   []MovieTranslation   `gorm:"foreignKey:MovieID;constraint:OnDelete:CASCADE" json:"translations,omitempty"`
+	// Fields edited by an admin, left untouched by the metadata refresh:
+	AdminOverrides []string       `gorm:"serializer:json" json:"adminOverrides,omitempty"`
+	LastSyncedAt   *time.Time     `json:"lastSyncedAt,omitempty"`
+	CreatedAt      time.Time      `json:"-"`
+	UpdatedAt      time.Time      `json:"-"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+func (movie *Movie) IsOverridden(field string) bool {
+	for _, overridden := range movie.AdminOverrides {
+		if overridden == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (movie *Movie) AddOverride(field string) {
+	if !movie.IsOverridden(field) {
+		movie.AdminOverrides = append(movie.AdminOverrides, field)
+	}
+}
+
+// Actor is a member of the cast of movies. Homonyms are different actors, told
+// apart by their TMDB id.
 type Actor struct {
 	ID     uint    `gorm:"primaryKey" json:"id"`
-	Name   string  `gorm:"unique;not null" json:"name"`
+	TMDBID uint    `gorm:"column:tmdb_id;index" json:"tmdbID,omitempty"`
+	Name   string  `gorm:"size:191;not null;index" json:"name"`
 	PicURL string  `gorm:"type:text" json:"picURL"`
 	Movies []Movie `gorm:"many2many:movie_actors;" json:"movies,omitempty"`
 }
 
 type Type struct {
-	ID     uint    `gorm:"primaryKey" json:"id"`
-	Name   string  `gorm:"unique,not null" json:"name"`
-	Movies []Movie `gorm:"many2many:movie_types;" json:"movies,omitempty"`
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	TMDBID       uint              `gorm:"column:tmdb_id;index" json:"tmdbID,omitempty"`
+	Name         string            `gorm:"unique,not null" json:"name"`
+	Movies       []Movie           `gorm:"many2many:movie_types;" json:"movies,omitempty"`
+	Translations []TypeTranslation `gorm:"foreignKey:TypeID;constraint:OnDelete:CASCADE" json:"translations,omitempty"`
 }
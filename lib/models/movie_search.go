@@ -0,0 +1,170 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+	maxSearchQueryLength  = 100
+)
+
+// MovieSearch holds the text query, the filters and the page of a catalog
+// search. Nil bounds are left open.
+type MovieSearch struct {
+	Query       string
+	TypeIDs     []uint
+	Language    string
+	MinRate     *float64
+	MaxRate     *float64
+	MinDuration *time.Duration
+	MaxDuration *time.Duration
+	From        *time.Time
+	To          *time.Time
+	Page        int
+	PageSize    int
+}
+
+// ParseMovieSearch reads a search from query parameters: q, typeID (repeated
+// or comma separated), language, minRate, maxRate, minDuration and
+// maxDuration (in minutes), from and to (dates or RFC 3339 times), page and
+// pageSize. Dates are days in the given location.
+func ParseMovieSearch(values url.Values, location *time.Location) (MovieSearch, error) {
+	search := MovieSearch{
+		Query:    strings.TrimSpace(values.Get("q")),
+		Language: strings.TrimSpace(values.Get("language")),
+		Page:     1,
+		PageSize: defaultSearchPageSize,
+	}
+
+	for _, value := range values["typeID"] {
+		for _, part := range strings.Split(value, ",") {
+			typeID, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+			if err != nil || typeID == 0 {
+				return search, errors.New("INVALID_TYPE_ID")
+			}
+			search.TypeIDs = append(search.TypeIDs, uint(typeID))
+		}
+	}
+
+	var err error
+	if search.MinRate, err = parseRate(values.Get("minRate")); err != nil {
+		return search, errors.New("INVALID_MIN_RATE")
+	}
+	if search.MaxRate, err = parseRate(values.Get("maxRate")); err != nil {
+		return search, errors.New("INVALID_MAX_RATE")
+	}
+	if search.MinDuration, err = parseMinutes(values.Get("minDuration")); err != nil {
+		return search, errors.New("INVALID_MIN_DURATION")
+	}
+	if search.MaxDuration, err = parseMinutes(values.Get("maxDuration")); err != nil {
+		return search, errors.New("INVALID_MAX_DURATION")
+	}
+	if search.From, _, err = parseSearchTime(values.Get("from"), location); err != nil {
+		return search, errors.New("INVALID_FROM")
+	}
+	var dateOnly bool
+	if search.To, dateOnly, err = parseSearchTime(values.Get("to"), location); err != nil {
+		return search, errors.New("INVALID_TO")
+	}
+	if dateOnly {
+		// The window ends with the given day:
+		endOfDay := search.To.AddDate(0, 0, 1)
+		search.To = &endOfDay
+	}
+
+	if page := values.Get("page"); page != "" {
+		if search.Page, err = strconv.Atoi(page); err != nil {
+			return search, errors.New("INVALID_PAGE")
+		}
+	}
+	if pageSize := values.Get("pageSize"); pageSize != "" {
+		if search.PageSize, err = strconv.Atoi(pageSize); err != nil {
+			return search, errors.New("INVALID_PAGE_SIZE")
+		}
+	}
+
+	return search, search.Validate()
+}
+
+func (search *MovieSearch) Validate() error {
+	if len(search.Query) > maxSearchQueryLength {
+		return errors.New("QUERY_TOO_LONG")
+	}
+	if search.MinRate != nil && (*search.MinRate < 0 || *search.MinRate > 10) {
+		return errors.New("INVALID_MIN_RATE")
+	}
+	if search.MaxRate != nil && (*search.MaxRate < 0 || *search.MaxRate > 10) {
+		return errors.New("INVALID_MAX_RATE")
+	}
+	if search.MinRate != nil && search.MaxRate != nil && *search.MinRate > *search.MaxRate {
+		return errors.New("INVALID_RATE_RANGE")
+	}
+	if search.MinDuration != nil && *search.MinDuration < 0 {
+		return errors.New("INVALID_MIN_DURATION")
+	}
+	if search.MaxDuration != nil && *search.MaxDuration < 0 {
+		return errors.New("INVALID_MAX_DURATION")
+	}
+	if search.MinDuration != nil && search.MaxDuration != nil && *search.MinDuration > *search.MaxDuration {
+		return errors.New("INVALID_DURATION_RANGE")
+	}
+	if search.From != nil && search.To != nil && search.To.Before(*search.From) {
+		return errors.New("INVALID_DATE_RANGE")
+	}
+	if search.Page < 1 {
+		return errors.New("INVALID_PAGE")
+	}
+	if search.PageSize < 1 || search.PageSize > maxSearchPageSize {
+		return errors.New("INVALID_PAGE_SIZE")
+	}
+	return nil
+}
+
+// HasDiffusionWindow tells whether the search only keeps the movies with
+// upcoming diffusions in the [From, To) window.
+func (search *MovieSearch) HasDiffusionWindow() bool {
+	return search.From != nil || search.To != nil
+}
+
+func parseRate(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func parseMinutes(value string) (*time.Duration, error) {
+	if value == "" {
+		return nil, nil
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	duration := time.Duration(minutes) * time.Minute
+	return &duration, nil
+}
+
+func parseSearchTime(value string, location *time.Location) (*time.Time, bool, error) {
+	if value == "" {
+		return nil, false, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, false, nil
+	}
+	parsed, err := time.ParseInLocation(time.DateOnly, value, location)
+	if err != nil {
+		return nil, false, err
+	}
+	return &parsed, true, nil
+}
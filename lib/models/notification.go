@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Kinds of notifications sent about reservations and waitlist offers:
+const (
+	NotificationConfirmation  = "confirmation"
+	NotificationReminder      = "reminder"
+	NotificationCancellation  = "cancellation"
+	NotificationWaitlistOffer = "waitlistOffer"
+)
+
+// Delivery statuses of notifications:
+const (
+	NotificationPending  = "pending"
+	NotificationSending  = "sending"
+	NotificationSent     = "sent"
+	NotificationFailed   = "failed"
+	NotificationCanceled = "canceled"
+)
+
+// Notification is an email about a reservation, queued along with the change
+// it reports and sent once SendAt is reached. It outlives the reservation so
+// that cancellation receipts keep their delivery status. Waitlist offers are
+// not about a reservation, and have no ReservationID.
+type Notification struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ReservationID uint       `gorm:"not null;index" json:"reservationID"`
+	UserID        uint       `gorm:"not null;index" json:"-"`
+	Kind          string     `gorm:"size:15;not null" json:"kind"`
+	Email         string     `gorm:"not null" json:"email"`
+	Subject       string     `gorm:"not null" json:"subject"`
+	Body          string     `gorm:"type:text;not null" json:"-"`
+	Status        string     `gorm:"size:10;not null;index" json:"status"`
+	Attempts      uint       `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `json:"lastError,omitempty"`
+	SendAt        time.Time  `gorm:"not null;index" json:"sendAt"`
+	SentAt        *time.Time `json:"sentAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"-"`
+}
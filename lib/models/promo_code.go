@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of promo code discounts:
+const (
+	PromoPercentage = "percentage"
+	PromoFixed      = "fixed"
+)
+
+// PromoCode is a discount campaign. Its value is a percentage of the amount,
+// or an amount in minor units of its currency. Empty restrictions and zero
+// limits are not enforced.
+type PromoCode struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Code           string     `gorm:"size:32;unique;not null" json:"code"`
+	Description    string     `json:"description,omitempty"`
+	DiscountType   string     `gorm:"size:10;not null" json:"discountType"`
+	Value          float64    `gorm:"not null" json:"value"`
+	Currency       string     `gorm:"size:3" json:"currency,omitempty"`
+	StartsAt       *time.Time `json:"startsAt,omitempty"`
+	EndsAt         *time.Time `json:"endsAt,omitempty"`
+	MaxUses        uint       `json:"maxUses,omitempty"`
+	MaxUsesPerUser uint       `json:"maxUsesPerUser,omitempty"`
+	MovieIDs       []uint     `gorm:"serializer:json" json:"movieIDs,omitempty"`
+	HallIDs        []uint     `gorm:"serializer:json" json:"hallIDs,omitempty"`
+	Weekdays       []string   `gorm:"serializer:json" json:"weekdays,omitempty"`
+	Active         bool       `gorm:"not null;default:true" json:"active"`
+	UsesCount      int64      `gorm:"-" json:"usesCount"`
+	CreatedAt      time.Time  `json:"-"`
+	UpdatedAt      time.Time  `json:"-"`
+}
+
+// PromoRedemption records the use of a promo code. It is held from the
+// creation of the payment intent and tied to the reservation once paid.
+type PromoRedemption struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	PromoCodeID     uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"promoCodeID"`
+	PromoCode       *PromoCode `json:"promoCode,omitempty"`
+	UserID          uint       `gorm:"not null;index" json:"-"`
+	ReservationID   *uint      `gorm:"index" json:"reservationID,omitempty"`
+	DiffusionID     uint       `gorm:"index" json:"diffusionID,omitempty"`
+	PaymentIntentID string     `gorm:"size:191;index" json:"-"`
+	Discount        int64      `gorm:"not null" json:"discount"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+func (promoCode *PromoCode) Validate() error {
+	promoCode.Code = NormalizePromoCode(promoCode.Code)
+	if promoCode.Code == "" || len(promoCode.Code) > 32 {
+		return errors.New("INVALID_CODE")
+	}
+	switch promoCode.DiscountType {
+	case PromoPercentage:
+		if promoCode.Value <= 0 || promoCode.Value > 100 {
+			return errors.New("INVALID_VALUE")
+		}
+	case PromoFixed:
+		if promoCode.Value <= 0 || promoCode.Value != math.Trunc(promoCode.Value) {
+			return errors.New("INVALID_VALUE")
+		}
+		if len(promoCode.Currency) != 3 {
+			return errors.New("INVALID_CURRENCY")
+		}
+	default:
+		return errors.New("INVALID_DISCOUNT_TYPE")
+	}
+	promoCode.Currency = strings.ToLower(promoCode.Currency)
+	if promoCode.StartsAt != nil && promoCode.EndsAt != nil && !promoCode.EndsAt.After(*promoCode.StartsAt) {
+		return errors.New("INVALID_VALIDITY_WINDOW")
+	}
+	for _, weekday := range promoCode.Weekdays {
+		if _, ok := parseWeekday(weekday); !ok {
+			return errors.New("INVALID_WEEKDAYS")
+		}
+	}
+	return nil
+}
+
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// CheckDiffusion tells whether the code can be used now to book the
+// diffusion, whose hall must be loaded.
+func (promoCode *PromoCode) CheckDiffusion(diffusion Diffusion) error {
+	now := time.Now()
+	if !promoCode.Active {
+		return errors.New("PROMO_CODE_INACTIVE")
+	}
+	if promoCode.StartsAt != nil && now.Before(*promoCode.StartsAt) {
+		return errors.New("PROMO_CODE_NOT_STARTED")
+	}
+	if promoCode.EndsAt != nil && !now.Before(*promoCode.EndsAt) {
+		return errors.New("PROMO_CODE_EXPIRED")
+	}
+	if len(promoCode.MovieIDs) > 0 && !slices.Contains(promoCode.MovieIDs, diffusion.MovieID) {
+		return errors.New("PROMO_CODE_NOT_APPLICABLE")
+	}
+	if len(promoCode.HallIDs) > 0 && !slices.Contains(promoCode.HallIDs, diffusion.HallID) {
+		return errors.New("PROMO_CODE_NOT_APPLICABLE")
+	}
+	if len(promoCode.Weekdays) > 0 {
+		weekday := diffusion.ShowTime.In(diffusion.Hall.Location()).Weekday()
+		if !slices.Contains(promoCode.Weekdays, weekday.String()) {
+			return errors.New("PROMO_CODE_NOT_APPLICABLE")
+		}
+	}
+	return nil
+}
+
+// Discount returns what the code takes off an amount in minor currency units.
+func (promoCode *PromoCode) Discount(amount int64, currency string) (int64, error) {
+	switch promoCode.DiscountType {
+	case PromoPercentage:
+		return int64(math.Round(float64(amount) * promoCode.Value / 100)), nil
+	case PromoFixed:
+		if !strings.EqualFold(promoCode.Currency, currency) {
+			return 0, errors.New("PROMO_CODE_CURRENCY_MISMATCH")
+		}
+		return min(int64(promoCode.Value), amount), nil
+	}
+	return 0, errors.New("INVALID_DISCOUNT_TYPE")
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -21,6 +24,27 @@ type Reservation struct {
 	CreatedAt     time.Time      `json:"-"`
 	UpdatedAt     time.Time      `json:"-"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Promo code used to pay the reservation, if any:
+	PromoRedemption *PromoRedemption `gorm:"foreignKey:ReservationID" json:"promoRedemption,omitempty"`
+
+	// Part of the amount paid with gift cards, the rest being charged by Stripe:
+	GiftCardAmount uint `gorm:"not null;default:0" json:"giftCardAmount,omitempty"`
+
+	// Concessions pre-ordered with the seats:
+	Items []ReservationItem `gorm:"foreignKey:ReservationID" json:"items,omitempty"`
+
+	// Code scanned at the entrance, random so that it cannot be guessed:
+	TicketCode *string `gorm:"size:32;uniqueIndex" json:"-"`
+}
+
+// NewTicketCode returns a random code such as RES-0123456789ABCDEF0123.
+func NewTicketCode() (string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "RES-" + strings.ToUpper(hex.EncodeToString(bytes)), nil
 }
 
 func (reservation *Reservation) ValidateAdd() error {
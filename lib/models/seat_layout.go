@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SeatLabel names a seat by its row and column, such as "A1".
+func SeatLabel(row string, column int) string {
+	return row + strconv.Itoa(column)
+}
+
+func (hall *Hall) ValidateLayout() error {
+	for _, column := range hall.AisleAfterColumns {
+		if column <= 0 || column >= int(hall.ColumnsCount) {
+			return errors.New("INVALID_AISLE_COLUMNS")
+		}
+	}
+	for index, label := range hall.AccessibleSeats {
+		label = strings.ToUpper(strings.TrimSpace(label))
+		hall.AccessibleSeats[index] = label
+		if len(label) < 2 || label[0] < 'A' || int(label[0]-'A') >= int(hall.RowsCount) {
+			return errors.New("INVALID_ACCESSIBLE_SEATS")
+		}
+		column, err := strconv.Atoi(label[1:])
+		if err != nil || column <= 0 || column > int(hall.ColumnsCount) {
+			return errors.New("INVALID_ACCESSIBLE_SEATS")
+		}
+	}
+	return nil
+}
+
+// HasAisleAfter tells whether an aisle separates the column from the next one.
+func (hall *Hall) HasAisleAfter(column int) bool {
+	return slices.Contains(hall.AisleAfterColumns, column)
+}
+
+// IsAisleSeat tells whether the seats of the column can be left without
+// passing in front of others.
+func (hall *Hall) IsAisleSeat(column int) bool {
+	return column == 1 || column == int(hall.ColumnsCount) || hall.HasAisleAfter(column) || hall.HasAisleAfter(column-1)
+}
+
+func (hall *Hall) IsAccessibleSeat(seat *Seat) bool {
+	return slices.Contains(hall.AccessibleSeats, SeatLabel(seat.SeatRow, seat.SeatColumn))
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// SupportedLocales are the locales in which the movies metadata is stored.
+var SupportedLocales = []string{"en", "fr", "ar"}
+
+type MovieTranslation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	MovieID     uint      `gorm:"not null;uniqueIndex:idx_movie_locale" json:"movieID"`
+	Locale      string    `gorm:"size:5;not null;uniqueIndex:idx_movie_locale" json:"locale"`
+	Title       string    `json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	AdminEdited bool      `json:"adminEdited"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+type TypeTranslation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	TypeID      uint      `gorm:"not null;uniqueIndex:idx_type_locale" json:"typeID"`
+	Locale      string    `gorm:"size:5;not null;uniqueIndex:idx_type_locale" json:"locale"`
+	Name        string    `json:"name"`
+	AdminEdited bool      `json:"adminEdited"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+func IsSupportedLocale(locale string) bool {
+	return slices.Contains(SupportedLocales, locale)
+}
+
+func (translation *MovieTranslation) Validate() error {
+	if translation.MovieID == 0 {
+		return errors.New("INVALID_MOVIE_ID")
+	}
+	if !IsSupportedLocale(translation.Locale) {
+		return errors.New("UNSUPPORTED_LOCALE")
+	}
+	if translation.Title == "" && translation.Description == "" {
+		return errors.New("EMPTY_TRANSLATION")
+	}
+	return nil
+}
+
+func (translation *TypeTranslation) Validate() error {
+	if translation.TypeID == 0 {
+		return errors.New("INVALID_TYPE_ID")
+	}
+	if !IsSupportedLocale(translation.Locale) {
+		return errors.New("UNSUPPORTED_LOCALE")
+	}
+	if translation.Name == "" {
+		return errors.New("EMPTY_TRANSLATION")
+	}
+	return nil
+}
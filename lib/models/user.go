@@ -8,25 +8,31 @@ import (
 )
 
 type User struct {
-	ID             uint           `gorm:"primaryKey" json:"id"`
-	Email          string         `gorm:"unique;not null" json:"email"`
-	Password       string         `gorm:"not null" json:"password"`
-	FullName       string         `gorm:"not null" json:"fullName"`
-	BirthDay       time.Time      `gorm:"not null" json:"birthday"`
-	Gender         string         `gorm:"size:1;not null" json:"gender"`
-	PicURL         string         `gorm:"not null" json:"picURL"`
-	EmailVerified  bool           `json:"emailVerified"`
-	PhoneNumber    string         `json:"phoneNumber"`
-	Nationality    string         `json:"nationality"`
-	Address        string         `json:"address"`
-	PostalCode     uint           `json:"postalCode"`
-	IsAdmin        bool           `gorm:"not null" json:"isAdmin"`
-	FidelityPoints uint           `gorm:"not null" json:"fidelityPoints"`
-	AuthProviders  []AuthProvider `gorm:"many2many:user_auth_providers" json:"-"`
-	Reservations   []Reservation  `gorm:"foreignKey:UserID" json:"reservations,omitempty"`
-	CreatedAt      time.Time      `json:"createdAt"`
-	UpdatedAt      time.Time      `json:"updatedAt"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	Email           string         `gorm:"unique;not null" json:"email"`
+	Password        string         `gorm:"not null" json:"password"`
+	FullName        string         `gorm:"not null" json:"fullName"`
+	BirthDay        time.Time      `gorm:"not null" json:"birthday"`
+	Gender          string         `gorm:"size:1;not null" json:"gender"`
+	PicURL          string         `gorm:"not null" json:"picURL"`
+	EmailVerified   bool           `json:"emailVerified"`
+	PhoneNumber     string         `json:"phoneNumber"`
+	Nationality     string         `json:"nationality"`
+	Address         string         `json:"address"`
+	PostalCode      uint           `json:"postalCode"`
+	IsAdmin         bool           `gorm:"not null" json:"isAdmin"`
+	FidelityPoints  uint           `gorm:"not null" json:"fidelityPoints"`
+	DefaultCinemaID *uint          `gorm:"constraint:OnDelete:SET NULL" json:"defaultCinemaID,omitempty"`
+	DefaultCinema   *Cinema        `json:"defaultCinema,omitempty"`
+	AdminCinemas    []Cinema       `gorm:"many2many:cinema_admins" json:"adminCinemas,omitempty"`
+	AuthProviders   []AuthProvider `gorm:"many2many:user_auth_providers" json:"-"`
+	Reservations    []Reservation  `gorm:"foreignKey:UserID" json:"reservations,omitempty"`
+	CreatedAt       time.Time      `json:"createdAt"`
+	UpdatedAt       time.Time      `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Secret of the calendar feed of the reservations of the user:
+	CalendarToken *string `gorm:"size:64;unique" json:"-"`
 }
 
 type AuthProvider struct {
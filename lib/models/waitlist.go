@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Statuses of waitlist entries:
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistOffered = "offered"
+	WaitlistBooked  = "booked"
+	WaitlistExpired = "expired"
+)
+
+// WaitlistEntry is a party waiting for seats of a sold-out diffusion. Freed
+// seats are offered in turn: they are held for the party until the offer
+// expires.
+type WaitlistEntry struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	DiffusionID    uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"diffusionID"`
+	UserID         uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"-"`
+	PartySize      uint       `gorm:"not null" json:"partySize"`
+	Status         string     `gorm:"size:10;not null;index" json:"status"`
+	HeldSeatIDs    []uint     `gorm:"serializer:json" json:"heldSeatIDs,omitempty"`
+	OfferExpiresAt *time.Time `json:"offerExpiresAt,omitempty"`
+	Position       int64      `gorm:"-" json:"position,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"-"`
+}
+
+func (entry *WaitlistEntry) Validate() error {
+	if entry.DiffusionID == 0 {
+		return errors.New("INVALID_DIFFUSION_ID")
+	}
+	if entry.UserID == 0 {
+		return errors.New("INVALID_USER_ID")
+	}
+	if entry.PartySize == 0 {
+		return errors.New("INVALID_PARTY_SIZE")
+	}
+	return nil
+}
+
+// IsActive tells whether the entry still waits for, or holds, seats.
+func (entry *WaitlistEntry) IsActive() bool {
+	return entry.Status == WaitlistWaiting || entry.Status == WaitlistOffered
+}
@@ -0,0 +1,68 @@
+package loyalty
+
+import (
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// Config holds how many points a currency unit earns and how much a point is
+// worth at checkout. Both rates can be set per currency, in the
+// LOYALTY_EARN_RATES and LOYALTY_POINT_VALUES variables written as
+// "usd:1,eur:1,dzd:0.01". Point values are whole minor currency units, so
+// the other ones are ignored.
+type Config struct {
+	// Points earned per major currency unit paid:
+	DefaultEarnRate float64
+	EarnRates       map[string]float64
+	// Minor currency units a redeemed point takes off the payment:
+	DefaultPointValue int64
+	PointValues       map[string]int64
+	// Least amount, in minor currency units, a payment intent can charge:
+	MinimumCharge int64
+}
+
+var loyaltyConfig = initConfig()
+
+func initConfig() Config {
+	godotenv.Load()
+	config := Config{
+		DefaultEarnRate:   1,
+		EarnRates:         make(map[string]float64),
+		DefaultPointValue: 1,
+		PointValues:       make(map[string]int64),
+		MinimumCharge:     50,
+	}
+
+	for currency, rate := range parseRates(os.Getenv("LOYALTY_EARN_RATES")) {
+		config.EarnRates[currency] = rate
+	}
+	for currency, value := range parseRates(os.Getenv("LOYALTY_POINT_VALUES")) {
+		if value < 1 || value != math.Trunc(value) {
+			log.Printf("ignoring loyalty point value %v of %v: not a whole number of minor units", value, currency)
+			continue
+		}
+		config.PointValues[currency] = int64(value)
+	}
+	return config
+}
+
+func parseRates(value string) map[string]float64 {
+	rates := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		currency, rateString, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			continue
+		}
+		rate, err := strconv.ParseFloat(rateString, 64)
+		if err != nil || rate < 0 {
+			continue
+		}
+		rates[strings.ToLower(currency)] = rate
+	}
+	return rates
+}
@@ -0,0 +1,31 @@
+package loyalty
+
+import (
+	"math"
+	"strings"
+)
+
+var Instance Config
+
+func Init() {
+	Instance = loyaltyConfig
+}
+
+// PointsEarned returns the points a payment of the given amount, in minor
+// currency units, earns.
+func (config Config) PointsEarned(amount int64, currency string) uint {
+	rate, ok := config.EarnRates[strings.ToLower(currency)]
+	if !ok {
+		rate = config.DefaultEarnRate
+	}
+	return uint(math.Floor(float64(amount) / 100 * rate))
+}
+
+// PointValue returns the minor currency units a redeemed point is worth.
+func (config Config) PointValue(currency string) int64 {
+	value, ok := config.PointValues[strings.ToLower(currency)]
+	if !ok {
+		return config.DefaultPointValue
+	}
+	return value
+}
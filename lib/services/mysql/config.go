@@ -13,6 +13,9 @@ type config struct {
 	dbHost     string
 	dbPort     string
 	dbName     string
+	// IANA time zone of the host of the API before dates were written in
+	// UTC, such as "Africa/Algiers":
+	dbLegacyTimezone string
 }
 
 var envs = initAPI()
@@ -25,12 +28,17 @@ func initAPI() config {
 		dbHost:     os.Getenv("DB_HOST"),
 		dbPort:     os.Getenv("DB_PORT"),
 		dbName:     os.Getenv("DB_NAME"),
+
+		dbLegacyTimezone: os.Getenv("DB_LEGACY_TIMEZONE"),
 	}
 }
 
+// Dates are read and written in UTC. The rows written before were in the
+// time zone of the host of the API, the driver converting dates to it: they
+// are converted to UTC once by migrateLegacyDates.
 func (config config) getDatabaseDSN() string {
 	return fmt.Sprintf(
-		"%v:%v@tcp(%v:%v)/%v?charset=utf8mb4&parseTime=True&loc=Local",
+		"%v:%v@tcp(%v:%v)/%v?charset=utf8mb4&parseTime=True&loc=UTC",
 		config.dbUser,
 		config.dbPassword,
 		config.dbHost,
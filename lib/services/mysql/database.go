@@ -3,6 +3,7 @@ package mysql
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
 	"gorm.io/driver/mysql"
@@ -15,11 +16,21 @@ func Init() {
 	dsn := envs.getDatabaseDSN()
 
 	var err error
-	Instance, err = gorm.Open(mysql.Open(dsn))
+	Instance, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
+	err = migrateLegacyDates()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	err = migrateTables()
 	if err != nil {
 		log.Fatal(err)
@@ -35,13 +46,35 @@ func migrateTables() error {
 		&models.Actor{},
 		&models.Type{},
 		&models.Movie{},
+		&models.MovieTranslation{},
+		&models.TypeTranslation{},
+		&models.MovieCertification{},
+		&models.Cinema{},
+		&models.FormatSurcharge{},
 		&models.Seat{},
 		&models.Hall{},
+		&models.HallMaintenance{},
 		&models.Diffusion{},
+		&models.DiffusionSeries{},
 		&models.Reservation{},
+		&models.ConcessionItem{},
+		&models.ConcessionVariant{},
+		&models.ConcessionStock{},
+		&models.ReservationItem{},
+		&models.WaitlistEntry{},
+		&models.Notification{},
+		&models.Invoice{},
+		&models.InvoiceSequence{},
+		&models.LoyaltyEntry{},
+		&models.PromoCode{},
+		&models.PromoRedemption{},
+		&models.GiftCard{},
+		&models.GiftCardTransaction{},
+		&models.ImportJob{},
+		&models.ImportJobItem{},
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	return migrateAfterTables()
 }
@@ -0,0 +1,176 @@
+package mysql
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/OucheneMohamedNourElIslem658/kinema_api/lib/models"
+	"gorm.io/gorm"
+)
+
+// Cinema given to the halls created before halls belonged to cinemas:
+const defaultCinemaName = "Default cinema"
+
+// Migration converting the dates written before they were written in UTC:
+const utcDatesMigration = "utc_dates"
+
+// Rows whose dates are converted at once:
+const legacyDatesBatch = 1000
+
+// Date columns of the tables created before dates were written in UTC:
+var legacyDateColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"users", []string{"birth_day", "created_at", "updated_at", "deleted_at"}},
+	{"auth_providers", []string{"created_at", "updated_at", "deleted_at"}},
+	{"movies", []string{"created_at", "updated_at", "deleted_at"}},
+	{"diffusions", []string{"show_time", "created_at", "updated_at", "deleted_at"}},
+	{"reservations", []string{"created_at", "updated_at", "deleted_at"}},
+}
+
+// schemaMigration records a one-off migration applied to the database.
+type schemaMigration struct {
+	Name      string `gorm:"primaryKey;size:191"`
+	AppliedAt time.Time
+}
+
+// migrateLegacyDates converts the dates written in the time zone of the host
+// of the API, set in DB_LEGACY_TIMEZONE, to UTC. The API refuses to start
+// until they are converted. A new database has nothing to convert.
+func migrateLegacyDates() error {
+	migrator := Instance.Migrator()
+	if err := Instance.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	var count int64
+	err := Instance.Model(&schemaMigration{}).Where("name = ?", utcDatesMigration).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+
+	var tables []string
+	for _, legacyTable := range legacyDateColumns {
+		if migrator.HasTable(legacyTable.table) {
+			tables = append(tables, legacyTable.table)
+		}
+	}
+	var location *time.Location
+	if len(tables) > 0 {
+		if envs.dbLegacyTimezone == "" {
+			return errors.New("DB_LEGACY_TIMEZONE_REQUIRED: set the time zone the API host wrote dates in to convert them to UTC")
+		}
+		location, err = time.LoadLocation(envs.dbLegacyTimezone)
+		if err != nil {
+			return errors.New("INVALID_DB_LEGACY_TIMEZONE")
+		}
+	}
+
+	return Instance.Transaction(func(tx *gorm.DB) error {
+		for _, legacyTable := range legacyDateColumns {
+			if !slices.Contains(tables, legacyTable.table) {
+				continue
+			}
+			for _, column := range legacyTable.columns {
+				if !migrator.HasColumn(legacyTable.table, column) {
+					continue
+				}
+				if err := convertLegacyDates(tx, legacyTable.table, column, location); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Create(&schemaMigration{Name: utcDatesMigration, AppliedAt: time.Now()}).Error
+	})
+}
+
+// legacyDate is a date of a legacy column, with the id of its row.
+type legacyDate struct {
+	ID   uint
+	Date time.Time
+}
+
+// convertLegacyDates rewrites the dates of the column, read as UTC by the
+// connection, as the UTC time of the same wall clock in the legacy location.
+func convertLegacyDates(tx *gorm.DB, table string, column string, location *time.Location) error {
+	var lastID uint
+	for {
+		var dates []legacyDate
+		err := tx.Table(table).
+			Select("id", column+" AS date").
+			Where(column+" IS NOT NULL AND id > ?", lastID).
+			Order("id").
+			Limit(legacyDatesBatch).
+			Find(&dates).Error
+		if err != nil || len(dates) == 0 {
+			return err
+		}
+
+		for _, date := range dates {
+			year, month, day := date.Date.Date()
+			hour, minute, second := date.Date.Clock()
+			utcDate := time.Date(year, month, day, hour, minute, second, date.Date.Nanosecond(), location).UTC()
+			err := tx.Table(table).Where("id = ?", date.ID).UpdateColumn(column, utcDate).Error
+			if err != nil {
+				return err
+			}
+		}
+		lastID = dates[len(dates)-1].ID
+	}
+}
+
+// migrateAfterTables fills the columns AutoMigrate added to existing tables.
+func migrateAfterTables() error {
+	if err := assignDefaultCinema(); err != nil {
+		return err
+	}
+	return assignTicketCodes()
+}
+
+// assignDefaultCinema moves the halls that have no cinema into a default one,
+// so that they are in the scope of its admins and of super admins.
+func assignDefaultCinema() error {
+	var count int64
+	err := Instance.Unscoped().Model(&models.Hall{}).Where("cinema_id IS NULL").Count(&count).Error
+	if err != nil || count == 0 {
+		return err
+	}
+
+	cinema := models.Cinema{
+		Name:     defaultCinemaName,
+		Timezone: "UTC",
+		Currency: "usd",
+	}
+	err = Instance.Where(models.Cinema{Name: cinema.Name}).FirstOrCreate(&cinema).Error
+	if err != nil {
+		return err
+	}
+
+	return Instance.Unscoped().Model(&models.Hall{}).Where("cinema_id IS NULL").Update("cinema_id", cinema.ID).Error
+}
+
+// assignTicketCodes gives a ticket code to the reservations made before they
+// had one.
+func assignTicketCodes() error {
+	var reservations []models.Reservation
+	err := Instance.Unscoped().Select("id").Where("ticket_code IS NULL").Find(&reservations).Error
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		ticketCode, err := models.NewTicketCode()
+		if err != nil {
+			return err
+		}
+		err = Instance.Unscoped().Model(&models.Reservation{}).
+			Where("id = ? AND ticket_code IS NULL", reservation.ID).
+			Update("ticket_code", ticketCode).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package notifications
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config holds the SMTP server notifications are sent through, and when they
+// are sent. The reminder delay is written as a duration, like "24h" or "90m",
+// in the NOTIFICATIONS_REMINDER_BEFORE variable.
+type Config struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// Time before the show time the reminder is sent at:
+	ReminderBefore time.Duration
+	// Sending attempts before a notification is marked as failed:
+	MaxAttempts uint
+}
+
+var notificationsConfig = initConfig()
+
+func initConfig() Config {
+	godotenv.Load()
+	config := Config{
+		Host:           os.Getenv("SMTP_HOST"),
+		Port:           os.Getenv("SMTP_PORT"),
+		Username:       os.Getenv("SMTP_USERNAME"),
+		Password:       os.Getenv("SMTP_PASSWORD"),
+		From:           os.Getenv("SMTP_FROM"),
+		ReminderBefore: 24 * time.Hour,
+		MaxAttempts:    5,
+	}
+
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	if config.From == "" {
+		config.From = config.Username
+	}
+	if reminderBefore, err := time.ParseDuration(os.Getenv("NOTIFICATIONS_REMINDER_BEFORE")); err == nil && reminderBefore > 0 {
+		config.ReminderBefore = reminderBefore
+	}
+	if maxAttempts, err := strconv.ParseUint(os.Getenv("NOTIFICATIONS_MAX_ATTEMPTS"), 10, 32); err == nil && maxAttempts > 0 {
+		config.MaxAttempts = uint(maxAttempts)
+	}
+	return config
+}
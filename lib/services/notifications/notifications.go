@@ -0,0 +1,116 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+var Instance Config
+
+func Init() {
+	Instance = notificationsConfig
+}
+
+type Attachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+type Message struct {
+	To          string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Send sends the message by email.
+func (config Config) Send(message Message) error {
+	if config.Host == "" || config.From == "" {
+		return errors.New("NOTIFICATIONS_NOT_CONFIGURED")
+	}
+
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	content, err := config.BuildMIME(message)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(
+		net.JoinHostPort(config.Host, config.Port),
+		auth,
+		config.From,
+		[]string{message.To},
+		content,
+	)
+}
+
+// BuildMIME writes the message as a multipart MIME email, with its body as
+// plain text followed by its attachments.
+func (config Config) BuildMIME(message Message) ([]byte, error) {
+	var content bytes.Buffer
+	writer := multipart.NewWriter(&content)
+
+	fmt.Fprintf(&content, "From: %v\r\n", config.From)
+	fmt.Fprintf(&content, "To: %v\r\n", message.To)
+	fmt.Fprintf(&content, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&content, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&content, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&content, "Content-Type: multipart/mixed; boundary=%v\r\n\r\n", writer.Boundary())
+
+	body, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64(body, []byte(message.Body)); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range message.Attachments {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(attachment.ContentType, map[string]string{"name": attachment.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, attachment.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// writeBase64 encodes the content in lines of 76 characters, as MIME wants.
+func writeBase64(writer io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		if _, err := fmt.Fprintf(writer, "%v\r\n", encoded[:76]); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := fmt.Fprintf(writer, "%v\r\n", encoded)
+	return err
+}
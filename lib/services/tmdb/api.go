@@ -1,7 +1,7 @@
 package tmdb
 
-var Instance Config
+var Instance *Client
 
 func Init() {
-	Instance = tmdbAPI
-}
\ No newline at end of file
+	Instance = NewClient(tmdbAPI)
+}
@@ -0,0 +1,86 @@
+package tmdb
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// responseCache keeps the most recently used response bodies for a limited
+// time. It is safe for concurrent use.
+type responseCache struct {
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	mu      sync.Mutex
+}
+
+type cacheEntry struct {
+	key       string
+	body      []byte
+	expiresAt time.Time
+}
+
+func newResponseCache(size int, ttl time.Duration) *responseCache {
+	return &responseCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (cache *responseCache) get(key string) ([]byte, bool) {
+	if cache.size <= 0 {
+		return nil, false
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		return nil, false
+	}
+
+	cache.order.MoveToFront(element)
+	return entry.body, true
+}
+
+func (cache *responseCache) add(key string, body []byte) {
+	if cache.size <= 0 {
+		return
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		entry.body = body
+		entry.expiresAt = time.Now().Add(cache.ttl)
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(&cacheEntry{
+		key:       key,
+		body:      body,
+		expiresAt: time.Now().Add(cache.ttl),
+	})
+
+	// Evict the least recently used entries:
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
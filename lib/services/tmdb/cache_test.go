@@ -0,0 +1,49 @@
+package tmdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCacheExpires(t *testing.T) {
+	cache := newResponseCache(2, 50*time.Millisecond)
+	cache.add("/movie/1?", []byte("dune"))
+
+	if body, ok := cache.get("/movie/1?"); !ok || string(body) != "dune" {
+		t.Fatalf("get = %q, %v, want the cached body", body, ok)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := cache.get("/movie/1?"); ok {
+		t.Errorf("got an expired entry")
+	}
+	if len(cache.entries) != 0 || cache.order.Len() != 0 {
+		t.Errorf("expired entry kept in the cache")
+	}
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newResponseCache(2, time.Minute)
+	cache.add("a", []byte("a"))
+	cache.add("b", []byte("b"))
+	cache.get("a")
+	cache.add("c", []byte("c"))
+
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.get(key); !ok {
+			t.Errorf("entry %q was evicted", key)
+		}
+	}
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	cache := newResponseCache(0, time.Minute)
+	cache.add("a", []byte("a"))
+
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("cache of size 0 kept an entry")
+	}
+}
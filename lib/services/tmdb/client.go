@@ -0,0 +1,261 @@
+package tmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("TMDB_RESOURCE_NOT_FOUND")
+
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("tmdb: status %d: %s", err.StatusCode, err.Message)
+}
+
+type Client struct {
+	config     Config
+	httpClient *http.Client
+	limiter    *rateLimiter
+	cache      *responseCache
+}
+
+func NewClient(config Config) *Client {
+	return &Client{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+		},
+		limiter: newRateLimiter(config.RequestsPerSecond),
+		cache:   newResponseCache(config.CacheSize, config.CacheTTL),
+	}
+}
+
+// ImageURL returns the full poster or profile URL, or an empty string when
+// TMDB has no image for the resource.
+func (client *Client) ImageURL(path *string) string {
+	if path == nil || *path == "" {
+		return ""
+	}
+	return client.config.ImagesBaseURL + *path
+}
+
+func (client *Client) SearchMovies(ctx context.Context, query string, page int) (*MoviesPage, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page))
+	}
+
+	var movies MoviesPage
+	err := client.get(ctx, "/search/movie", params, &movies)
+	if err != nil {
+		return nil, err
+	}
+	return &movies, nil
+}
+
+func (client *Client) GetNowPlayingMovies(ctx context.Context, page int) (*MoviesPage, error) {
+	return client.getMoviesList(ctx, "/movie/now_playing", page)
+}
+
+func (client *Client) GetUpcomingMovies(ctx context.Context, page int) (*MoviesPage, error) {
+	return client.getMoviesList(ctx, "/movie/upcoming", page)
+}
+
+func (client *Client) getMoviesList(ctx context.Context, path string, page int) (*MoviesPage, error) {
+	params := url.Values{}
+	if page > 0 {
+		params.Set("page", strconv.Itoa(page))
+	}
+
+	var movies MoviesPage
+	err := client.get(ctx, path, params, &movies)
+	if err != nil {
+		return nil, err
+	}
+	return &movies, nil
+}
+
+// GetMovie fetches the movie details, in the given language when it is not
+// empty (e.g. "fr-FR").
+func (client *Client) GetMovie(ctx context.Context, id int, language string) (*MovieDetails, error) {
+	params := url.Values{}
+	if language != "" {
+		params.Set("language", language)
+	}
+
+	var movie MovieDetails
+	err := client.get(ctx, fmt.Sprintf("/movie/%d", id), params, &movie)
+	if err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (client *Client) GetMovieVideos(ctx context.Context, id int) (*Videos, error) {
+	var videos Videos
+	err := client.get(ctx, fmt.Sprintf("/movie/%d/videos", id), nil, &videos)
+	if err != nil {
+		return nil, err
+	}
+	return &videos, nil
+}
+
+func (client *Client) GetMovieCredits(ctx context.Context, id int) (*Credits, error) {
+	var credits Credits
+	err := client.get(ctx, fmt.Sprintf("/movie/%d/credits", id), nil, &credits)
+	if err != nil {
+		return nil, err
+	}
+	return &credits, nil
+}
+
+func (client *Client) GetMovieReleaseDates(ctx context.Context, id int) (*ReleaseDates, error) {
+	var releaseDates ReleaseDates
+	err := client.get(ctx, fmt.Sprintf("/movie/%d/release_dates", id), nil, &releaseDates)
+	if err != nil {
+		return nil, err
+	}
+	return &releaseDates, nil
+}
+
+func (client *Client) get(ctx context.Context, path string, params url.Values, target any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	// The api key is left out of the cache key:
+	cacheKey := path + "?" + params.Encode()
+	if body, ok := client.cache.get(cacheKey); ok {
+		return json.Unmarshal(body, target)
+	}
+
+	params.Set("api_key", client.config.ApiKey)
+	endpoint := client.config.BaseURL + path + "?" + params.Encode()
+
+	body, err := client.do(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, target)
+	if err != nil {
+		return err
+	}
+
+	client.cache.add(cacheKey, body)
+	return nil
+}
+
+func (client *Client) do(ctx context.Context, endpoint string) ([]byte, error) {
+	backoff := 500 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		err := client.limiter.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Accept", "application/json")
+
+		response, err := client.httpClient.Do(request)
+		if err != nil {
+			if ctx.Err() != nil || attempt >= client.config.MaxRetries {
+				return nil, err
+			}
+			if !sleep(ctx, backoff) {
+				return nil, ctx.Err()
+			}
+			backoff *= 2
+			continue
+		}
+
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode == http.StatusOK {
+			return body, nil
+		}
+
+		if response.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+
+		retryable := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+		if !retryable || attempt >= client.config.MaxRetries {
+			return nil, &APIError{
+				StatusCode: response.StatusCode,
+				Message:    statusMessage(body),
+			}
+		}
+
+		// Respect the delay asked by TMDB, for every request of the client:
+		delay := backoff
+		if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+			delay = retryAfter
+		}
+		if response.StatusCode == http.StatusTooManyRequests {
+			client.limiter.pause(time.Now().Add(delay))
+		} else if !sleep(ctx, delay) {
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
+
+func statusMessage(body []byte) string {
+	var payload struct {
+		StatusMessage string `json:"status_message"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.StatusMessage != "" {
+		return payload.StatusMessage
+	}
+	return strings.TrimSpace(string(body))
+}
+
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,186 @@
+package tmdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer answers the requests with the given handler, and records them.
+type testServer struct {
+	*httptest.Server
+	requests []*http.Request
+	mu       sync.Mutex
+}
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, attempt int)) *testServer {
+	server := &testServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.requests = append(server.requests, r)
+		attempt := len(server.requests)
+		server.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r, attempt)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *testServer) requestsCount() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return len(server.requests)
+}
+
+func (server *testServer) client(config Config) *Client {
+	config.ApiKey = "key"
+	config.BaseURL = server.URL
+	config.ImagesBaseURL = "https://image.tmdb.org/t/p/w300"
+	config.Timeout = 5 * time.Second
+	return NewClient(config)
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if attempt <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"id": 438631, "title": "Dune"}`))
+	})
+
+	movie, err := server.client(Config{MaxRetries: 3}).GetMovie(context.Background(), 438631, "")
+	if err != nil {
+		t.Fatalf("GetMovie: %v", err)
+	}
+	if movie.Title != "Dune" {
+		t.Errorf("title = %q, want Dune", movie.Title)
+	}
+	if count := server.requestsCount(); count != 3 {
+		t.Errorf("sent %d requests, want 3", count)
+	}
+}
+
+func TestClientGivesUpAfterMaxRetries(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status_message": "Service unavailable"}`))
+	})
+
+	_, err := server.client(Config{MaxRetries: 2}).GetMovie(context.Background(), 1, "")
+
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusServiceUnavailable || apiError.Message != "Service unavailable" {
+		t.Fatalf("err = %v, want a 503 api error", err)
+	}
+	if count := server.requestsCount(); count != 3 {
+		t.Errorf("sent %d requests, want 3", count)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if r.URL.Path == "/movie/2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	client := server.client(Config{MaxRetries: 3})
+
+	var apiError *APIError
+	if _, err := client.GetMovie(context.Background(), 1, ""); !errors.As(err, &apiError) || apiError.StatusCode != http.StatusUnauthorized {
+		t.Errorf("err = %v, want a 401 api error", err)
+	}
+	if _, err := client.GetMovie(context.Background(), 2, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want %v", err, ErrNotFound)
+	}
+	if count := server.requestsCount(); count != 2 {
+		t.Errorf("sent %d requests, want 2", count)
+	}
+}
+
+func TestClientPausesOnTooManyRequests(t *testing.T) {
+	var pausedAt time.Time
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if attempt == 1 {
+			pausedAt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	})
+	client := server.client(Config{MaxRetries: 3})
+
+	if _, err := client.GetMovie(context.Background(), 1, ""); err != nil {
+		t.Fatalf("GetMovie: %v", err)
+	}
+
+	// Every request of the client waits for the end of the pause:
+	if _, err := client.GetMovieVideos(context.Background(), 1); err != nil {
+		t.Fatalf("GetMovieVideos: %v", err)
+	}
+	if elapsed := time.Since(pausedAt); elapsed < time.Second {
+		t.Errorf("retried %v after the 429, want at least 1s", elapsed)
+	}
+	if count := server.requestsCount(); count != 3 {
+		t.Errorf("sent %d requests, want 3", count)
+	}
+}
+
+func TestClientEscapesQueries(t *testing.T) {
+	query := `Léon & "the pro"?page=9`
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.Write([]byte(`{"page": 2, "results": []}`))
+	})
+	client := server.client(Config{CacheSize: 8, CacheTTL: time.Minute})
+
+	for range 2 {
+		if _, err := client.SearchMovies(context.Background(), query, 2); err != nil {
+			t.Fatalf("SearchMovies: %v", err)
+		}
+	}
+
+	if count := server.requestsCount(); count != 1 {
+		t.Fatalf("sent %d requests, want 1 with the second one cached", count)
+	}
+	params := server.requests[0].URL.Query()
+	if params.Get("query") != query || params.Get("page") != "2" || params.Get("api_key") != "key" {
+		t.Errorf("params = %v, want the query, page and api key", params)
+	}
+}
+
+func TestClientNullPosterPath(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.Write([]byte(`{"id": 1, "title": "Dune", "poster_path": null}`))
+	})
+	client := server.client(Config{})
+
+	movie, err := client.GetMovie(context.Background(), 1, "fr-FR")
+	if err != nil {
+		t.Fatalf("GetMovie: %v", err)
+	}
+	if movie.PosterPath != nil {
+		t.Errorf("poster path = %q, want nil", *movie.PosterPath)
+	}
+	if imageURL := client.ImageURL(movie.PosterPath); imageURL != "" {
+		t.Errorf("image url = %q, want none", imageURL)
+	}
+
+	posterPath := "/dune.jpg"
+	if imageURL := client.ImageURL(&posterPath); imageURL != "https://image.tmdb.org/t/p/w300/dune.jpg" {
+		t.Errorf("image url = %q, want the full poster url", imageURL)
+	}
+	if language := server.requests[0].URL.Query().Get("language"); language != "fr-FR" {
+		t.Errorf("language = %q, want fr-FR", language)
+	}
+}
@@ -2,14 +2,20 @@ package tmdb
 
 import (
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ApiKey     string
-	BaseURL string
-	ImagesBaseURL string
+	ApiKey            string
+	BaseURL           string
+	ImagesBaseURL     string
+	Timeout           time.Duration
+	MaxRetries        int
+	RequestsPerSecond int
+	CacheSize         int
+	CacheTTL          time.Duration
 }
 
 var tmdbAPI = initConfig()
@@ -17,8 +23,13 @@ var tmdbAPI = initConfig()
 func initConfig() Config {
 	godotenv.Load()
 	return Config{
-		ApiKey:     os.Getenv("TMDB_API_KEY"),
-		BaseURL: "https://api.themoviedb.org/3",
-		ImagesBaseURL: "https://image.tmdb.org/t/p/w300",
+		ApiKey:            os.Getenv("TMDB_API_KEY"),
+		BaseURL:           "https://api.themoviedb.org/3",
+		ImagesBaseURL:     "https://image.tmdb.org/t/p/w300",
+		Timeout:           10 * time.Second,
+		MaxRetries:        3,
+		RequestsPerSecond: 40,
+		CacheSize:         512,
+		CacheTTL:          10 * time.Minute,
 	}
-}
\ No newline at end of file
+}
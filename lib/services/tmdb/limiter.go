@@ -0,0 +1,59 @@
+package tmdb
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out the requests so that no more than the allowed number
+// is sent per second, and lets the whole client back off after a 429.
+type rateLimiter struct {
+	interval time.Duration
+	next     time.Time
+	mu       sync.Mutex
+}
+
+func newRateLimiter(requestsPerSecond int) *rateLimiter {
+	var interval time.Duration
+	if requestsPerSecond > 0 {
+		interval = time.Second / time.Duration(requestsPerSecond)
+	}
+	return &rateLimiter{
+		interval: interval,
+	}
+}
+
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	limiter.mu.Lock()
+	now := time.Now()
+	slot := limiter.next
+	if slot.Before(now) {
+		slot = now
+	}
+	limiter.next = slot.Add(limiter.interval)
+	limiter.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pause holds every request back until the given time.
+func (limiter *rateLimiter) pause(until time.Time) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if until.After(limiter.next) {
+		limiter.next = until
+	}
+}
@@ -0,0 +1,49 @@
+package tmdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSpacesRequests(t *testing.T) {
+	limiter := newRateLimiter(20)
+	start := time.Now()
+
+	for range 3 {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests at 20 per second took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	limiter := newRateLimiter(0)
+	until := time.Now().Add(100 * time.Millisecond)
+	limiter.pause(until)
+
+	// An earlier pause does not shorten the current one:
+	limiter.pause(time.Now())
+
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if now := time.Now(); now.Before(until) {
+		t.Errorf("wait returned %v before the end of the pause", until.Sub(now))
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	limiter := newRateLimiter(0)
+	limiter.pause(time.Now().Add(time.Minute))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.wait(ctx); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
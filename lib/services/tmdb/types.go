@@ -0,0 +1,93 @@
+package tmdb
+
+type MovieSummary struct {
+	ID               int     `json:"id"`
+	Title            string  `json:"title"`
+	OriginalTitle    string  `json:"original_title"`
+	OriginalLanguage string  `json:"original_language"`
+	Overview         string  `json:"overview"`
+	PosterPath       *string `json:"poster_path"`
+	ReleaseDate      string  `json:"release_date"`
+	VoteAverage      float64 `json:"vote_average"`
+	VoteCount        int     `json:"vote_count"`
+}
+
+type MoviesPage struct {
+	Page         int            `json:"page"`
+	TotalPages   int            `json:"total_pages"`
+	TotalResults int            `json:"total_results"`
+	Results      []MovieSummary `json:"results"`
+}
+
+type Genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type MovieDetails struct {
+	ID               int     `json:"id"`
+	Title            string  `json:"title"`
+	OriginalTitle    string  `json:"original_title"`
+	OriginalLanguage string  `json:"original_language"`
+	Overview         string  `json:"overview"`
+	PosterPath       *string `json:"poster_path"`
+	Runtime          *int    `json:"runtime"`
+	VoteAverage      float64 `json:"vote_average"`
+	VoteCount        int     `json:"vote_count"`
+	Genres           []Genre `json:"genres"`
+}
+
+type Video struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Site        string `json:"site"`
+	Type        string `json:"type"`
+	Official    bool   `json:"official"`
+	Size        int    `json:"size"`
+	Language    string `json:"iso_639_1"`
+	PublishedAt string `json:"published_at"`
+}
+
+type Videos struct {
+	ID      int     `json:"id"`
+	Results []Video `json:"results"`
+}
+
+type CastMember struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Character   string  `json:"character"`
+	ProfilePath *string `json:"profile_path"`
+	Order       int     `json:"order"`
+}
+
+type Credits struct {
+	ID   int          `json:"id"`
+	Cast []CastMember `json:"cast"`
+}
+
+// Release types, as numbered by TMDB:
+const (
+	ReleaseTypePremiere = iota + 1
+	ReleaseTypeTheatricalLimited
+	ReleaseTypeTheatrical
+	ReleaseTypeDigital
+	ReleaseTypePhysical
+	ReleaseTypeTV
+)
+
+type ReleaseDate struct {
+	Certification string `json:"certification"`
+	Type          int    `json:"type"`
+	ReleaseDate   string `json:"release_date"`
+}
+
+type CountryReleaseDates struct {
+	Country      string        `json:"iso_3166_1"`
+	ReleaseDates []ReleaseDate `json:"release_dates"`
+}
+
+type ReleaseDates struct {
+	ID      int                   `json:"id"`
+	Results []CountryReleaseDates `json:"results"`
+}
@@ -0,0 +1,29 @@
+package tools
+
+import "net/http"
+
+// CinemaScope tells which cinemas the authenticated admin manages, as set by
+// the cinema admin middleware. Super admins manage every cinema. Requests
+// that did not go through the middleware have an empty scope.
+type CinemaScope struct {
+	IsSuperAdmin bool
+	CinemaIDs    []uint
+}
+
+func GetCinemaScope(r *http.Request) CinemaScope {
+	auth, _ := r.Context().Value("auth").(map[string]any)
+	cinemaScope, _ := auth["cinemaScope"].(CinemaScope)
+	return cinemaScope
+}
+
+func (cinemaScope CinemaScope) Includes(cinemaID uint) bool {
+	if cinemaScope.IsSuperAdmin {
+		return true
+	}
+	for _, adminCinemaID := range cinemaScope.CinemaIDs {
+		if adminCinemaID == cinemaID {
+			return true
+		}
+	}
+	return false
+}
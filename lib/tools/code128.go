@@ -0,0 +1,54 @@
+package tools
+
+import "errors"
+
+// Widths of the bars and spaces of the Code 128 symbols, in modules, starting
+// with a bar:
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128Stop   = 106
+)
+
+// EncodeCode128 encodes printable ASCII data as a Code 128 barcode, in code
+// set B. It returns the widths of its bars and spaces in modules, starting
+// with a bar, without the quiet zones.
+func EncodeCode128(data string) ([]int, error) {
+	if data == "" {
+		return nil, errors.New("INVALID_BARCODE_DATA")
+	}
+
+	symbols := []int{code128StartB}
+	checksum := code128StartB
+	for index := 0; index < len(data); index++ {
+		character := data[index]
+		if character < 32 || character > 127 {
+			return nil, errors.New("INVALID_BARCODE_DATA")
+		}
+		value := int(character) - 32
+		symbols = append(symbols, value)
+		checksum += value * (index + 1)
+	}
+	symbols = append(symbols, checksum%103, code128Stop)
+
+	var widths []int
+	for _, symbol := range symbols {
+		for _, width := range code128Patterns[symbol] {
+			widths = append(widths, int(width-'0'))
+		}
+	}
+	return widths, nil
+}
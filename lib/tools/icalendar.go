@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CalendarEvent is an event of an iCalendar file, as RFC 5545 defines it.
+type CalendarEvent struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Location    string
+	Description string
+}
+
+// EncodeCalendar writes the events as an iCalendar file.
+func EncodeCalendar(name string, events []CalendarEvent) []byte {
+	var calendar strings.Builder
+	writeCalendarLine(&calendar, "BEGIN:VCALENDAR")
+	writeCalendarLine(&calendar, "VERSION:2.0")
+	writeCalendarLine(&calendar, "PRODID:-//Kinema//Reservations//EN")
+	writeCalendarLine(&calendar, "CALSCALE:GREGORIAN")
+	writeCalendarLine(&calendar, "METHOD:PUBLISH")
+	if name != "" {
+		writeCalendarLine(&calendar, "X-WR-CALNAME:"+escapeCalendarText(name))
+	}
+
+	stamp := formatCalendarTime(time.Now())
+	for _, event := range events {
+		writeCalendarLine(&calendar, "BEGIN:VEVENT")
+		writeCalendarLine(&calendar, "UID:"+event.UID)
+		writeCalendarLine(&calendar, "DTSTAMP:"+stamp)
+		writeCalendarLine(&calendar, "DTSTART:"+formatCalendarTime(event.Start))
+		writeCalendarLine(&calendar, "DTEND:"+formatCalendarTime(event.End))
+		writeCalendarLine(&calendar, "SUMMARY:"+escapeCalendarText(event.Summary))
+		if event.Location != "" {
+			writeCalendarLine(&calendar, "LOCATION:"+escapeCalendarText(event.Location))
+		}
+		if event.Description != "" {
+			writeCalendarLine(&calendar, "DESCRIPTION:"+escapeCalendarText(event.Description))
+		}
+		writeCalendarLine(&calendar, "END:VEVENT")
+	}
+
+	writeCalendarLine(&calendar, "END:VCALENDAR")
+	return []byte(calendar.String())
+}
+
+func formatCalendarTime(at time.Time) string {
+	return at.UTC().Format("20060102T150405Z")
+}
+
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
+
+// writeCalendarLine ends the line with CRLF, folding it so that no line is
+// longer than 75 bytes, without splitting UTF-8 characters.
+func writeCalendarLine(calendar *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		fmt.Fprintf(calendar, "%v\r\n ", line[:cut])
+		line = line[cut:]
+		// Continuation lines start with a space:
+		limit = 74
+	}
+	fmt.Fprintf(calendar, "%v\r\n", line)
+}
@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	gorm "gorm.io/gorm"
+	clause "gorm.io/gorm/clause"
+	schema "gorm.io/gorm/schema"
+)
+
+const (
+	// Page size of the listings that have a default limit, and of the ones
+	// without when a cursor is sent alone:
+	DefaultListingLimit = 50
+	maxListingLimit     = 200
+)
+
+var listingSchemas sync.Map
+
+// ListingQuery is the page of a listing requested through the limit, cursor,
+// sort and fields query parameters. A zero limit lists every row.
+type ListingQuery struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Fields []string
+
+	sortKeys     []sortKey
+	cursorValues []interface{}
+}
+
+// ListingOptions describes what a listing endpoint accepts. The sort fields
+// map the sort keys to non null columns of the listed model. Without a default
+// limit, the requests that send neither a limit nor a cursor get every row, as
+// the endpoints listed before they were paginated.
+type ListingOptions struct {
+	Model        interface{}
+	SortFields   map[string]string
+	DefaultSort  string
+	DefaultLimit int
+}
+
+// ListingPage is what FindPage tells about the listing besides its rows.
+type ListingPage struct {
+	Total      int64
+	NextCursor string
+}
+
+type sortKey struct {
+	field      *schema.Field
+	descending bool
+}
+
+type listingCursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// GetListingQuery reads the listing parameters of the request, which are
+// checked by Validate.
+func GetListingQuery(r *http.Request) ListingQuery {
+	query := r.URL.Query()
+
+	listing := ListingQuery{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		parsedLimit, err := strconv.Atoi(limit)
+		// A zero limit sent is invalid, not a request for every row:
+		if err != nil || parsedLimit == 0 {
+			parsedLimit = -1
+		}
+		listing.Limit = parsedLimit
+	}
+	for _, fields := range query["fields"] {
+		for _, field := range strings.Split(fields, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				listing.Fields = append(listing.Fields, field)
+			}
+		}
+	}
+	return listing
+}
+
+// Validate checks the listing parameters against what the endpoint accepts,
+// and decodes the sort and the cursor.
+func (listing *ListingQuery) Validate(options ListingOptions) error {
+	if listing.Limit == 0 {
+		listing.Limit = options.DefaultLimit
+		if listing.Limit == 0 && listing.Cursor != "" {
+			listing.Limit = DefaultListingLimit
+		}
+	}
+	if listing.Limit < 0 || listing.Limit > maxListingLimit {
+		return errors.New("INVALID_LIMIT")
+	}
+
+	modelSchema, err := schema.Parse(options.Model, &listingSchemas, schema.NamingStrategy{})
+	if err != nil {
+		return err
+	}
+
+	if listing.Sort == "" {
+		listing.Sort = options.DefaultSort
+	}
+	listing.sortKeys = nil
+	hasPrimaryKey := false
+	for _, key := range strings.Split(listing.Sort, ",") {
+		key = strings.TrimSpace(key)
+		name, descending := strings.CutPrefix(key, "-")
+		column, ok := options.SortFields[name]
+		if !ok {
+			return errors.New("INVALID_SORT")
+		}
+		field := modelSchema.LookUpField(column)
+		if field == nil {
+			return errors.New("INVALID_SORT")
+		}
+		if field == modelSchema.PrioritizedPrimaryField {
+			hasPrimaryKey = true
+		}
+		listing.sortKeys = append(listing.sortKeys, sortKey{field: field, descending: descending})
+	}
+	// The primary key breaks the ties, so that the cursor is stable:
+	if !hasPrimaryKey {
+		listing.sortKeys = append(listing.sortKeys, sortKey{field: modelSchema.PrioritizedPrimaryField})
+	}
+
+	listing.cursorValues = nil
+	if listing.Cursor != "" {
+		var cursor listingCursor
+		decodedCursor, err := base64.RawURLEncoding.DecodeString(listing.Cursor)
+		if err != nil || json.Unmarshal(decodedCursor, &cursor) != nil {
+			return errors.New("INVALID_CURSOR")
+		}
+		if cursor.Sort != listing.Sort || len(cursor.Values) != len(listing.sortKeys) {
+			return errors.New("INVALID_CURSOR")
+		}
+		for index, key := range listing.sortKeys {
+			value := reflect.New(key.field.FieldType)
+			if err := json.Unmarshal(cursor.Values[index], value.Interface()); err != nil {
+				return errors.New("INVALID_CURSOR")
+			}
+			listing.cursorValues = append(listing.cursorValues, value.Elem().Interface())
+		}
+	}
+
+	jsonFields := jsonFieldNames(modelSchema.ModelType)
+	for _, field := range listing.Fields {
+		if !jsonFields[field] {
+			return errors.New("INVALID_FIELDS")
+		}
+	}
+
+	return nil
+}
+
+// FindPage counts the rows of the query, then finds into dest, a pointer to a
+// slice of the listed model, the page following the cursor, or every row when
+// the listing has no limit. The listing must have been validated.
+func FindPage(query *gorm.DB, dest interface{}, listing ListingQuery) (ListingPage, error) {
+	var page ListingPage
+
+	err := query.Session(&gorm.Session{}).Count(&page.Total).Error
+	if err != nil {
+		return page, err
+	}
+
+	query = query.Session(&gorm.Session{})
+	if listing.cursorValues != nil {
+		query = query.Where(listing.afterCursor())
+	}
+	for _, key := range listing.sortKeys {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: key.field.DBName},
+			Desc:   key.descending,
+		})
+	}
+
+	if listing.Limit == 0 {
+		err = query.Find(dest).Error
+		return page, err
+	}
+
+	err = query.Limit(listing.Limit + 1).Find(dest).Error
+	if err != nil {
+		return page, err
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() <= listing.Limit {
+		return page, nil
+	}
+
+	// The extra row only tells that there is a next page:
+	last := rows.Index(listing.Limit - 1)
+	rows.Set(rows.Slice(0, listing.Limit))
+
+	cursor := listingCursor{Sort: listing.Sort}
+	for _, key := range listing.sortKeys {
+		value, _ := key.field.ValueOf(query.Statement.Context, last)
+		encodedValue, err := json.Marshal(value)
+		if err != nil {
+			return page, err
+		}
+		cursor.Values = append(cursor.Values, encodedValue)
+	}
+	encodedCursor, _ := json.Marshal(cursor)
+	page.NextCursor = base64.RawURLEncoding.EncodeToString(encodedCursor)
+
+	return page, nil
+}
+
+// afterCursor matches the rows sorted after the cursor: those greater on the
+// first key, or equal on it and greater on the next one, and so on.
+func (listing *ListingQuery) afterCursor() clause.Expression {
+	var conditions []clause.Expression
+	for index, key := range listing.sortKeys {
+		var equalities []clause.Expression
+		for previous := 0; previous < index; previous++ {
+			equalities = append(equalities, clause.Eq{
+				Column: clause.Column{Table: clause.CurrentTable, Name: listing.sortKeys[previous].field.DBName},
+				Value:  listing.cursorValues[previous],
+			})
+		}
+
+		column := clause.Column{Table: clause.CurrentTable, Name: key.field.DBName}
+		if key.descending {
+			equalities = append(equalities, clause.Lt{Column: column, Value: listing.cursorValues[index]})
+		} else {
+			equalities = append(equalities, clause.Gt{Column: column, Value: listing.cursorValues[index]})
+		}
+		conditions = append(conditions, clause.And(equalities...))
+	}
+	return clause.Or(conditions...)
+}
+
+// SelectFields keeps only the requested fields of the listed rows, or returns
+// them untouched when no fields were requested.
+func SelectFields(rows interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return rows
+	}
+
+	encodedRows, _ := json.Marshal(rows)
+	var decodedRows []map[string]json.RawMessage
+	json.Unmarshal(encodedRows, &decodedRows)
+
+	selectedRows := make([]map[string]json.RawMessage, len(decodedRows))
+	for index, row := range decodedRows {
+		selectedRows[index] = make(map[string]json.RawMessage)
+		for _, field := range fields {
+			if value, ok := row[field]; ok {
+				selectedRows[index][field] = value
+			}
+		}
+	}
+	return selectedRows
+}
+
+// jsonFieldNames returns the JSON names of the fields of a struct type,
+// including the ones of its embedded structs.
+func jsonFieldNames(modelType reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for index := 0; index < modelType.NumField(); index++ {
+		field := modelType.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embeddedName := range jsonFieldNames(field.Type) {
+				names[embeddedName] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
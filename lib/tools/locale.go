@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GetLocale returns the supported locale preferred by the client through the
+// Accept-Language header, or an empty string when there is none.
+func GetLocale(r *http.Request, supportedLocales []string) string {
+	type languageRange struct {
+		locale  string
+		quality float64
+	}
+
+	var ranges []languageRange
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsedQuality, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsedQuality
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		// Only the primary language subtag is matched (fr-CA is fr):
+		locale, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		ranges = append(ranges, languageRange{
+			locale:  locale,
+			quality: quality,
+		})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, languageRange := range ranges {
+		if slices.Contains(supportedLocales, languageRange.locale) {
+			return languageRange.locale
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Size of A4 pages, in points:
+const (
+	PDFPageWidth  = 595.28
+	PDFPageHeight = 841.89
+)
+
+// PDFDocument writes PDF files with text in the standard Helvetica fonts,
+// lines and filled rectangles, which every reader renders without embedded
+// fonts. Positions are in points from the top left corner of the page.
+type PDFDocument struct {
+	pages []*PDFPage
+}
+
+type PDFPage struct {
+	content bytes.Buffer
+}
+
+func NewPDFDocument() *PDFDocument {
+	return &PDFDocument{}
+}
+
+func (document *PDFDocument) AddPage() *PDFPage {
+	page := &PDFPage{}
+	document.pages = append(document.pages, page)
+	return page
+}
+
+// Text writes the text with its baseline at the given position.
+func (page *PDFPage) Text(x float64, y float64, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&page.content, "BT /%v %.2f Tf %.2f %.2f Td (%v) Tj ET\n", font, size, x, PDFPageHeight-y, escapePDFText(text))
+}
+
+func (page *PDFPage) Line(x1 float64, y1 float64, x2 float64, y2 float64, width float64) {
+	fmt.Fprintf(&page.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, PDFPageHeight-y1, x2, PDFPageHeight-y2)
+}
+
+// Rect fills the rectangle whose top left corner is at the given position.
+func (page *PDFPage) Rect(x float64, y float64, width float64, height float64) {
+	fmt.Fprintf(&page.content, "%.2f %.2f %.2f %.2f re f\n", x, PDFPageHeight-y-height, width, height)
+}
+
+// Barcode draws the Code 128 barcode of the data, its top left corner being
+// at the given position.
+func (page *PDFPage) Barcode(x float64, y float64, moduleWidth float64, height float64, data string) error {
+	widths, err := EncodeCode128(data)
+	if err != nil {
+		return err
+	}
+
+	for index, width := range widths {
+		// Bars and spaces alternate, starting with a bar:
+		if index%2 == 0 {
+			page.Rect(x, y, float64(width)*moduleWidth, height)
+		}
+		x += float64(width) * moduleWidth
+	}
+	return nil
+}
+
+// Bytes returns the PDF file.
+func (document *PDFDocument) Bytes() []byte {
+	var objects []string
+
+	// Catalog, pages and fonts come first, each page then adds its page and
+	// content objects:
+	pagesCount := len(document.pages)
+	var kids []string
+	for index := range document.pages {
+		kids = append(kids, fmt.Sprintf("%v 0 R", 5+2*index))
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %v >>", strings.Join(kids, " "), pagesCount),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for index, page := range document.pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %v 0 R >>", PDFPageWidth, PDFPageHeight, 6+2*index),
+			fmt.Sprintf("<< /Length %v >>\nstream\n%vendstream", page.content.Len(), page.content.String()),
+		)
+	}
+
+	var file bytes.Buffer
+	file.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for index, object := range objects {
+		offsets[index] = file.Len()
+		fmt.Fprintf(&file, "%v 0 obj\n%v\nendobj\n", index+1, object)
+	}
+
+	xrefOffset := file.Len()
+	fmt.Fprintf(&file, "xref\n0 %v\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&file, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&file, "trailer\n<< /Size %v /Root 1 0 R >>\nstartxref\n%v\n%%%%EOF\n", len(objects)+1, xrefOffset)
+	return file.Bytes()
+}
+
+// escapePDFText encodes the text in WinAnsi, the characters it lacks being
+// replaced by question marks.
+func escapePDFText(text string) string {
+	var escaped strings.Builder
+	for _, character := range text {
+		switch {
+		case character == '(' || character == ')' || character == '\\':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(character)
+		case character == '€':
+			escaped.WriteString(`\200`)
+		case character >= 32 && character < 127:
+			escaped.WriteRune(character)
+		case character >= 160 && character < 256:
+			fmt.Fprintf(&escaped, `\%03o`, character)
+		default:
+			escaped.WriteByte('?')
+		}
+	}
+	return escaped.String()
+}
@@ -0,0 +1,21 @@
+package tools
+
+import "time"
+
+// DayBounds returns midnight of the calendar day of the given date in the
+// location, and midnight of the next day. The calendar date is read as given,
+// whatever the location it was sent in. Around DST transitions a day lasts 23
+// or 25 hours, so the end is computed from the calendar rather than by adding
+// 24 hours.
+func DayBounds(day time.Time, location *time.Location) (time.Time, time.Time) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, location)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// WeekStart returns midnight of the Sunday opening the week that contains the
+// instant, in the location.
+func WeekStart(instant time.Time, location *time.Location) time.Time {
+	local := instant.In(location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	return day.AddDate(0, 0, -int(day.Weekday()))
+}